@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"time"
+
+	"go_news_api/endpoints"
+	"go_news_api/utils"
+)
+
+// runCommand runs a maintenance command given on the command line and
+// returns the process exit code
+func runCommand(name string, args []string) int {
+	switch name {
+	case "retrain-classifier":
+		return retrainClassifierCommand()
+	case "extract-entities":
+		return extractEntitiesCommand()
+	case "summarize-articles":
+		return summarizeArticlesCommand()
+	case "index-articles":
+		return indexArticlesCommand()
+	case "rebuild-keyword-rollups":
+		return rebuildKeywordRollupsCommand()
+	case "rederive-keywords":
+		return rederiveKeywordsCommand()
+	case "export-articles":
+		return exportArticlesCommand(args)
+	case "import-articles":
+		return importArticlesCommand(args)
+	case "reprocess-payloads":
+		return reprocessPayloadsCommand(args)
+	default:
+		log.Printf("Unknown command %q (available: retrain-classifier, extract-entities, summarize-articles, index-articles, rebuild-keyword-rollups, rederive-keywords, export-articles, import-articles, reprocess-payloads)", name)
+		return 2
+	}
+}
+
+// retrainClassifierCommand retrains the category classifier and prints its
+// held-out evaluation metrics
+func retrainClassifierCommand() int {
+	result, err := endpoints.RetrainClassifier(context.Background())
+	if err != nil {
+		log.Printf("Retraining failed: %v", err)
+		return 1
+	}
+
+	metrics := result.Metrics
+	fmt.Printf("Classifier %d trained on %d articles, evaluated on %d\n", result.ModelID, metrics.TrainSize, metrics.TestSize)
+	fmt.Printf("Accuracy %.3f, macro F1 %.3f\n\n", metrics.Accuracy, metrics.MacroF1)
+
+	labels := make([]string, 0, len(metrics.Classes))
+	for label := range metrics.Classes {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	fmt.Printf("%-14s %9s %9s %9s %8s\n", "category", "precision", "recall", "f1", "support")
+	for _, label := range labels {
+		class := metrics.Classes[label]
+		fmt.Printf("%-14s %9.3f %9.3f %9.3f %8d\n", label, class.Precision, class.Recall, class.F1, class.Support)
+	}
+
+	fmt.Printf("\nReclassified %d articles\n", result.Reclassified)
+	return 0
+}
+
+// extractEntitiesCommand extracts entities from every article that has none
+// recorded yet
+func extractEntitiesCommand() int {
+	count, err := endpoints.ExtractMissingEntities(context.Background())
+	if err != nil {
+		log.Printf("Entity extraction failed after %d articles: %v", count, err)
+		return 1
+	}
+	fmt.Printf("Extracted entities from %d articles\n", count)
+	return 0
+}
+
+// summarizeArticlesCommand summarizes every extracted article without a
+// summary
+func summarizeArticlesCommand() int {
+	count, err := endpoints.SummarizeMissingArticles(context.Background())
+	if err != nil {
+		log.Printf("Summarizing failed after %d articles: %v", count, err)
+		return 1
+	}
+	fmt.Printf("Summarized %d articles\n", count)
+	return 0
+}
+
+// indexArticlesCommand computes the related articles vectors of every article
+// without one
+func indexArticlesCommand() int {
+	ctx := context.Background()
+	// Weigh new vectors with the frequencies of those already stored
+	if err := endpoints.LoadRelatedIndex(ctx); err != nil {
+		log.Print(err)
+		return 1
+	}
+	count, err := endpoints.IndexMissingArticles(ctx)
+	if err != nil {
+		log.Printf("Indexing failed after %d articles: %v", count, err)
+		return 1
+	}
+	fmt.Printf("Indexed %d articles\n", count)
+	return 0
+}
+
+// rebuildKeywordRollupsCommand recomputes the keyword time series rollups
+func rebuildKeywordRollupsCommand() int {
+	if err := utils.RebuildKeywordRollups(); err != nil {
+		log.Print(err)
+		return 1
+	}
+	fmt.Println("Rebuilt keyword rollups")
+	return 0
+}
+
+// rederiveKeywordsCommand extracts the keywords of every article again and
+// rebuilds the keyword rollups
+func rederiveKeywordsCommand() int {
+	count, err := endpoints.RederiveKeywords(context.Background())
+	if err != nil {
+		log.Printf("Re-deriving keywords failed after %d articles: %v", count, err)
+		return 1
+	}
+	fmt.Printf("Re-derived the keywords of %d articles\n", count)
+	return 0
+}
+
+// exportArticlesCommand writes the articles matching the filters given as
+// flags, named like the export endpoint's query parameters, to -out or to
+// standard output
+func exportArticlesCommand(args []string) int {
+	flags := flag.NewFlagSet("export-articles", flag.ContinueOnError)
+	out := flags.String("out", "-", "file to write, - for standard output")
+	names := []string{"format", "q", "lang", "category", "from", "to", "source", "keyword", "provider"}
+	for _, name := range names {
+		flags.String(name, "", "same as the "+name+" query parameter of /exports/articles")
+	}
+	fullText := flags.Bool("full-text", false, "include the extracted full text")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	values := url.Values{}
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name != "out" && f.Name != "full-text" {
+			values.Set(f.Name, f.Value.String())
+		}
+	})
+	if *fullText {
+		values.Set("full_text", "true")
+	}
+	params, err := endpoints.ParseExportParams(values)
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+
+	file := os.Stdout
+	if *out != "-" {
+		if file, err = os.Create(*out); err != nil {
+			log.Print(err)
+			return 1
+		}
+	}
+	w := bufio.NewWriter(file)
+	count, err := endpoints.WriteExport(context.Background(), params, w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if file != os.Stdout {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		log.Printf("Export failed after %d articles: %v", count, err)
+		return 1
+	}
+	log.Printf("Exported %d articles", count)
+	return 0
+}
+
+// importArticlesCommand imports the files given as arguments, one import run
+// each, and prints each run's report as JSON. Importing a file again resumes
+// it or, once done, changes nothing.
+func importArticlesCommand(args []string) int {
+	flags := flag.NewFlagSet("import-articles", flag.ContinueOnError)
+	for _, name := range []string{"format", "provider", "lang"} {
+		flags.String(name, "", "same as the "+name+" query parameter of /admin/imports")
+	}
+	dryRun := flags.Bool("dry-run", false, "only validate and report what would be imported")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		log.Print("Usage: import-articles [flags] file...")
+		return 2
+	}
+
+	values := url.Values{}
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name != "dry-run" {
+			values.Set(f.Name, f.Value.String())
+		}
+	})
+	if *dryRun {
+		values.Set("dry_run", "true")
+	}
+
+	status := 0
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	for _, path := range flags.Args() {
+		values.Set("filename", path)
+		params, err := endpoints.ParseImportParams(values)
+		if err != nil {
+			log.Print(err)
+			return 2
+		}
+		result, err := endpoints.RunImport(context.Background(), params, path)
+		if result != nil {
+			encoder.Encode(result)
+		}
+		if err != nil {
+			log.Printf("Import of %s failed: %v", path, err)
+			status = 1
+		}
+	}
+	return status
+}
+
+// reprocessPayloadsCommand replays archived provider responses through the
+// current mapping and save path, and prints what it did
+func reprocessPayloadsCommand(args []string) int {
+	flags := flag.NewFlagSet("reprocess-payloads", flag.ContinueOnError)
+	provider := flags.String("provider", "", "only payloads of this provider (newsapi or gnews)")
+	from := flags.String("from", "", "only payloads fetched at or after (RFC3339 or YYYY-MM-DD)")
+	to := flags.String("to", "", "only payloads fetched at or before (RFC3339 or YYYY-MM-DD)")
+	id := flags.Uint("id", 0, "only the payload with this id")
+	dryRun := flags.Bool("dry-run", false, "only report what would be saved")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	opts := endpoints.ReprocessOptions{Provider: *provider, ID: *id, DryRun: *dryRun}
+	for _, bound := range []struct {
+		value string
+		into  **time.Time
+	}{{*from, &opts.From}, {*to, &opts.To}} {
+		if bound.value == "" {
+			continue
+		}
+		t := utils.ParsePublishedAt(bound.value)
+		if t == nil {
+			log.Printf("Invalid date %q", bound.value)
+			return 2
+		}
+		*bound.into = t
+	}
+
+	result, err := endpoints.ReprocessPayloads(context.Background(), opts)
+	if result != nil {
+		fmt.Printf("%d payloads, %d skipped, %d failed: %d articles, %d created, %d updated\n",
+			result.Payloads, result.Skipped, result.Failed, result.Articles, result.Created, result.Updated)
+	}
+	if err != nil {
+		log.Printf("Reprocessing failed: %v", err)
+		return 1
+	}
+	if result.Failed > 0 {
+		return 1
+	}
+	return 0
+}
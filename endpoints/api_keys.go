@@ -0,0 +1,194 @@
+package endpoints
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"go_news_api/utils"
+)
+
+// APIKey is one credential for a provider with its own daily quota
+type APIKey struct {
+	Value       string
+	Fingerprint string
+	Quota       *RequestQuota
+}
+
+// APIKeyStatus is the health view of a key. It never includes the key itself.
+type APIKeyStatus struct {
+	Fingerprint  string     `json:"fingerprint"`
+	Remaining    int        `json:"remaining"`
+	BlockedUntil *time.Time `json:"blocked_until,omitempty"`
+}
+
+// KeyRing rotates between a provider's API keys, moving on to the next key
+// when one is out of quota, rate limited or rejected
+type KeyRing struct {
+	Provider string
+
+	mu   sync.Mutex
+	keys []*APIKey
+	next int
+}
+
+// Fingerprint identifies a key in logs and health output without revealing it
+func Fingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(sum[:])[:12]
+}
+
+// NewKeyRing creates a ring from the comma-separated list in listVar, falling
+// back to the single key in singleVar
+func NewKeyRing(provider, listVar, singleVar string, dailyLimit int) *KeyRing {
+	values := utils.GetEnvList(listVar, nil)
+	if len(values) == 0 {
+		values = utils.GetEnvList(singleVar, nil)
+	}
+
+	ring := &KeyRing{Provider: provider}
+	seen := map[string]bool{}
+	for _, value := range values {
+		if seen[value] {
+			continue
+		}
+		seen[value] = true
+		ring.keys = append(ring.keys, &APIKey{
+			Value:       value,
+			Fingerprint: Fingerprint(value),
+			Quota:       NewRequestQuota(dailyLimit),
+		})
+	}
+	if len(ring.keys) == 0 {
+		log.Printf("Warning: no API keys configured for %s (set %s or %s)", provider, listVar, singleVar)
+	}
+	return ring
+}
+
+// Do calls fn with an available key. When fn fails because the key is rate
+// limited or rejected, the key is parked and fn is retried with the next one.
+func (r *KeyRing) Do(fn func(key *APIKey) error) error {
+	var lastErr error
+	for attempt := 0; attempt < len(r.keys); attempt++ {
+		key := r.acquire()
+		if key == nil {
+			break
+		}
+
+		err := fn(key)
+		if err == nil || !r.park(key, err) {
+			return err
+		}
+		lastErr = err
+	}
+
+	if lastErr != nil {
+		return lastErr
+	}
+	return r.exhaustedError()
+}
+
+// Exhausted reports whether every key is out of quota or parked
+func (r *KeyRing) Exhausted() bool {
+	for _, key := range r.keys {
+		if !key.Quota.Exhausted() {
+			return false
+		}
+	}
+	return true
+}
+
+// Remaining sums the requests left today across all keys
+func (r *KeyRing) Remaining() int {
+	total := 0
+	for _, key := range r.keys {
+		total += key.Quota.Remaining()
+	}
+	return total
+}
+
+// Statuses reports quota state per key fingerprint
+func (r *KeyRing) Statuses() []APIKeyStatus {
+	statuses := make([]APIKeyStatus, 0, len(r.keys))
+	for _, key := range r.keys {
+		status := APIKeyStatus{
+			Fingerprint: key.Fingerprint,
+			Remaining:   key.Quota.Remaining(),
+		}
+		if until := key.Quota.BlockedUntil(); !until.IsZero() {
+			status.BlockedUntil = &until
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// acquire takes one request from the next key with quota left, round-robin
+func (r *KeyRing) acquire() *APIKey {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := 0; i < len(r.keys); i++ {
+		key := r.keys[(r.next+i)%len(r.keys)]
+		if key.Quota.Take() {
+			r.next = (r.next + i + 1) % len(r.keys)
+			return key
+		}
+	}
+	return nil
+}
+
+// park blocks a key after a rate limit or auth error reported by the provider
+// and reports whether another key should be tried
+func (r *KeyRing) park(key *APIKey, err error) bool {
+	var providerErr *ProviderError
+	if !errors.As(err, &providerErr) || providerErr.StatusCode == 0 {
+		return false
+	}
+
+	switch {
+	case errors.Is(err, ErrRateLimited):
+		key.Quota.ObserveError(err)
+		log.Printf("%s key %s rate limited until %s, rotating", r.Provider, key.Fingerprint, key.Quota.BlockedUntil().Format(time.RFC3339))
+	case errors.Is(err, ErrUnauthorized):
+		key.Quota.Block(time.Now().Add(utils.GetEnvDuration("KEY_AUTH_BLOCK_DURATION", 24*time.Hour)))
+		log.Printf("%s key %s rejected (%s), rotating", r.Provider, key.Fingerprint, providerErr.Code)
+	default:
+		return false
+	}
+	return true
+}
+
+// exhaustedError explains why no key could be used
+func (r *KeyRing) exhaustedError() error {
+	if len(r.keys) == 0 {
+		return &ProviderError{
+			Provider: r.Provider,
+			Message:  "no API key configured",
+			Kind:     ErrUnauthorized,
+		}
+	}
+
+	err := &ProviderError{
+		Provider: r.Provider,
+		Message:  "request limit reached for today on all API keys",
+		Kind:     ErrRateLimited,
+	}
+	// If every key is only temporarily blocked, tell the caller when the first
+	// one becomes usable again
+	var earliest time.Time
+	for _, key := range r.keys {
+		until := key.Quota.BlockedUntil()
+		if until.IsZero() {
+			return err
+		}
+		if earliest.IsZero() || until.Before(earliest) {
+			earliest = until
+		}
+	}
+	err.Message = "all API keys are rate limited or rejected"
+	err.RetryAfter = time.Until(earliest)
+	return err
+}
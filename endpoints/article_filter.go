@@ -0,0 +1,143 @@
+package endpoints
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"go_news_api/nlp"
+	"go_news_api/utils"
+
+	"gorm.io/gorm"
+)
+
+// Sort orders accepted by listing endpoints
+const (
+	SortRelevance   = "relevance"
+	SortPublishedAt = "published_at"
+)
+
+// ArticleFilter is the publish date range, category and ordering shared by
+// the article listing and search endpoints
+type ArticleFilter struct {
+	From *time.Time
+	// To is inclusive unless ToExclusive is set, as it is for a to date
+	// without a time of day: the range then covers that whole day and To is
+	// the start of the next one
+	To          *time.Time
+	ToExclusive bool
+	Category    string
+	Sort        string
+	Ascending   bool
+}
+
+// ParseArticleFilter reads the from, to, category, sort and order query
+// parameters. from and to take any format ParsePublishedAt understands, e.g.
+// 2024-05-01 or an RFC3339 timestamp; a to date without a time includes the
+// whole day.
+func ParseArticleFilter(values url.Values) (ArticleFilter, error) {
+	var filter ArticleFilter
+	if from := values.Get("from"); from != "" {
+		if filter.From = utils.ParsePublishedAt(from); filter.From == nil {
+			return filter, fmt.Errorf("%w: invalid from date %q", ErrBadRequest, from)
+		}
+	}
+	if to := values.Get("to"); to != "" {
+		if filter.To = utils.ParsePublishedAt(to); filter.To == nil {
+			return filter, fmt.Errorf("%w: invalid to date %q", ErrBadRequest, to)
+		}
+		if utils.IsDateOnly(to) {
+			endOfDay := filter.To.Add(24 * time.Hour)
+			filter.To, filter.ToExclusive = &endOfDay, true
+		}
+	}
+	if filter.From != nil && filter.To != nil && (filter.To.Before(*filter.From) || (filter.ToExclusive && filter.To.Equal(*filter.From))) {
+		return filter, fmt.Errorf("%w: to must not be before from", ErrBadRequest)
+	}
+
+	if filter.Category = strings.ToLower(values.Get("category")); filter.Category != "" && !nlp.IsCategory(filter.Category) {
+		return filter, fmt.Errorf("%w: category must be one of %s", ErrBadRequest, strings.Join(nlp.Categories, ", "))
+	}
+
+	if filter.Sort = values.Get("sort"); filter.Sort == "" {
+		filter.Sort = SortRelevance
+	}
+	switch filter.Sort {
+	case SortRelevance, SortPublishedAt:
+	default:
+		return filter, fmt.Errorf("%w: sort must be %s or %s", ErrBadRequest, SortRelevance, SortPublishedAt)
+	}
+	switch order := values.Get("order"); order {
+	case "asc":
+		filter.Ascending = true
+	case "desc", "":
+	default:
+		return filter, fmt.Errorf("%w: order must be asc or desc", ErrBadRequest)
+	}
+	return filter, nil
+}
+
+// IsZero reports whether the filter leaves a listing unchanged
+func (f ArticleFilter) IsZero() bool {
+	return f.From == nil && f.To == nil && f.Category == "" && f.Sort != SortPublishedAt
+}
+
+// Apply adds the date range, category and, for sort=published_at, the
+// ordering to an articles query. Relevance ordering is left to the caller.
+func (f ArticleFilter) Apply(query *gorm.DB) *gorm.DB {
+	if f.From != nil {
+		query = query.Where("articles.published_at >= ?", *f.From)
+	}
+	if f.To != nil && f.ToExclusive {
+		query = query.Where("articles.published_at < ?", *f.To)
+	} else if f.To != nil {
+		query = query.Where("articles.published_at <= ?", *f.To)
+	}
+	if f.Category != "" {
+		query = query.Where("articles.category = ?", f.Category)
+	}
+	if f.Sort == SortPublishedAt {
+		if f.Ascending {
+			query = query.Order("articles.published_at ASC NULLS LAST")
+		} else {
+			query = query.Order("articles.published_at DESC NULLS LAST")
+		}
+	}
+	return query
+}
+
+// FilterArticles applies the filter to articles that came straight from a
+// provider rather than from the database
+func (f ArticleFilter) FilterArticles(articles []utils.Article) []utils.Article {
+	filtered := make([]utils.Article, 0, len(articles))
+	for _, article := range articles {
+		if f.From != nil && (article.PublishedAt == nil || article.PublishedAt.Before(*f.From)) {
+			continue
+		}
+		if f.To != nil && (article.PublishedAt == nil || article.PublishedAt.After(*f.To) ||
+			(f.ToExclusive && article.PublishedAt.Equal(*f.To))) {
+			continue
+		}
+		if f.Category != "" && article.Category != f.Category {
+			continue
+		}
+		filtered = append(filtered, article)
+	}
+
+	if f.Sort == SortPublishedAt {
+		sort.SliceStable(filtered, func(i, j int) bool {
+			a, b := filtered[i].PublishedAt, filtered[j].PublishedAt
+			if a == nil || b == nil {
+				// Undated articles go last
+				return b == nil && a != nil
+			}
+			if f.Ascending {
+				return a.Before(*b)
+			}
+			return a.After(*b)
+		})
+	}
+	return filtered
+}
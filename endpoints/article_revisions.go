@@ -0,0 +1,166 @@
+package endpoints
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go_news_api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// revisionFields returns the provider-supplied fields that revisions track
+func revisionFields(article *utils.Article) []utils.FieldChange {
+	return []utils.FieldChange{
+		{Field: "title", New: article.Title},
+		{Field: "description", New: article.Description},
+		{Field: "content", New: article.Content},
+		{Field: "author", New: article.Author},
+		{Field: "urlToImage", New: article.URLToImage},
+	}
+}
+
+// ArticleContentHash fingerprints the fields tracked by revisions
+func ArticleContentHash(article *utils.Article) string {
+	h := sha256.New()
+	for _, field := range revisionFields(article) {
+		// Length prefixes keep "ab"+"c" and "a"+"bc" apart
+		fmt.Fprintf(h, "%d:%s\n", len(field.New), field.New)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// diffArticle lists the tracked fields that differ between two versions
+func diffArticle(before, after *utils.Article) []utils.FieldChange {
+	var changes []utils.FieldChange
+	old := revisionFields(before)
+	for i, field := range revisionFields(after) {
+		if old[i].New != field.New {
+			changes = append(changes, utils.FieldChange{Field: field.Field, Old: old[i].New, New: field.New})
+		}
+	}
+	return changes
+}
+
+// SaveRevision compares an article before and after a re-fetch and, when a
+// tracked field changed, stores the difference as an ArticleRevision. It
+// updates after.ContentHash either way.
+func SaveRevision(tx *gorm.DB, apiResponse *utils.APIResponse, before, after *utils.Article) error {
+	after.ContentHash = ArticleContentHash(after)
+	if before.ContentHash == after.ContentHash {
+		return nil
+	}
+	changes := diffArticle(before, after)
+	if len(changes) == 0 {
+		// Only the stored hash was missing
+		return nil
+	}
+
+	previousHash := before.ContentHash
+	if previousHash == "" {
+		previousHash = ArticleContentHash(before)
+	}
+	revision := utils.ArticleRevision{
+		ArticleID:     after.ID,
+		APIResponseID: apiResponse.ID,
+		PreviousHash:  previousHash,
+		ContentHash:   after.ContentHash,
+		Changes:       changes,
+		TitleChanged:  before.Title != after.Title,
+		ChangedAt:     apiResponse.CreatedAt,
+	}
+	if err := tx.Create(&revision).Error; err != nil {
+		return fmt.Errorf("Failed to save article revision: %v", err)
+	}
+	return nil
+}
+
+// GetArticleRevisions lists an article's revisions, newest first
+func GetArticleRevisions(c *gin.Context) {
+	article, ok := findArticle(c)
+	if !ok {
+		return
+	}
+	page, perPage := GetPaginationParams(c)
+
+	var total int64
+	var revisions []utils.ArticleRevision
+	query := utils.DB.Model(&utils.ArticleRevision{}).Where("article_id = ?", article.ID)
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := query.Order("changed_at DESC").Offset((page - 1) * perPage).Limit(perPage).Find(&revisions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"article_id": article.ID,
+		"total":      total,
+		"page":       page,
+		"per_page":   perPage,
+		"revisions":  revisions,
+	})
+}
+
+// GetChangedHeadlines lists recent headline edits across all articles. The
+// window parameter (default 24h) limits how far back to look.
+func GetChangedHeadlines(c *gin.Context) {
+	window, err := time.ParseDuration(c.DefaultQuery("window", "24h"))
+	if err != nil || window <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid window"})
+		return
+	}
+	page, perPage := GetPaginationParams(c)
+
+	var revisions []utils.ArticleRevision
+	err = utils.DB.Where("title_changed AND changed_at >= ?", time.Now().Add(-window)).
+		Order("changed_at DESC").
+		Offset((page - 1) * perPage).
+		Limit(perPage).
+		Find(&revisions).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	articleIDs := make([]uint, 0, len(revisions))
+	for _, revision := range revisions {
+		articleIDs = append(articleIDs, revision.ArticleID)
+	}
+	var articles []utils.Article
+	if err := utils.DB.Preload("Source").Where("id IN ?", articleIDs).Find(&articles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	byID := make(map[uint]*utils.Article, len(articles))
+	for i := range articles {
+		byID[articles[i].ID] = &articles[i]
+	}
+
+	changes := make([]utils.HeadlineChange, 0, len(revisions))
+	for _, revision := range revisions {
+		change := utils.HeadlineChange{
+			RevisionID: revision.ID,
+			ArticleID:  revision.ArticleID,
+			ChangedAt:  revision.ChangedAt,
+		}
+		for _, field := range revision.Changes {
+			if field.Field == "title" {
+				change.OldTitle, change.NewTitle = field.Old, field.New
+			}
+		}
+		if article, ok := byID[revision.ArticleID]; ok {
+			change.URL = article.URL
+			change.Source = article.Source.Name
+		}
+		changes = append(changes, change)
+	}
+
+	c.JSON(http.StatusOK, changes)
+}
@@ -0,0 +1,117 @@
+package endpoints
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"go_news_api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SaveSighting records that an article appeared at the given rank (1-based)
+// in a stored provider response
+func SaveSighting(tx *gorm.DB, apiResponse *utils.APIResponse, article *utils.Article, position int) error {
+	sighting := utils.ArticleSighting{
+		ArticleID:     article.ID,
+		APIResponseID: apiResponse.ID,
+		Provider:      apiResponse.APISource,
+		QueryType:     apiResponse.Type,
+		Query:         apiResponse.Topic,
+		Country:       apiResponse.Country,
+		Language:      apiResponse.Language,
+		Position:      position,
+		SeenAt:        apiResponse.CreatedAt,
+	}
+	if err := tx.Create(&sighting).Error; err != nil {
+		return fmt.Errorf("Failed to save article sighting: %v", err)
+	}
+	return nil
+}
+
+// LoadSightedArticles fills apiResponse.Articles with the articles seen in
+// that fetch, in the order the provider returned them
+func LoadSightedArticles(tx *gorm.DB, apiResponse *utils.APIResponse) error {
+	return tx.Preload("Source").
+		Joins("JOIN article_sightings ON article_sightings.article_id = articles.id AND article_sightings.deleted_at IS NULL").
+		Where("article_sightings.api_response_id = ?", apiResponse.ID).
+		Order("article_sightings.position").
+		Find(&apiResponse.Articles).Error
+}
+
+// findArticle loads an article by its id path parameter, answering 404 when
+// it does not exist
+func findArticle(c *gin.Context, preloads ...string) (*utils.Article, bool) {
+	id, ok := ParseIDParam(c, "id")
+	if !ok {
+		return nil, false
+	}
+
+	query := utils.DB
+	for _, preload := range preloads {
+		query = query.Preload(preload)
+	}
+	var article utils.Article
+	if err := query.First(&article, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Article not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return &article, true
+}
+
+// GetArticle returns one stored article with its source, extracted content
+// and enrichment
+func GetArticle(c *gin.Context) {
+	article, ok := findArticle(c, "Source", "FullContent", "Enrichment")
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, article)
+}
+
+// GetArticleSightings lists the fetches an article appeared in, newest
+// first, together with a per-query summary
+func GetArticleSightings(c *gin.Context) {
+	article, ok := findArticle(c)
+	if !ok {
+		return
+	}
+	page, perPage := GetPaginationParams(c)
+
+	response := utils.ArticleSightingsResponse{
+		ArticleID:   article.ID,
+		FirstSeenAt: article.FirstSeenAt,
+		LastSeenAt:  article.LastSeenAt,
+		Page:        page,
+		PerPage:     perPage,
+	}
+
+	sightings := utils.DB.Model(&utils.ArticleSighting{}).Where("article_id = ?", article.ID)
+	if err := sightings.Count(&response.Total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := sightings.Order("seen_at DESC").Offset((page - 1) * perPage).Limit(perPage).Find(&response.Sightings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := utils.DB.Model(&utils.ArticleSighting{}).
+		Select("provider, query_type, query, COUNT(*) AS sightings, MIN(position) AS best_rank, MIN(seen_at) AS first_seen_at, MAX(seen_at) AS last_seen_at").
+		Where("article_id = ?", article.ID).
+		Group("provider, query_type, query").
+		Order("last_seen_at DESC").
+		Scan(&response.Queries).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
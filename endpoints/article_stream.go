@@ -0,0 +1,261 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go_news_api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamEvent is a new article as sent on the article stream
+type streamEvent struct {
+	ID       uint64
+	Provider string
+	Article  *utils.Article
+	data     []byte
+}
+
+// StreamFilter narrows the articles a stream client receives
+type StreamFilter struct {
+	Keyword  string
+	Source   string
+	Provider string
+	Language string
+}
+
+// matches reports whether an event passes the filter
+func (f StreamFilter) matches(event *streamEvent) bool {
+	article := event.Article
+	if f.Keyword != "" && !containsFold(article.Title+"\n"+article.Description, f.Keyword) {
+		return false
+	}
+	if f.Source != "" && f.Source != article.Source.Domain {
+		return false
+	}
+	if f.Provider != "" && f.Provider != event.Provider {
+		return false
+	}
+	return f.Language == "" || f.Language == article.Language
+}
+
+// streamClient is a connected stream client. events is closed when the
+// client falls too far behind, so that it reconnects and catches up from the
+// replay buffer instead of holding up the others.
+type streamClient struct {
+	filter StreamFilter
+	events chan *streamEvent
+}
+
+// ArticleStream fans newly ingested articles out to stream clients. It keeps
+// the last events in a ring buffer for clients resuming with Last-Event-ID.
+// Publishing never blocks on clients.
+type ArticleStream struct {
+	mu           sync.Mutex
+	nextID       uint64
+	replay       []*streamEvent
+	clients      map[*streamClient]struct{}
+	clientBuffer int
+}
+
+// NewArticleStream creates a stream replaying up to replaySize events and
+// buffering up to clientBuffer events per client. Event ids start from the
+// current time, so ids from before a restart are older than any kept event.
+func NewArticleStream(replaySize, clientBuffer int) *ArticleStream {
+	return &ArticleStream{
+		nextID:       uint64(time.Now().UnixMicro()),
+		replay:       make([]*streamEvent, max(replaySize, 1)),
+		clients:      map[*streamClient]struct{}{},
+		clientBuffer: max(clientBuffer, 1),
+	}
+}
+
+var articleStream *ArticleStream
+
+// StartArticleStream publishes newly ingested articles to the stream clients
+func StartArticleStream() {
+	articleStream = NewArticleStream(
+		utils.GetEnvInt("STREAM_REPLAY_SIZE", 1000),
+		utils.GetEnvInt("STREAM_CLIENT_BUFFER", 64),
+	)
+	OnArticlesIngested(articleStream.Publish)
+}
+
+// Publish adds the new articles among those ingested to the replay buffer
+// and sends them to the matching clients. Clients whose buffer is full are
+// disconnected.
+func (s *ArticleStream) Publish(provider string, articles []IngestedArticle) {
+	var events []*streamEvent
+	for i := range articles {
+		if !articles[i].Created || articles[i].Imported {
+			continue
+		}
+		article := articles[i].Article
+		data, err := json.Marshal(&article)
+		if err != nil {
+			log.Printf("Failed to encode article %d for the stream: %v", article.ID, err)
+			continue
+		}
+		events = append(events, &streamEvent{Provider: strings.ToLower(provider), Article: &article, data: data})
+	}
+	if len(events) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, event := range events {
+		event.ID = s.nextID
+		s.nextID++
+		s.replay[event.ID%uint64(len(s.replay))] = event
+		for client := range s.clients {
+			if !client.filter.matches(event) {
+				continue
+			}
+			select {
+			case client.events <- event:
+			default:
+				delete(s.clients, client)
+				close(client.events)
+			}
+		}
+	}
+}
+
+// Subscribe connects a client. With a lastEventID it also returns the
+// buffered events after that one which pass the filter; if that event is no
+// longer buffered, every buffered event is returned.
+func (s *ArticleStream) Subscribe(filter StreamFilter, lastEventID *uint64) (*streamClient, []*streamEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	client := &streamClient{filter: filter, events: make(chan *streamEvent, s.clientBuffer)}
+	s.clients[client] = struct{}{}
+
+	var backlog []*streamEvent
+	if lastEventID != nil {
+		size := uint64(len(s.replay))
+		start := *lastEventID + 1
+		if s.nextID-start > size {
+			start = s.nextID - size
+		}
+		for id := start; id < s.nextID; id++ {
+			if event := s.replay[id%size]; event != nil && event.ID == id && filter.matches(event) {
+				backlog = append(backlog, event)
+			}
+		}
+	}
+	return client, backlog
+}
+
+// Unsubscribe disconnects a client
+func (s *ArticleStream) Unsubscribe(client *streamClient) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.clients[client]; ok {
+		delete(s.clients, client)
+		close(client.events)
+	}
+}
+
+// parseStreamFilter reads the keyword, source, provider and lang query
+// parameters
+func parseStreamFilter(c *gin.Context) (StreamFilter, error) {
+	filter := StreamFilter{Keyword: strings.TrimSpace(c.Query("keyword")), Provider: strings.ToLower(c.Query("provider"))}
+	if source := c.Query("source"); source != "" {
+		if filter.Source = utils.RegistrableDomain(source); filter.Source == "" {
+			return filter, fmt.Errorf("%w: invalid source domain %q", ErrBadRequest, source)
+		}
+	}
+	var err error
+	filter.Language, err = parseLangParam(c.Request.URL.Query())
+	return filter, err
+}
+
+// writeStreamEvent writes an event in the text/event-stream format
+func writeStreamEvent(w gin.ResponseWriter, event *streamEvent) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: article\ndata: %s\n\n", event.ID, event.data)
+	return err
+}
+
+// StreamArticles streams newly ingested articles as Server-Sent Events,
+// optionally only those matching keyword, source, provider and lang. A client
+// reconnecting with Last-Event-ID (or last_event_id) first receives what it
+// missed, as far as the replay buffer reaches. A comment is sent every
+// STREAM_HEARTBEAT_INTERVAL to keep idle connections open.
+func StreamArticles(c *gin.Context) {
+	if articleStream == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "The article stream is not running"})
+		return
+	}
+	filter, err := parseStreamFilter(c)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+	var lastEventID *uint64
+	raw := c.GetHeader("Last-Event-ID")
+	if raw == "" {
+		raw = c.Query("last_event_id")
+	}
+	if raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid Last-Event-ID %q", raw)})
+			return
+		}
+		lastEventID = &id
+	}
+
+	client, backlog := articleStream.Subscribe(filter, lastEventID)
+	defer articleStream.Unsubscribe(client)
+
+	w := c.Writer
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	heartbeat := utils.GetEnvDuration("STREAM_HEARTBEAT_INTERVAL", 15*time.Second)
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", (5 * time.Second).Milliseconds()); err != nil {
+		return
+	}
+	for _, event := range backlog {
+		if err := writeStreamEvent(w, event); err != nil {
+			return
+		}
+	}
+	w.Flush()
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-client.events:
+			if !ok {
+				// Too slow to keep up; the client reconnects and resumes
+				fmt.Fprint(w, "event: overflow\ndata: {}\n\n")
+				w.Flush()
+				return
+			}
+			if err := writeStreamEvent(w, event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		w.Flush()
+	}
+}
@@ -0,0 +1,36 @@
+package endpoints
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IsAdmin reports whether the request carries ADMIN_TOKEN, either as a bearer
+// token or in the X-Admin-Token header. Without ADMIN_TOKEN nobody is an admin.
+func IsAdmin(c *gin.Context) bool {
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	if adminToken == "" {
+		return false
+	}
+
+	token := c.GetHeader("X-Admin-Token")
+	if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
+}
+
+// RequireAdmin rejects requests that are not authenticated as admin
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !IsAdmin(c) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin token required"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,214 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"go_news_api/nlp"
+	"go_news_api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// minTrainingArticles is the least number of labeled articles worth training
+// a classifier on
+const minTrainingArticles = 50
+
+// categoryBatchSize is how many articles are loaded at a time when training
+// and reclassifying
+const categoryBatchSize = 500
+
+var classifier struct {
+	sync.RWMutex
+	model *nlp.NaiveBayes
+}
+
+// LoadClassifier loads the newest trained category classifier, if any
+func LoadClassifier() error {
+	var stored utils.ClassifierModel
+	err := utils.DB.Order("id DESC").First(&stored).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Println("No category classifier trained yet; articles outside top headlines stay uncategorized")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to load category classifier: %v", err)
+	}
+
+	model := nlp.NewNaiveBayes()
+	if err := json.Unmarshal(stored.Data, model); err != nil {
+		return fmt.Errorf("Failed to decode category classifier %d: %v", stored.ID, err)
+	}
+	classifier.Lock()
+	classifier.model = model
+	classifier.Unlock()
+	log.Printf("Loaded category classifier %d (accuracy %.3f)", stored.ID, stored.Metrics.Accuracy)
+	return nil
+}
+
+// CategorizeArticle labels an article during ingestion. Articles from a
+// top-headlines fetch take its category; the rest are classified when they
+// are new or their text changed. A specific provider category is never
+// replaced by "general".
+func CategorizeArticle(apiResponse *utils.APIResponse, article *utils.Article, changed bool) {
+	if apiResponse.Type == "category" && nlp.IsCategory(apiResponse.Topic) {
+		keep := article.CategorySource == utils.CategoryFromProvider && article.Category != "general" && apiResponse.Topic == "general"
+		if !keep {
+			article.Category = apiResponse.Topic
+			article.CategoryConfidence = 1
+			article.CategorySource = utils.CategoryFromProvider
+		}
+		return
+	}
+	if article.CategorySource == utils.CategoryFromProvider || (article.Category != "" && !changed) {
+		return
+	}
+	classifyArticle(article)
+}
+
+// classifyArticle sets an article's category from the classifier, if one has
+// been trained
+func classifyArticle(article *utils.Article) {
+	classifier.RLock()
+	model := classifier.model
+	classifier.RUnlock()
+	if model == nil {
+		return
+	}
+	article.Category, article.CategoryConfidence = model.Classify(articleText(article))
+	article.CategorySource = utils.CategoryFromClassifier
+}
+
+// RetrainResult reports a retraining run
+type RetrainResult struct {
+	ModelID      uint        `json:"model_id"`
+	Metrics      nlp.Metrics `json:"metrics"`
+	Reclassified int         `json:"reclassified"`
+}
+
+// RetrainClassifier trains a new category classifier on the articles whose
+// category is known from top-headlines fetches. Every fifth article is held
+// out to compute the metrics, then the stored model is trained on all of
+// them and every other article is reclassified with it.
+func RetrainClassifier(ctx context.Context) (*RetrainResult, error) {
+	var train, test []nlp.LabeledText
+	err := eachArticleBatch(ctx, utils.DB.Where("category_source = ?", utils.CategoryFromProvider), func(articles []utils.Article) error {
+		for i := range articles {
+			example := nlp.LabeledText{Label: articles[i].Category, Text: articleText(&articles[i])}
+			if articles[i].ID%5 == 0 {
+				test = append(test, example)
+			} else {
+				train = append(train, example)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load labeled articles: %v", err)
+	}
+	if total := len(train) + len(test); total < minTrainingArticles {
+		return nil, fmt.Errorf("%w: only %d articles have a known category, need at least %d", ErrBadRequest, total, minTrainingArticles)
+	}
+
+	metrics := nlp.TrainNaiveBayes(train).Evaluate(test)
+	metrics.TrainSize = len(train)
+
+	model := nlp.TrainNaiveBayes(append(train, test...))
+	data, err := json.Marshal(model)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode category classifier: %v", err)
+	}
+	stored := utils.ClassifierModel{Data: data, Metrics: metrics}
+	if err := utils.DB.Create(&stored).Error; err != nil {
+		return nil, fmt.Errorf("Failed to save category classifier: %v", err)
+	}
+	classifier.Lock()
+	classifier.model = model
+	classifier.Unlock()
+
+	reclassified, err := reclassifyArticles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &RetrainResult{ModelID: stored.ID, Metrics: metrics, Reclassified: reclassified}, nil
+}
+
+// reclassifyArticles runs the current classifier over every article without
+// a provider category
+func reclassifyArticles(ctx context.Context) (int, error) {
+	count := 0
+	query := utils.DB.Where("category_source IS NULL OR category_source <> ?", utils.CategoryFromProvider)
+	err := eachArticleBatch(ctx, query, func(articles []utils.Article) error {
+		for i := range articles {
+			article := &articles[i]
+			classifyArticle(article)
+			if err := utils.DB.Model(article).Select("category", "category_confidence", "category_source").Updates(article).Error; err != nil {
+				return fmt.Errorf("Failed to save category of article %d: %v", article.ID, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// eachArticleBatch calls fn with the articles matching query, in id order and
+// categoryBatchSize at a time
+func eachArticleBatch(ctx context.Context, query *gorm.DB, fn func(articles []utils.Article) error) error {
+	var lastID uint
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var articles []utils.Article
+		if err := query.Session(&gorm.Session{}).
+			Select("id", "title", "description", "content", "category", "category_source").
+			Where("id > ?", lastID).Order("id").Limit(categoryBatchSize).
+			Find(&articles).Error; err != nil {
+			return err
+		}
+		if len(articles) == 0 {
+			return nil
+		}
+		if err := fn(articles); err != nil {
+			return err
+		}
+		lastID = articles[len(articles)-1].ID
+	}
+}
+
+// RetrainClassifierHandler retrains the category classifier and returns its
+// evaluation metrics
+func RetrainClassifierHandler(c *gin.Context) {
+	result, err := RetrainClassifier(c.Request.Context())
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetClassifier returns the evaluation metrics of the classifier in use
+func GetClassifier(c *gin.Context) {
+	var stored utils.ClassifierModel
+	if err := utils.DB.Order("id DESC").First(&stored).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No category classifier has been trained yet"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"model_id":   stored.ID,
+		"trained_at": stored.CreatedAt,
+		"categories": nlp.Categories,
+		"metrics":    stored.Metrics,
+	})
+}
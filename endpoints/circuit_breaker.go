@@ -0,0 +1,140 @@
+package endpoints
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"go_news_api/utils"
+)
+
+// CircuitState is the state of a CircuitBreaker
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// CircuitBreaker stops calling a provider after FailureThreshold consecutive
+// failures. Once OpenTimeout has passed it lets HalfOpenRequests probe calls
+// through; if they all succeed the circuit closes again, any failure reopens it.
+type CircuitBreaker struct {
+	FailureThreshold int
+	OpenTimeout      time.Duration
+	HalfOpenRequests int
+
+	mu                  sync.Mutex
+	state               CircuitState
+	consecutiveFailures int
+	openedAt            time.Time
+	halfOpenInFlight    int
+	halfOpenSuccesses   int
+}
+
+// CircuitStatus is a point-in-time view of a CircuitBreaker for health output
+type CircuitStatus struct {
+	State               CircuitState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	OpenedAt            *time.Time   `json:"opened_at,omitempty"`
+	RetryAt             *time.Time   `json:"retry_at,omitempty"`
+}
+
+// NewCircuitBreaker creates a closed breaker using the thresholds from the environment
+func NewCircuitBreaker() *CircuitBreaker {
+	return &CircuitBreaker{
+		FailureThreshold: utils.GetEnvInt("BREAKER_FAILURE_THRESHOLD", 5),
+		OpenTimeout:      utils.GetEnvDuration("BREAKER_OPEN_TIMEOUT", time.Minute),
+		HalfOpenRequests: utils.GetEnvInt("BREAKER_HALF_OPEN_REQUESTS", 1),
+		state:            CircuitClosed,
+	}
+}
+
+// Allow reports whether a call may proceed. Every allowed call must be
+// followed by exactly one call to Record.
+func (cb *CircuitBreaker) Allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case CircuitOpen:
+		if time.Since(cb.openedAt) < cb.OpenTimeout {
+			return false
+		}
+		cb.state = CircuitHalfOpen
+		cb.halfOpenInFlight = 0
+		cb.halfOpenSuccesses = 0
+		fallthrough
+	case CircuitHalfOpen:
+		if cb.halfOpenInFlight >= cb.HalfOpenRequests {
+			return false
+		}
+		cb.halfOpenInFlight++
+	}
+	return true
+}
+
+// Record reports the outcome of a call that Allow let through
+func (cb *CircuitBreaker) Record(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if !countsAsFailure(err) {
+		cb.consecutiveFailures = 0
+		if cb.state == CircuitHalfOpen {
+			cb.halfOpenSuccesses++
+			if cb.halfOpenSuccesses >= cb.HalfOpenRequests {
+				cb.state = CircuitClosed
+			}
+		}
+		return
+	}
+
+	cb.consecutiveFailures++
+	if cb.state == CircuitHalfOpen || cb.consecutiveFailures >= cb.FailureThreshold {
+		cb.state = CircuitOpen
+		cb.openedAt = time.Now()
+	}
+}
+
+// State returns the current state, moving an expired open circuit to half-open
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == CircuitOpen && time.Since(cb.openedAt) >= cb.OpenTimeout {
+		return CircuitHalfOpen
+	}
+	return cb.state
+}
+
+// Status returns a snapshot of the breaker
+func (cb *CircuitBreaker) Status() CircuitStatus {
+	state := cb.State()
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	status := CircuitStatus{
+		State:               state,
+		ConsecutiveFailures: cb.consecutiveFailures,
+	}
+	if state != CircuitClosed {
+		openedAt := cb.openedAt
+		retryAt := cb.openedAt.Add(cb.OpenTimeout)
+		status.OpenedAt = &openedAt
+		status.RetryAt = &retryAt
+	}
+	return status
+}
+
+// countsAsFailure decides whether an error says something about the health of
+// the provider. Bad requests and local quota exhaustion do not.
+func countsAsFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, ErrUpstreamUnavailable) ||
+		errors.Is(err, ErrUpstreamTimeout) ||
+		errors.Is(err, ErrInvalidResponse) ||
+		errors.Is(err, ErrUnauthorized)
+}
@@ -0,0 +1,272 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"go_news_api/utils"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// ExtractedContent is the main content of an article page
+type ExtractedContent struct {
+	Text        string
+	WordCount   int
+	LeadImage   string
+	PublishedAt *time.Time
+}
+
+var (
+	positiveHint = regexp.MustCompile(`(?i)article|body|content|entry|main|page|post|story|text|blog`)
+	negativeHint = regexp.MustCompile(`(?i)comment|combx|contact|foot|footer|footnote|masthead|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|social|subscribe|tags|taboola|tool|widget|newsletter|cookie|banner|advert|nav|menu`)
+	whitespace   = regexp.MustCompile(`\s+`)
+)
+
+// unwantedSelector matches elements that never hold article text
+const unwantedSelector = "script, style, noscript, iframe, svg, form, button, input, select, textarea, nav, header, footer, aside, [role=navigation], [role=banner], [role=contentinfo], [aria-hidden=true]"
+
+// ExtractContent runs a readability-style extractor over an HTML page and
+// returns the main article text, lead image and publish date. It performs no
+// I/O beyond reading r, so it can be run against stored fixtures.
+func ExtractContent(r io.Reader, pageURL string) (*ExtractedContent, error) {
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return nil, err
+	}
+	base, _ := url.Parse(pageURL)
+
+	// Metadata is read before unwanted elements (like <header>) are stripped
+	content := &ExtractedContent{
+		LeadImage:   metaContent(doc, "og:image", "twitter:image", "twitter:image:src"),
+		PublishedAt: detectPublishedAt(doc),
+	}
+
+	doc.Find(unwantedSelector).Remove()
+
+	main := findMainContent(doc)
+	if main != nil {
+		content.Text = collectText(main)
+		if content.LeadImage == "" {
+			if src, ok := main.Find("img[src]").First().Attr("src"); ok {
+				content.LeadImage = src
+			}
+		}
+	}
+	content.LeadImage = resolveURL(base, content.LeadImage)
+	content.WordCount = len(strings.Fields(content.Text))
+
+	return content, nil
+}
+
+// findMainContent scores block containers by the paragraphs they hold and
+// returns the best one
+func findMainContent(doc *goquery.Document) *goquery.Selection {
+	scores := map[*html.Node]float64{}
+	nodes := map[*html.Node]*goquery.Selection{}
+
+	addScore := func(s *goquery.Selection, score float64) {
+		if s.Length() == 0 {
+			return
+		}
+		node := s.Get(0)
+		if _, ok := scores[node]; !ok {
+			scores[node] = initialScore(s)
+			nodes[node] = s
+		}
+		scores[node] += score
+	}
+
+	doc.Find("p, pre, td, blockquote").Each(func(i int, p *goquery.Selection) {
+		text := normalizeSpace(p.Text())
+		if len(text) < 25 {
+			return
+		}
+		score := 1 + float64(strings.Count(text, ",")) + math.Min(float64(len(text))/100, 3)
+		addScore(p.Parent(), score)
+		addScore(p.Parent().Parent(), score/2)
+	})
+
+	var best *goquery.Selection
+	bestScore := 0.0
+	for node, score := range scores {
+		s := nodes[node]
+		score *= 1 - linkDensity(s)
+		if score > bestScore {
+			best, bestScore = s, score
+		}
+	}
+
+	if best == nil {
+		if article := doc.Find("article").First(); article.Length() > 0 {
+			return article
+		}
+		body := doc.Find("body")
+		if body.Length() == 0 {
+			return nil
+		}
+		return body
+	}
+	return best
+}
+
+// initialScore weighs a candidate by its tag and class/id hints
+func initialScore(s *goquery.Selection) float64 {
+	score := 0.0
+	switch goquery.NodeName(s) {
+	case "article":
+		score += 10
+	case "div", "section", "main":
+		score += 5
+	case "pre", "td", "blockquote":
+		score += 3
+	case "ol", "ul", "dl", "dd", "dt", "li", "form":
+		score -= 3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		score -= 5
+	}
+
+	hints := s.AttrOr("class", "") + " " + s.AttrOr("id", "")
+	if negativeHint.MatchString(hints) {
+		score -= 25
+	}
+	if positiveHint.MatchString(hints) {
+		score += 25
+	}
+	return score
+}
+
+// linkDensity is the share of a node's text that sits inside links
+func linkDensity(s *goquery.Selection) float64 {
+	textLength := len(normalizeSpace(s.Text()))
+	if textLength == 0 {
+		return 0
+	}
+	linkLength := 0
+	s.Find("a").Each(func(i int, a *goquery.Selection) {
+		linkLength += len(normalizeSpace(a.Text()))
+	})
+	return float64(linkLength) / float64(textLength)
+}
+
+// collectText joins the text blocks of the main content with blank lines
+func collectText(main *goquery.Selection) string {
+	var blocks []string
+	main.Find("p, h2, h3, h4, li, blockquote, pre").Each(func(i int, s *goquery.Selection) {
+		// Nested blocks are picked up through their parent
+		if s.ParentsFiltered("p, li, blockquote, pre").Length() > 0 {
+			return
+		}
+		text := normalizeSpace(s.Text())
+		if text == "" || (goquery.NodeName(s) == "li" && len(text) < 40) {
+			return
+		}
+		if linkDensity(s) > 0.5 {
+			return
+		}
+		blocks = append(blocks, text)
+	})
+	if len(blocks) == 0 {
+		return normalizeSpace(main.Text())
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+// detectPublishedAt looks for a publish date in meta tags, <time> elements and
+// JSON-LD
+func detectPublishedAt(doc *goquery.Document) *time.Time {
+	candidates := []string{
+		metaContent(doc, "article:published_time", "og:published_time", "datePublished", "pubdate", "publishdate", "publish-date", "date", "dc.date.issued", "dc.date", "sailthru.date", "parsely-pub-date"),
+		doc.Find("[itemprop=datePublished]").First().AttrOr("content", doc.Find("[itemprop=datePublished]").First().AttrOr("datetime", "")),
+		jsonLDField(doc, "datePublished"),
+		doc.Find("time[datetime]").First().AttrOr("datetime", ""),
+	}
+	for _, candidate := range candidates {
+		if t, ok := parseLooseDate(candidate); ok {
+			return &t
+		}
+	}
+	return nil
+}
+
+// metaContent returns the first non-empty <meta> content for the given
+// property or name values
+func metaContent(doc *goquery.Document, keys ...string) string {
+	for _, key := range keys {
+		for _, attr := range []string{"property", "name", "itemprop"} {
+			var value string
+			doc.Find("meta[" + attr + "]").EachWithBreak(func(i int, s *goquery.Selection) bool {
+				if strings.EqualFold(s.AttrOr(attr, ""), key) {
+					value = strings.TrimSpace(s.AttrOr("content", ""))
+				}
+				return value == ""
+			})
+			if value != "" {
+				return value
+			}
+		}
+	}
+	return ""
+}
+
+// jsonLDField returns a string field from the first JSON-LD object that has it
+func jsonLDField(doc *goquery.Document, field string) string {
+	var value string
+	doc.Find(`script[type="application/ld+json"]`).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		var data interface{}
+		if err := json.Unmarshal([]byte(s.Text()), &data); err != nil {
+			return true
+		}
+		value = findJSONLDString(data, field)
+		return value == ""
+	})
+	return value
+}
+
+func findJSONLDString(data interface{}, field string) string {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		if s, ok := v[field].(string); ok {
+			return s
+		}
+		if graph, ok := v["@graph"]; ok {
+			return findJSONLDString(graph, field)
+		}
+	case []interface{}:
+		for _, item := range v {
+			if s := findJSONLDString(item, field); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// parseLooseDate parses a date found in page metadata
+func parseLooseDate(value string) (time.Time, bool) {
+	if t := utils.ParsePublishedAt(value); t != nil {
+		return *t, true
+	}
+	return time.Time{}, false
+}
+
+func resolveURL(base *url.URL, ref string) string {
+	if ref == "" || base == nil {
+		return ref
+	}
+	u, err := base.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return u.String()
+}
+
+func normalizeSpace(s string) string {
+	return strings.TrimSpace(whitespace.ReplaceAllString(s, " "))
+}
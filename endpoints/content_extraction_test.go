@@ -0,0 +1,253 @@
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExtractContent(t *testing.T) {
+	tests := []struct {
+		fixture     string
+		pageURL     string
+		contains    []string
+		excludes    []string
+		wordCount   int
+		leadImage   string
+		publishedAt string
+	}{
+		{
+			fixture: "article_opengraph.html",
+			pageURL: "https://gazette.example/city/tram-line",
+			contains: []string{
+				"The city council voted on Tuesday",
+				"Years of debate",
+				"a direct connection to the centre.",
+			},
+			excludes:    []string{"newsletter", "Copyright", "Home", "window.analytics"},
+			wordCount:   75,
+			leadImage:   "https://gazette.example/images/tram-line.jpg",
+			publishedAt: "2024-05-01T06:30:00Z",
+		},
+		{
+			fixture: "article_jsonld.html",
+			pageURL: "https://weather.example/news/heatwave",
+			contains: []string{
+				"Temperatures above 35 degrees",
+				"check on elderly neighbours.",
+			},
+			excludes:    []string{"Storms to follow", "stay cool"},
+			wordCount:   37,
+			leadImage:   "https://weather.example/news/media/heatwave.png",
+			publishedAt: "2024-07-15T06:00:00Z",
+		},
+		{
+			fixture: "article_time.html",
+			pageURL: "https://library.example/reopening",
+			contains: []string{
+				"The central library reopened its doors",
+				"almost entirely new.",
+			},
+			wordCount:   50,
+			publishedAt: "2023-11-20T00:00:00Z",
+		},
+		{
+			fixture: "article_empty.html",
+			pageURL: "https://example.com/missing",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.fixture, func(t *testing.T) {
+			file, err := os.Open(filepath.Join("testdata", test.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+
+			content, err := ExtractContent(file, test.pageURL)
+			if err != nil {
+				t.Fatalf("ExtractContent: %v", err)
+			}
+			for _, want := range test.contains {
+				if !strings.Contains(content.Text, want) {
+					t.Errorf("text does not contain %q:\n%s", want, content.Text)
+				}
+			}
+			for _, unwanted := range test.excludes {
+				if strings.Contains(content.Text, unwanted) {
+					t.Errorf("text contains %q:\n%s", unwanted, content.Text)
+				}
+			}
+			if content.WordCount != test.wordCount {
+				t.Errorf("word count = %d, want %d", content.WordCount, test.wordCount)
+			}
+			if content.LeadImage != test.leadImage {
+				t.Errorf("lead image = %q, want %q", content.LeadImage, test.leadImage)
+			}
+			var publishedAt string
+			if content.PublishedAt != nil {
+				publishedAt = content.PublishedAt.Format(time.RFC3339)
+			}
+			if publishedAt != test.publishedAt {
+				t.Errorf("published at = %q, want %q", publishedAt, test.publishedAt)
+			}
+		})
+	}
+}
+
+func TestRobotsAllowed(t *testing.T) {
+	rules := parseRobots([]byte(`
+User-agent: *
+Disallow: /
+
+User-agent: Googlebot
+User-agent: go_news_api
+Disallow: /private/
+Disallow: /*.pdf$
+Allow: /private/press/
+Crawl-delay: 2.5
+`))
+
+	if rules.crawlDelay != 2500*time.Millisecond {
+		t.Errorf("crawl delay = %s, want 2.5s", rules.crawlDelay)
+	}
+	tests := []struct {
+		path    string
+		allowed bool
+	}{
+		{"/", true},
+		{"/news/story", true},
+		{"/private/", false},
+		{"/private/drafts/1", false},
+		{"/private/press/release", true},
+		{"/files/report.pdf", false},
+		{"/files/report.pdf?download=1", true},
+	}
+	for _, test := range tests {
+		if allowed := rules.Allowed(test.path); allowed != test.allowed {
+			t.Errorf("Allowed(%q) = %v, want %v", test.path, allowed, test.allowed)
+		}
+	}
+
+	if parseRobots([]byte("User-agent: *\nDisallow: /\n")).Allowed("/news") {
+		t.Error("the wildcard group should apply when ours is missing")
+	}
+	if !parseRobots(nil).Allowed("/news") {
+		t.Error("an empty robots.txt should allow everything")
+	}
+	if !parseRobots([]byte("User-agent: news\nDisallow: /\n")).Allowed("/news") {
+		t.Error("a group for a substring of our token should not apply to us")
+	}
+	if parseRobots([]byte("User-agent: Go_News_API\nDisallow: /\n")).Allowed("/news") {
+		t.Error("our group should match case-insensitively")
+	}
+}
+
+func TestPageFetcherRobots(t *testing.T) {
+	var pages atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprint(w, "User-agent: *\nDisallow: /members/\n")
+		default:
+			pages.Add(1)
+			fmt.Fprint(w, "<html><body><p>Hello</p></body></html>")
+		}
+	}))
+	defer server.Close()
+
+	fetcher := NewPageFetcher()
+	fetcher.MinDomainInterval = 0
+	fetcher.client.MaxRetries = 0
+	ctx := context.Background()
+
+	if _, err := fetcher.Fetch(ctx, server.URL+"/members/story"); !errors.Is(err, ErrDisallowedByRobots) {
+		t.Errorf("disallowed page: err = %v, want ErrDisallowedByRobots", err)
+	}
+	if pages.Load() != 0 {
+		t.Errorf("disallowed page was requested %d times", pages.Load())
+	}
+	resp, err := fetcher.Fetch(ctx, server.URL+"/news/story")
+	if err != nil {
+		t.Fatalf("allowed page: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || pages.Load() != 1 {
+		t.Errorf("allowed page: status %d after %d requests", resp.StatusCode, pages.Load())
+	}
+}
+
+func TestPageFetcherForbiddenRobots(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		t.Errorf("unexpected request for %s", r.URL.Path)
+	}))
+	defer server.Close()
+
+	fetcher := NewPageFetcher()
+	fetcher.MinDomainInterval = 0
+	fetcher.client.MaxRetries = 0
+	if _, err := fetcher.Fetch(context.Background(), server.URL+"/news/story"); !errors.Is(err, ErrDisallowedByRobots) {
+		t.Errorf("err = %v, want ErrDisallowedByRobots", err)
+	}
+}
+
+func TestPageFetcherUnreachableRobots(t *testing.T) {
+	var robotsRequests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			robotsRequests.Add(1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		t.Errorf("unexpected request for %s", r.URL.Path)
+	}))
+	defer server.Close()
+
+	fetcher := NewPageFetcher()
+	fetcher.MinDomainInterval = 0
+	fetcher.client.MaxRetries = 0
+	for i := 0; i < 2; i++ {
+		_, err := fetcher.Fetch(context.Background(), server.URL+"/news/story")
+		if !errors.Is(err, ErrUpstreamUnavailable) || errors.Is(err, ErrDisallowedByRobots) {
+			t.Errorf("err = %v, want ErrUpstreamUnavailable", err)
+		}
+	}
+	if n := robotsRequests.Load(); n != 1 {
+		t.Errorf("robots.txt fetched %d times, want it cached for RobotsErrorTTL", n)
+	}
+}
+
+func TestPageFetcherCancelledRobots(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			fmt.Fprint(w, "User-agent: *\nDisallow: /\n")
+			return
+		}
+		t.Errorf("unexpected request for %s", r.URL.Path)
+	}))
+	defer server.Close()
+
+	fetcher := NewPageFetcher()
+	fetcher.MinDomainInterval = 0
+	fetcher.client.MaxRetries = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := fetcher.Fetch(ctx, server.URL+"/news/story"); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if _, err := fetcher.Fetch(context.Background(), server.URL+"/news/story"); !errors.Is(err, ErrDisallowedByRobots) {
+		t.Errorf("err = %v, want ErrDisallowedByRobots after the cancelled fetch", err)
+	}
+}
@@ -0,0 +1,281 @@
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go_news_api/nlp"
+	"go_news_api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	gazetteerOnce   sync.Once
+	entityGazetteer *nlp.Gazetteer
+)
+
+// gazetteer returns the built-in gazetteer extended with the files in
+// ENTITY_GAZETTEER_DIR, loaded on first use
+func gazetteer() *nlp.Gazetteer {
+	gazetteerOnce.Do(func() {
+		entityGazetteer = nlp.NewGazetteer()
+		if dir := os.Getenv("ENTITY_GAZETTEER_DIR"); dir != "" {
+			if err := entityGazetteer.LoadDir(dir); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}
+	})
+	return entityGazetteer
+}
+
+// entityText is the text entities are extracted from: the extracted page
+// text when there is one, the provider's snippet otherwise
+func entityText(tx *gorm.DB, article *utils.Article) string {
+	var content utils.ArticleContent
+	err := tx.Select("full_text").Where("article_id = ? AND status IN ?", article.ID, extractedStatuses).First(&content).Error
+	if err == nil && content.FullText != "" {
+		return article.Title + "\n" + article.Description + "\n" + content.FullText
+	}
+	return articleText(article)
+}
+
+// SaveEntities replaces the entities recorded for an article with those
+// found in text
+func SaveEntities(tx *gorm.DB, article *utils.Article, text string) error {
+	if err := tx.Where("article_id = ?", article.ID).Delete(&utils.ArticleEntity{}).Error; err != nil {
+		return fmt.Errorf("Failed to clear article entities: %v", err)
+	}
+	for _, mention := range nlp.ExtractEntities(text, gazetteer()) {
+		// Insert or keep the existing entity, then read its id: concurrent
+		// ingestion may create the same entity at any moment
+		entity := utils.Entity{Name: mention.Name, NormalizedName: strings.ToLower(mention.Name), Type: mention.Type}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "normalized_name"}, {Name: "type"}},
+			DoNothing: true,
+		}).Create(&entity).Error; err != nil {
+			return fmt.Errorf("Failed to save entity: %v", err)
+		}
+		if entity.ID == 0 {
+			if err := tx.Where("normalized_name = ? AND type = ?", entity.NormalizedName, entity.Type).
+				First(&entity).Error; err != nil {
+				return fmt.Errorf("Failed to load entity: %v", err)
+			}
+		}
+		link := utils.ArticleEntity{ArticleID: article.ID, EntityID: entity.ID, Mentions: mention.Count}
+		if err := tx.Create(&link).Error; err != nil {
+			return fmt.Errorf("Failed to link entity to article: %v", err)
+		}
+	}
+	return nil
+}
+
+// ExtractMissingEntities runs entity extraction over every article that has
+// none recorded yet, such as those stored before extraction existed
+func ExtractMissingEntities(ctx context.Context) (int, error) {
+	count := 0
+	query := utils.DB.Where("NOT EXISTS (SELECT 1 FROM article_entities WHERE article_entities.article_id = articles.id)")
+	err := eachArticleBatch(ctx, query, func(articles []utils.Article) error {
+		for i := range articles {
+			article := &articles[i]
+			if err := SaveEntities(utils.DB, article, entityText(utils.DB, article)); err != nil {
+				return fmt.Errorf("article %d: %v", article.ID, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// entitySummaries selects entities with their article and mention counts and
+// the publish dates of their first and last article
+func entitySummaries() *gorm.DB {
+	return utils.DB.Table("entities").
+		Select(`entities.*,
+			COUNT(articles.id) AS article_count,
+			COALESCE(SUM(article_entities.mentions), 0) AS mention_count,
+			MIN(articles.published_at) AS first_seen_at,
+			MAX(articles.published_at) AS last_seen_at`).
+		Joins("LEFT JOIN article_entities ON article_entities.entity_id = entities.id").
+		Joins("LEFT JOIN articles ON articles.id = article_entities.article_id AND articles.deleted_at IS NULL").
+		Where("entities.deleted_at IS NULL").
+		Group("entities.id")
+}
+
+// parseEntityType reads the type query parameter
+func parseEntityType(c *gin.Context) (string, error) {
+	entityType := strings.ToLower(c.Query("type"))
+	if entityType == "" {
+		return "", nil
+	}
+	for _, known := range nlp.EntityTypes {
+		if entityType == known {
+			return entityType, nil
+		}
+	}
+	return "", fmt.Errorf("%w: type must be one of %s", ErrBadRequest, strings.Join(nlp.EntityTypes, ", "))
+}
+
+// ListEntities lists entities by the number of articles mentioning them. q
+// searches names and type restricts to one entity type.
+func ListEntities(c *gin.Context) {
+	page, perPage := GetPaginationParams(c)
+	entityType, err := parseEntityType(c)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	filter := func(query *gorm.DB) *gorm.DB {
+		if q := strings.TrimSpace(c.Query("q")); q != "" {
+			query = query.Where("entities.normalized_name LIKE ?", "%"+strings.ToLower(q)+"%")
+		}
+		if entityType != "" {
+			query = query.Where("entities.type = ?", entityType)
+		}
+		return query
+	}
+
+	var total int64
+	if err := filter(utils.DB.Model(&utils.Entity{})).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var entities []utils.EntitySummary
+	if err := filter(entitySummaries()).
+		Order("article_count DESC, entities.name").
+		Offset((page - 1) * perPage).Limit(perPage).
+		Scan(&entities).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":    total,
+		"page":     page,
+		"per_page": perPage,
+		"entities": entities,
+	})
+}
+
+// findEntity loads an entity summary by its id path parameter
+func findEntity(c *gin.Context) (*utils.EntitySummary, bool) {
+	id, ok := ParseIDParam(c, "id")
+	if !ok {
+		return nil, false
+	}
+	var entity utils.EntitySummary
+	result := entitySummaries().Where("entities.id = ?", id).Scan(&entity)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return nil, false
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Entity not found"})
+		return nil, false
+	}
+	return &entity, true
+}
+
+// GetEntity returns an entity with the entities most often mentioned in the
+// same articles (limit, default 20)
+func GetEntity(c *gin.Context) {
+	entity, ok := findEntity(c)
+	if !ok {
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+		return
+	}
+
+	var coMentioned []utils.CoMentionedEntity
+	err = utils.DB.Table("article_entities AS a").
+		Select("entities.*, COUNT(*) AS shared_articles").
+		Joins("JOIN article_entities AS b ON b.article_id = a.article_id AND b.entity_id <> a.entity_id").
+		Joins("JOIN entities ON entities.id = b.entity_id AND entities.deleted_at IS NULL").
+		Where("a.entity_id = ?", entity.ID).
+		Group("entities.id").
+		Order("shared_articles DESC, entities.name").
+		Limit(limit).
+		Scan(&coMentioned).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entity":       entity,
+		"co_mentioned": coMentioned,
+	})
+}
+
+// GetEntityArticles lists the articles mentioning an entity, newest first
+// unless the filter says otherwise, with the number published per day
+func GetEntityArticles(c *gin.Context) {
+	entity, ok := findEntity(c)
+	if !ok {
+		return
+	}
+	filter, err := ParseArticleFilter(c.Request.URL.Query())
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+	page, perPage := GetPaginationParams(c)
+
+	// Counting and grouping must not carry the ordering
+	unordered := filter
+	unordered.Sort = SortRelevance
+	mentioning := func(filter ArticleFilter) *gorm.DB {
+		query := utils.DB.Model(&utils.Article{}).
+			Joins("JOIN article_entities ON article_entities.article_id = articles.id").
+			Where("article_entities.entity_id = ?", entity.ID)
+		return filter.Apply(query)
+	}
+
+	var total int64
+	if err := mentioning(unordered).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var timeline []utils.EntityTimelinePoint
+	if err := mentioning(unordered).
+		Select("TO_CHAR(DATE(articles.published_at), 'YYYY-MM-DD') AS day, COUNT(*) AS articles").
+		Where("articles.published_at IS NOT NULL").
+		Group("day").Order("day").
+		Scan(&timeline).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := mentioning(filter).Preload("Source")
+	if filter.Sort != SortPublishedAt {
+		query = query.Order("articles.published_at DESC NULLS LAST")
+	}
+	var articles []utils.Article
+	if err := query.Order("articles.id DESC").Offset((page - 1) * perPage).Limit(perPage).Find(&articles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entity":   entity,
+		"total":    total,
+		"page":     page,
+		"per_page": perPage,
+		"timeline": timeline,
+		"articles": articles,
+	})
+}
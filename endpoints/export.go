@@ -0,0 +1,444 @@
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go_news_api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// exportFlushRows is how often a streamed export is flushed to the client
+const exportFlushRows = 500
+
+// ExportParams selects the articles of an export and its format. The filters
+// are those of the listing endpoints: q searches as /news-by-keyword does.
+type ExportParams struct {
+	Format   string
+	Query    string
+	Language string
+	Filter   ArticleFilter
+	Source   string
+	Keyword  string
+	Provider string
+	FullText bool
+}
+
+// ParseExportParams reads format, q, lang, category, from, to, source,
+// keyword, provider and full_text from a query string
+func ParseExportParams(values url.Values) (ExportParams, error) {
+	params := ExportParams{
+		Format:   values.Get("format"),
+		Query:    strings.TrimSpace(values.Get("q")),
+		Keyword:  normalizeKeyword(values.Get("keyword")),
+		Provider: strings.ToLower(values.Get("provider")),
+		FullText: values.Get("full_text") == "true",
+	}
+	if params.Format == "" {
+		params.Format = ExportNDJSON
+	}
+	if _, ok := exportContentTypes[params.Format]; !ok {
+		return params, fmt.Errorf("%w: format must be %s, %s or %s", ErrBadRequest, ExportNDJSON, ExportCSV, ExportParquet)
+	}
+
+	var err error
+	if params.Filter, err = ParseArticleFilter(values); err != nil {
+		return params, err
+	}
+	params.Filter.Sort = ""
+	if params.Language, err = parseLangParam(values); err != nil {
+		return params, err
+	}
+	if source := values.Get("source"); source != "" {
+		if params.Source = utils.RegistrableDomain(source); params.Source == "" {
+			return params, fmt.Errorf("%w: invalid source domain %q", ErrBadRequest, source)
+		}
+	}
+	return params, nil
+}
+
+// exportQuery selects the articles of an export
+func exportQuery(params ExportParams) *gorm.DB {
+	query := utils.DB.Model(&utils.Article{}).
+		Joins("LEFT JOIN sources ON sources.id = articles.source_id").
+		Joins("LEFT JOIN api_responses ON api_responses.id = articles.api_response_id")
+	if params.Query != "" {
+		query = whereSearch(query, PrepareSearchQuery(params.Query), params.Language)
+	} else if params.Language != "" {
+		query = query.Where("articles.language = ?", params.Language)
+	}
+	query = params.Filter.Apply(query)
+	if params.Source != "" {
+		query = query.Where("sources.domain = ?", params.Source)
+	}
+	if params.Keyword != "" {
+		query = query.Where(`EXISTS (SELECT 1 FROM article_keywords
+            JOIN keywords ON keywords.id = article_keywords.keyword_id
+            WHERE article_keywords.article_id = articles.id AND keywords.word = ?)`, params.Keyword)
+	}
+	if params.Provider != "" {
+		query = query.Where(`EXISTS (SELECT 1 FROM article_sightings
+            WHERE article_sightings.article_id = articles.id AND article_sightings.deleted_at IS NULL
+            AND article_sightings.provider = ?)`, params.Provider)
+	}
+	return query
+}
+
+// exportColumnsSQL selects the columns of utils.ExportedArticle
+const exportColumnsSQL = `articles.id, articles.url, articles.title, articles.description, articles.author,
+    articles.content, articles.summary, articles.url_to_image,
+    articles.published_at, articles.first_seen_at, articles.last_seen_at,
+    articles.language, articles.category, articles.category_source,
+    articles.source_id, COALESCE(sources.name, '') AS source_name, COALESCE(sources.domain, '') AS source_domain,
+    COALESCE(api_responses.api_source, '') AS provider, COALESCE(api_responses.type, '') AS query_type,
+    COALESCE(api_responses.topic, '') AS query,
+    articles.requested_country, articles.requested_language,
+    (SELECT COUNT(*) FROM article_sightings
+        WHERE article_sightings.article_id = articles.id AND article_sightings.deleted_at IS NULL) AS sightings,
+    COALESCE((SELECT string_agg(keywords.word, ' ' ORDER BY keywords.word) FROM article_keywords
+        JOIN keywords ON keywords.id = article_keywords.keyword_id
+        WHERE article_keywords.article_id = articles.id), '') AS keywords`
+
+// CountExport counts the articles an export would write
+func CountExport(params ExportParams) (int64, error) {
+	var count int64
+	if err := exportQuery(params).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("Failed to count articles: %v", err)
+	}
+	return count, nil
+}
+
+// WriteExport writes the articles selected by params to w in id order. Rows
+// are read from a database cursor, so memory use does not grow with the
+// export. If w can be flushed, it is flushed every few hundred rows.
+func WriteExport(ctx context.Context, params ExportParams, w io.Writer) (int64, error) {
+	query := exportQuery(params).WithContext(ctx)
+	columns := exportColumnsSQL
+	if params.FullText {
+		query = query.Joins("LEFT JOIN article_contents ON article_contents.article_id = articles.id AND article_contents.deleted_at IS NULL")
+		columns += ", COALESCE(article_contents.full_text, '') AS full_text"
+	}
+	rows, err := query.Select(columns).Order("articles.id").Rows()
+	if err != nil {
+		return 0, fmt.Errorf("Failed to query articles: %v", err)
+	}
+	defer rows.Close()
+
+	exporter, err := newArticleExporter(params.Format, w, params.FullText)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to start export: %v", err)
+	}
+	flusher, _ := w.(http.Flusher)
+
+	var count int64
+	for rows.Next() {
+		var row utils.ExportedArticle
+		if err := utils.DB.ScanRows(rows, &row); err != nil {
+			return count, fmt.Errorf("Failed to read article: %v", err)
+		}
+		if err := exporter.Write(&row); err != nil {
+			return count, fmt.Errorf("Failed to write article %d: %v", row.ID, err)
+		}
+		count++
+		if flusher != nil && count%exportFlushRows == 0 {
+			if csv, ok := exporter.(*csvExporter); ok {
+				if err := csv.Flush(); err != nil {
+					return count, err
+				}
+			}
+			flusher.Flush()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return count, fmt.Errorf("Failed to read articles: %v", err)
+	}
+	if err := exporter.Close(); err != nil {
+		return count, fmt.Errorf("Failed to finish export: %v", err)
+	}
+	return count, nil
+}
+
+// exportFilename is the download name of an export
+func exportFilename(format string) string {
+	return "articles." + format
+}
+
+// ExportArticles exports the articles matching the filters as NDJSON, CSV
+// or Parquet. Exports of up to EXPORT_SYNC_LIMIT articles are streamed;
+// larger ones, or any with async=true, are queued as a job whose file can be
+// downloaded when done. Only admins can queue jobs, and no more than
+// EXPORT_MAX_PENDING_JOBS can be queued or running at once.
+func ExportArticles(c *gin.Context) {
+	params, err := ParseExportParams(c.Request.URL.Query())
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+	count, err := CountExport(params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.Query("async") == "true" || count > int64(utils.GetEnvInt("EXPORT_SYNC_LIMIT", 50000)) {
+		if !IsAdmin(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin token required for background exports; narrow the export to stream it"})
+			return
+		}
+		if exportJobs.dir == "" {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Export jobs are not running; narrow the export to stream it"})
+			return
+		}
+		var pending int64
+		if err := utils.DB.Model(&utils.ExportJob{}).Where("status IN ?", []string{utils.ExportQueued, utils.ExportRunning}).Count(&pending).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if pending >= int64(utils.GetEnvInt("EXPORT_MAX_PENDING_JOBS", 5)) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many exports are queued; try again later"})
+			return
+		}
+		query := c.Request.URL.Query()
+		query.Del("async")
+		job := utils.ExportJob{Format: params.Format, Query: query.Encode(), Status: utils.ExportQueued}
+		if err := utils.DB.Create(&job).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		exportJobs.enqueue(job.ID)
+		c.JSON(http.StatusAccepted, gin.H{
+			"job":      job,
+			"articles": count,
+			"status":   fmt.Sprintf("/api/v1/admin/exports/%d", job.ID),
+		})
+		return
+	}
+
+	c.Header("Content-Type", exportContentTypes[params.Format])
+	c.Header("Content-Disposition", `attachment; filename="`+exportFilename(params.Format)+`"`)
+	c.Header("X-Total-Count", fmt.Sprint(count))
+	c.Status(http.StatusOK)
+	if _, err := WriteExport(c.Request.Context(), params, c.Writer); err != nil {
+		log.Printf("Export failed: %v", err)
+		abortResponse(c)
+	}
+}
+
+// abortResponse drops the connection of a response whose status is already
+// sent, so that the client does not take a truncated body for complete
+func abortResponse(c *gin.Context) {
+	if conn, _, err := c.Writer.Hijack(); err == nil {
+		conn.Close()
+	}
+}
+
+// exportJobRunner runs queued export jobs in the background
+type exportJobRunner struct {
+	dir   string
+	ttl   time.Duration
+	queue chan uint
+}
+
+var exportJobs = &exportJobRunner{queue: make(chan uint, 1000)}
+
+// enqueue schedules a job. If the queue is full the job stays queued in the
+// database and is picked up on the next start.
+func (r *exportJobRunner) enqueue(id uint) {
+	select {
+	case r.queue <- id:
+	default:
+		log.Printf("Export queue is full, job %d waits for a restart", id)
+	}
+}
+
+// StartExportJobs runs export jobs in the background, EXPORT_JOB_CONCURRENCY
+// at a time, writing their files to EXPORT_DIR. Jobs interrupted by a restart
+// are run again, and files are deleted EXPORT_TTL after they are written.
+func StartExportJobs(ctx context.Context) {
+	dir := os.Getenv("EXPORT_DIR")
+	if dir == "" {
+		dir = "exports"
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Printf("Warning: export jobs are disabled: %v", err)
+		return
+	}
+	exportJobs.dir = dir
+	exportJobs.ttl = utils.GetEnvDuration("EXPORT_TTL", 24*time.Hour)
+
+	var pending []utils.ExportJob
+	if err := utils.DB.Where("status IN ?", []string{utils.ExportQueued, utils.ExportRunning}).Order("id").Find(&pending).Error; err != nil {
+		log.Printf("Failed to load pending export jobs: %v", err)
+	}
+	for _, job := range pending {
+		exportJobs.enqueue(job.ID)
+	}
+
+	for i := 0; i < utils.GetEnvInt("EXPORT_JOB_CONCURRENCY", 1); i++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case id := <-exportJobs.queue:
+					exportJobs.run(ctx, id)
+				}
+			}
+		}()
+	}
+	go exportJobs.expire(ctx)
+}
+
+// run writes the file of an export job
+func (r *exportJobRunner) run(ctx context.Context, id uint) {
+	var job utils.ExportJob
+	if err := utils.DB.First(&job, id).Error; err != nil {
+		log.Printf("Failed to load export job %d: %v", id, err)
+		return
+	}
+	now := time.Now()
+	job.Status = utils.ExportRunning
+	job.StartedAt = &now
+	job.Path = filepath.Join(r.dir, fmt.Sprintf("export-%d.%s", job.ID, job.Format))
+	if err := utils.DB.Save(&job).Error; err != nil {
+		log.Printf("Failed to start export job %d: %v", id, err)
+		return
+	}
+
+	rows, size, err := r.write(ctx, &job)
+	finished := time.Now()
+	job.FinishedAt = &finished
+	job.Rows = rows
+	if err != nil {
+		job.Status = utils.ExportFailed
+		job.Error = err.Error()
+		os.Remove(job.Path + ".part")
+	} else {
+		expires := finished.Add(r.ttl)
+		job.Status = utils.ExportDone
+		job.Size = size
+		job.ExpiresAt = &expires
+	}
+	if err := utils.DB.Save(&job).Error; err != nil {
+		log.Printf("Failed to record export job %d: %v", id, err)
+	}
+}
+
+// write exports a job's articles to a temporary file, renamed into place
+// once complete
+func (r *exportJobRunner) write(ctx context.Context, job *utils.ExportJob) (int64, int64, error) {
+	values, err := url.ParseQuery(job.Query)
+	if err != nil {
+		return 0, 0, err
+	}
+	params, err := ParseExportParams(values)
+	if err != nil {
+		return 0, 0, err
+	}
+	file, err := os.Create(job.Path + ".part")
+	if err != nil {
+		return 0, 0, err
+	}
+	rows, err := WriteExport(ctx, params, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return rows, 0, err
+	}
+	if err := os.Rename(job.Path+".part", job.Path); err != nil {
+		return rows, 0, err
+	}
+	info, err := os.Stat(job.Path)
+	if err != nil {
+		return rows, 0, err
+	}
+	return rows, info.Size(), nil
+}
+
+// expire deletes the files of expired export jobs every hour
+func (r *exportJobRunner) expire(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		var jobs []utils.ExportJob
+		if err := utils.DB.Where("status = ? AND expires_at < ?", utils.ExportDone, time.Now()).Find(&jobs).Error; err != nil {
+			log.Printf("Failed to load expired export jobs: %v", err)
+		}
+		for _, job := range jobs {
+			if err := os.Remove(job.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				log.Printf("Failed to delete export %d: %v", job.ID, err)
+				continue
+			}
+			utils.DB.Model(&job).Update("status", utils.ExportExpired)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// findExportJob loads an export job by its id path parameter
+func findExportJob(c *gin.Context) (*utils.ExportJob, bool) {
+	id, ok := ParseIDParam(c, "id")
+	if !ok {
+		return nil, false
+	}
+	var job utils.ExportJob
+	if err := utils.DB.First(&job, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Export not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return &job, true
+}
+
+// GetExportJob returns the status of an export job, with its download link
+// once done
+func GetExportJob(c *gin.Context) {
+	job, ok := findExportJob(c)
+	if !ok {
+		return
+	}
+	response := gin.H{"job": job}
+	if job.Status == utils.ExportDone {
+		response["download"] = fmt.Sprintf("/api/v1/admin/exports/%d/download", job.ID)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DownloadExportJob sends the file of a finished export job
+func DownloadExportJob(c *gin.Context) {
+	job, ok := findExportJob(c)
+	if !ok {
+		return
+	}
+	switch job.Status {
+	case utils.ExportDone:
+	case utils.ExportExpired:
+		c.JSON(http.StatusGone, gin.H{"error": "The export has expired"})
+		return
+	default:
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("The export is %s", job.Status)})
+		return
+	}
+	c.Header("Content-Type", exportContentTypes[job.Format])
+	c.FileAttachment(job.Path, exportFilename(job.Format))
+}
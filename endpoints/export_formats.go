@@ -0,0 +1,184 @@
+package endpoints
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_news_api/parquet"
+	"go_news_api/utils"
+)
+
+// Export formats
+const (
+	ExportNDJSON  = "ndjson"
+	ExportCSV     = "csv"
+	ExportParquet = "parquet"
+)
+
+// exportContentTypes are the media types of the export formats
+var exportContentTypes = map[string]string{
+	ExportNDJSON:  "application/x-ndjson",
+	ExportCSV:     "text/csv; charset=utf-8",
+	ExportParquet: "application/vnd.apache.parquet",
+}
+
+// exportColumn is a column of the CSV and Parquet exports
+type exportColumn struct {
+	Name     string
+	Type     parquet.Type
+	Optional bool
+	Value    func(row *utils.ExportedArticle) interface{}
+}
+
+func timeValue(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
+
+// exportColumns are the columns of the CSV and Parquet exports, in order.
+// Keywords are separated by spaces, which keywords never contain.
+func exportColumns(fullText bool) []exportColumn {
+	columns := []exportColumn{
+		{"id", parquet.Int64, false, func(r *utils.ExportedArticle) interface{} { return int64(r.ID) }},
+		{"url", parquet.String, false, func(r *utils.ExportedArticle) interface{} { return r.URL }},
+		{"title", parquet.String, false, func(r *utils.ExportedArticle) interface{} { return r.Title }},
+		{"description", parquet.String, false, func(r *utils.ExportedArticle) interface{} { return r.Description }},
+		{"author", parquet.String, false, func(r *utils.ExportedArticle) interface{} { return r.Author }},
+		{"content", parquet.String, false, func(r *utils.ExportedArticle) interface{} { return r.Content }},
+		{"summary", parquet.String, false, func(r *utils.ExportedArticle) interface{} { return r.Summary }},
+		{"url_to_image", parquet.String, false, func(r *utils.ExportedArticle) interface{} { return r.URLToImage }},
+		{"published_at", parquet.Timestamp, true, func(r *utils.ExportedArticle) interface{} { return timeValue(r.PublishedAt) }},
+		{"first_seen_at", parquet.Timestamp, true, func(r *utils.ExportedArticle) interface{} { return timeValue(r.FirstSeenAt) }},
+		{"last_seen_at", parquet.Timestamp, true, func(r *utils.ExportedArticle) interface{} { return timeValue(r.LastSeenAt) }},
+		{"language", parquet.String, false, func(r *utils.ExportedArticle) interface{} { return r.Language }},
+		{"category", parquet.String, false, func(r *utils.ExportedArticle) interface{} { return r.Category }},
+		{"category_source", parquet.String, false, func(r *utils.ExportedArticle) interface{} { return r.CategorySource }},
+		{"source_id", parquet.Int64, true, func(r *utils.ExportedArticle) interface{} {
+			if r.SourceID == nil {
+				return nil
+			}
+			return int64(*r.SourceID)
+		}},
+		{"source_name", parquet.String, false, func(r *utils.ExportedArticle) interface{} { return r.SourceName }},
+		{"source_domain", parquet.String, false, func(r *utils.ExportedArticle) interface{} { return r.SourceDomain }},
+		{"keywords", parquet.String, false, func(r *utils.ExportedArticle) interface{} { return r.KeywordList }},
+		{"provider", parquet.String, false, func(r *utils.ExportedArticle) interface{} { return r.Provider }},
+		{"query_type", parquet.String, false, func(r *utils.ExportedArticle) interface{} { return r.QueryType }},
+		{"query", parquet.String, false, func(r *utils.ExportedArticle) interface{} { return r.Query }},
+		{"requested_country", parquet.String, false, func(r *utils.ExportedArticle) interface{} { return r.RequestedCountry }},
+		{"requested_language", parquet.String, false, func(r *utils.ExportedArticle) interface{} { return r.RequestedLanguage }},
+		{"sightings", parquet.Int64, false, func(r *utils.ExportedArticle) interface{} { return r.Sightings }},
+	}
+	if fullText {
+		columns = append(columns, exportColumn{"full_text", parquet.String, false, func(r *utils.ExportedArticle) interface{} { return r.FullText }})
+	}
+	return columns
+}
+
+// articleExporter writes exported articles in one of the export formats
+type articleExporter interface {
+	Write(row *utils.ExportedArticle) error
+	// Close writes whatever the format needs at the end, but leaves the
+	// underlying writer open
+	Close() error
+}
+
+// newArticleExporter starts an export in format on w
+func newArticleExporter(format string, w io.Writer, fullText bool) (articleExporter, error) {
+	switch format {
+	case ExportCSV:
+		e := &csvExporter{w: csv.NewWriter(w), columns: exportColumns(fullText)}
+		header := make([]string, len(e.columns))
+		for i, column := range e.columns {
+			header[i] = column.Name
+		}
+		return e, e.w.Write(header)
+	case ExportParquet:
+		columns := exportColumns(fullText)
+		schema := make([]parquet.Column, len(columns))
+		for i, column := range columns {
+			schema[i] = parquet.Column{Name: column.Name, Type: column.Type, Optional: column.Optional}
+		}
+		pw, err := parquet.NewWriter(w, schema, utils.GetEnvInt("EXPORT_PARQUET_ROW_GROUP", parquet.DefaultRowGroupSize))
+		if err != nil {
+			return nil, err
+		}
+		return &parquetExporter{w: pw, columns: columns}, nil
+	default:
+		return &ndjsonExporter{enc: json.NewEncoder(w)}, nil
+	}
+}
+
+// ndjsonExporter writes one JSON object per line, keywords as an array
+type ndjsonExporter struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonExporter) Write(row *utils.ExportedArticle) error {
+	row.Keywords = strings.Fields(row.KeywordList)
+	return e.enc.Encode(row)
+}
+
+func (e *ndjsonExporter) Close() error {
+	return nil
+}
+
+// csvExporter writes a header row and one row per article. Times are RFC3339
+// in UTC; missing values are empty.
+type csvExporter struct {
+	w       *csv.Writer
+	columns []exportColumn
+	record  []string
+}
+
+func (e *csvExporter) Write(row *utils.ExportedArticle) error {
+	e.record = e.record[:0]
+	for _, column := range e.columns {
+		switch v := column.Value(row).(type) {
+		case nil:
+			e.record = append(e.record, "")
+		case string:
+			e.record = append(e.record, v)
+		case int64:
+			e.record = append(e.record, strconv.FormatInt(v, 10))
+		case time.Time:
+			e.record = append(e.record, v.Format(time.RFC3339Nano))
+		}
+	}
+	return e.w.Write(e.record)
+}
+
+// Flush sends the buffered rows on
+func (e *csvExporter) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvExporter) Close() error {
+	return e.Flush()
+}
+
+// parquetExporter writes a Parquet file, a row group at a time
+type parquetExporter struct {
+	w       *parquet.Writer
+	columns []exportColumn
+	values  []interface{}
+}
+
+func (e *parquetExporter) Write(row *utils.ExportedArticle) error {
+	e.values = e.values[:0]
+	for _, column := range e.columns {
+		e.values = append(e.values, column.Value(row))
+	}
+	return e.w.Write(e.values)
+}
+
+func (e *parquetExporter) Close() error {
+	return e.w.Close()
+}
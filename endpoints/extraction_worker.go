@@ -0,0 +1,223 @@
+package endpoints
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go_news_api/utils"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// ExtractionWorker fetches the pages of newly ingested articles in the
+// background, stores their full text and enriches their metadata
+type ExtractionWorker struct {
+	BatchSize    int
+	Concurrency  int
+	MaxAttempts  int
+	PollInterval time.Duration
+	RetryDelay   time.Duration
+
+	wake chan struct{}
+}
+
+// StartExtractionWorker starts the background extraction loop unless
+// EXTRACTION_ENABLED is "false". It wakes up whenever articles are ingested and
+// otherwise polls every EXTRACTION_POLL_INTERVAL.
+func StartExtractionWorker(ctx context.Context) {
+	if os.Getenv("EXTRACTION_ENABLED") == "false" {
+		log.Println("Article content extraction is disabled")
+		return
+	}
+
+	w := &ExtractionWorker{
+		BatchSize:    utils.GetEnvInt("EXTRACTION_BATCH_SIZE", 50),
+		Concurrency:  utils.GetEnvInt("EXTRACTION_CONCURRENCY", 4),
+		MaxAttempts:  utils.GetEnvInt("EXTRACTION_MAX_ATTEMPTS", 3),
+		PollInterval: utils.GetEnvDuration("EXTRACTION_POLL_INTERVAL", 5*time.Minute),
+		RetryDelay:   utils.GetEnvDuration("EXTRACTION_RETRY_DELAY", 10*time.Minute),
+		wake:         make(chan struct{}, 1),
+	}
+	OnArticlesIngested(func(provider string, articles []IngestedArticle) {
+		w.Wake()
+	})
+	go w.run(ctx)
+}
+
+// Wake asks the worker to look for pending articles now
+func (w *ExtractionWorker) Wake() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (w *ExtractionWorker) run(ctx context.Context) {
+	ticker := time.NewTicker(w.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Keep going while full batches come back
+		for ctx.Err() == nil {
+			processed, err := w.processBatch(ctx)
+			if err != nil {
+				log.Printf("Extraction batch failed: %v", err)
+				break
+			}
+			if processed < w.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.wake:
+		}
+	}
+}
+
+// processBatch extracts content for one batch of pending articles
+func (w *ExtractionWorker) processBatch(ctx context.Context) (int, error) {
+	var articles []utils.Article
+	err := utils.DB.Model(&utils.Article{}).
+		Select("articles.*").
+		Joins("LEFT JOIN article_contents ON article_contents.article_id = articles.id AND article_contents.deleted_at IS NULL").
+		Where("articles.url <> ''").
+		Where("article_contents.id IS NULL OR (article_contents.status IN ? AND article_contents.attempts < ? AND article_contents.next_attempt_at <= ?)",
+			[]string{utils.ExtractionFailed, utils.ExtractionPending}, w.MaxAttempts, time.Now()).
+		Order("articles.id DESC").
+		Limit(w.BatchSize).
+		Find(&articles).Error
+	if err != nil {
+		return 0, err
+	}
+
+	jobs := make(chan *utils.Article)
+	var wg sync.WaitGroup
+	for i := 0; i < w.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for article := range jobs {
+				if _, err := ExtractArticleContent(ctx, article, w.MaxAttempts, w.RetryDelay); err != nil {
+					log.Printf("Failed to extract article %d (%s): %v", article.ID, article.URL, err)
+				}
+			}
+		}()
+	}
+	for i := range articles {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- &articles[i]
+	}
+	close(jobs)
+	wg.Wait()
+
+	return len(articles), nil
+}
+
+// ExtractArticleContent fetches an article's page, extracts its main content
+// and stores the outcome. Failures are recorded on the ArticleContent row and
+// retried with exponential backoff until maxAttempts; pages that robots.txt
+// forbids or that are not HTML are not retried.
+func ExtractArticleContent(ctx context.Context, article *utils.Article, maxAttempts int, retryDelay time.Duration) (*utils.ArticleContent, error) {
+	var content utils.ArticleContent
+	err := utils.DB.Where(utils.ArticleContent{ArticleID: article.ID}).FirstOrInit(&content).Error
+	if err != nil {
+		return nil, err
+	}
+	content.Attempts++
+
+	extracted, meta, fetchErr := fetchAndExtract(ctx, article.URL)
+	now := time.Now()
+	switch {
+	case fetchErr == nil:
+		// The page is at hand, so fill missing article metadata from it as well
+		if _, err := ApplyEnrichment(utils.DB, article, meta); err != nil {
+			log.Printf("Failed to enrich article %d: %v", article.ID, err)
+		}
+		// The full text tells the language more reliably than the snippet
+		if extracted.WordCount > 0 {
+			utils.DetectArticleLanguage(article, article.Title+"\n"+extracted.Text, article.Language)
+			if err := utils.DB.Model(article).Select("language", "language_confidence", "search_config").Updates(article).Error; err != nil {
+				log.Printf("Failed to update language of article %d: %v", article.ID, err)
+			}
+		}
+		content.Status = utils.ExtractionDone
+		content.FullText = extracted.Text
+		content.WordCount = extracted.WordCount
+		content.LeadImage = extracted.LeadImage
+		content.DetectedPublishedAt = extracted.PublishedAt
+		content.Error = ""
+		content.ExtractedAt = &now
+	case errors.Is(fetchErr, ErrDisallowedByRobots):
+		content.Status = utils.ExtractionBlocked
+		content.Error = fetchErr.Error()
+	case errors.Is(fetchErr, errNotHTML):
+		content.Status = utils.ExtractionUnsupported
+		content.Error = fetchErr.Error()
+	default:
+		content.Status = utils.ExtractionFailed
+		content.Error = fetchErr.Error()
+		// Client errors such as 404 will not go away on their own
+		if errors.Is(fetchErr, ErrBadRequest) || errors.Is(fetchErr, ErrUnauthorized) {
+			content.Attempts = max(content.Attempts, maxAttempts)
+		}
+		content.NextAttemptAt = now.Add(retryDelay << (content.Attempts - 1))
+	}
+
+	if err := utils.DB.Save(&content).Error; err != nil {
+		return nil, fmt.Errorf("failed to save extracted content: %v", err)
+	}
+	// The full text names far more people and places than the snippet, says
+	// more about what the article is like, and only it is worth summarizing
+	if content.Status == utils.ExtractionDone && content.WordCount > 0 {
+		text := article.Title + "\n" + article.Description + "\n" + content.FullText
+		if err := SaveEntities(utils.DB, article, text); err != nil {
+			log.Printf("Failed to extract entities of article %d: %v", article.ID, err)
+		}
+		if err := SaveArticleVector(utils.DB, article, text); err != nil {
+			log.Printf("Failed to vectorize article %d: %v", article.ID, err)
+		} else if err := IndexArticleVectors([]uint{article.ID}); err != nil {
+			log.Printf("Failed to index article %d: %v", article.ID, err)
+		}
+		if err := UpdateArticleSummary(utils.DB, article, content.FullText); err != nil {
+			log.Printf("Failed to summarize article %d: %v", article.ID, err)
+		}
+	}
+	return &content, fetchErr
+}
+
+var errNotHTML = errors.New("page is not HTML")
+
+func fetchAndExtract(ctx context.Context, pageURL string) (*ExtractedContent, *PageMetadata, error) {
+	resp, err := pageFetcher.Fetch(ctx, pageURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" && !strings.Contains(contentType, "html") {
+		return nil, nil, fmt.Errorf("%w: %s", errNotHTML, contentType)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(resp.Body))
+	if err != nil {
+		return nil, nil, err
+	}
+	meta := ExtractMetadata(doc, pageURL)
+
+	extracted, err := ExtractContent(bytes.NewReader(resp.Body), pageURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	return extracted, meta, nil
+}
@@ -0,0 +1,313 @@
+package endpoints
+
+import (
+	"encoding/xml"
+	"mime"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	"go_news_api/utils"
+)
+
+// Feed formats
+const (
+	FeedRSS  = "rss"
+	FeedAtom = "atom"
+	FeedJSON = "json"
+)
+
+// feedContentTypes are the media types of the feed formats
+var feedContentTypes = map[string]string{
+	FeedRSS:  "application/rss+xml",
+	FeedAtom: "application/atom+xml",
+	FeedJSON: "application/feed+json",
+}
+
+// feed is a list of articles to be published in one of the feed formats
+type feed struct {
+	ID          string
+	Title       string
+	Description string
+	SelfURL     string
+	HomeURL     string
+	Format      string
+	Updated     time.Time
+	Articles    []utils.Article
+}
+
+// articleGUID identifies an article in feeds. It does not depend on the
+// article URL, which can be rewritten when canonicalized.
+func articleGUID(article *utils.Article) string {
+	return "urn:go-news-api:article:" + strconv.FormatUint(uint64(article.ID), 10)
+}
+
+// articleDate is when an article was published, or else first seen
+func articleDate(article *utils.Article) time.Time {
+	if article.PublishedAt != nil {
+		return *article.PublishedAt
+	}
+	if article.FirstSeenAt != nil {
+		return *article.FirstSeenAt
+	}
+	return article.CreatedAt
+}
+
+// articleBlurb is the short text of an article shown by feed readers
+func articleBlurb(article *utils.Article) string {
+	switch {
+	case article.Description != "":
+		return article.Description
+	case article.Summary != "":
+		return article.Summary
+	case article.Content != "":
+		return article.Content
+	}
+	return article.Title
+}
+
+// imageEnclosure returns an article's image URL and its guessed media type,
+// if it has an http or https image
+func imageEnclosure(article *utils.Article) (string, string, bool) {
+	parsed, err := url.Parse(article.URLToImage)
+	if article.URLToImage == "" || err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return "", "", false
+	}
+	mediaType := mime.TypeByExtension(path.Ext(parsed.Path))
+	if mediaType == "" {
+		mediaType = "image/jpeg"
+	}
+	return parsed.String(), mediaType, true
+}
+
+// RSS 2.0 document; see https://www.rssboard.org/rss-specification
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	DCNS    string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	AtomLink      rssLink   `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Generator     string    `xml:"generator"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	Description string        `xml:"description"`
+	Creator     string        `xml:"dc:creator,omitempty"`
+	Category    string        `xml:"category,omitempty"`
+	GUID        rssGUID       `xml:"guid"`
+	PubDate     string        `xml:"pubDate"`
+	Enclosure   *rssEnclosure `xml:"enclosure"`
+}
+
+type rssGUID struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length string `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// toRSS converts a feed to RSS 2.0
+func toRSS(f *feed) rssDocument {
+	doc := rssDocument{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		DCNS:    "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.HomeURL,
+			Description:   f.Description,
+			AtomLink:      rssLink{Href: f.SelfURL, Rel: "self", Type: feedContentTypes[FeedRSS]},
+			LastBuildDate: f.Updated.UTC().Format(time.RFC1123Z),
+			Generator:     "go_news_api",
+		},
+	}
+	for i := range f.Articles {
+		article := &f.Articles[i]
+		item := rssItem{
+			Title:       article.Title,
+			Link:        article.URL,
+			Description: articleBlurb(article),
+			Creator:     article.Author,
+			Category:    article.Category,
+			GUID:        rssGUID{IsPermaLink: "false", Value: articleGUID(article)},
+			PubDate:     articleDate(article).UTC().Format(time.RFC1123Z),
+		}
+		if image, mediaType, ok := imageEnclosure(article); ok {
+			// The size is unknown without downloading the image
+			item.Enclosure = &rssEnclosure{URL: image, Length: "0", Type: mediaType}
+		}
+		doc.Channel.Items = append(doc.Channel.Items, item)
+	}
+	return doc
+}
+
+// Atom 1.0 document; see RFC 4287
+type atomDocument struct {
+	XMLName   xml.Name    `xml:"feed"`
+	XMLNS     string      `xml:"xmlns,attr"`
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Subtitle  string      `xml:"subtitle,omitempty"`
+	Updated   string      `xml:"updated"`
+	Links     []atomLink  `xml:"link"`
+	Author    atomPerson  `xml:"author"`
+	Generator string      `xml:"generator"`
+	Entries   []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	ID        string        `xml:"id"`
+	Title     string        `xml:"title"`
+	Updated   string        `xml:"updated"`
+	Published string        `xml:"published"`
+	Links     []atomLink    `xml:"link"`
+	Author    *atomPerson   `xml:"author"`
+	Category  *atomCategory `xml:"category"`
+	Summary   string        `xml:"summary"`
+}
+
+// toAtom converts a feed to Atom 1.0
+func toAtom(f *feed) atomDocument {
+	doc := atomDocument{
+		XMLNS:    "http://www.w3.org/2005/Atom",
+		ID:       f.ID,
+		Title:    f.Title,
+		Subtitle: f.Description,
+		Updated:  f.Updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: f.SelfURL, Rel: "self", Type: feedContentTypes[FeedAtom]},
+			{Href: f.HomeURL, Rel: "alternate"},
+		},
+		Author:    atomPerson{Name: "go_news_api"},
+		Generator: "go_news_api",
+	}
+	for i := range f.Articles {
+		article := &f.Articles[i]
+		entry := atomEntry{
+			ID:        articleGUID(article),
+			Title:     article.Title,
+			Updated:   article.UpdatedAt.UTC().Format(time.RFC3339),
+			Published: articleDate(article).UTC().Format(time.RFC3339),
+			Links:     []atomLink{{Href: article.URL, Rel: "alternate", Type: "text/html"}},
+			Summary:   articleBlurb(article),
+		}
+		if article.Author != "" {
+			entry.Author = &atomPerson{Name: article.Author}
+		}
+		if article.Category != "" {
+			entry.Category = &atomCategory{Term: article.Category}
+		}
+		if image, mediaType, ok := imageEnclosure(article); ok {
+			entry.Links = append(entry.Links, atomLink{Href: image, Rel: "enclosure", Type: mediaType})
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return doc
+}
+
+// JSON Feed 1.1 document; see https://www.jsonfeed.org/version/1.1/
+type jsonFeedDocument struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url,omitempty"`
+	FeedURL     string         `json:"feed_url,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string               `json:"id"`
+	URL           string               `json:"url,omitempty"`
+	Title         string               `json:"title,omitempty"`
+	ContentText   string               `json:"content_text"`
+	Summary       string               `json:"summary,omitempty"`
+	Image         string               `json:"image,omitempty"`
+	DatePublished string               `json:"date_published,omitempty"`
+	DateModified  string               `json:"date_modified,omitempty"`
+	Authors       []jsonFeedAuthor     `json:"authors,omitempty"`
+	Tags          []string             `json:"tags,omitempty"`
+	Language      string               `json:"language,omitempty"`
+	Attachments   []jsonFeedAttachment `json:"attachments,omitempty"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedAttachment struct {
+	URL      string `json:"url"`
+	MimeType string `json:"mime_type"`
+}
+
+// toJSONFeed converts a feed to JSON Feed 1.1
+func toJSONFeed(f *feed) jsonFeedDocument {
+	doc := jsonFeedDocument{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.HomeURL,
+		FeedURL:     f.SelfURL,
+		Description: f.Description,
+		Items:       []jsonFeedItem{},
+	}
+	for i := range f.Articles {
+		article := &f.Articles[i]
+		item := jsonFeedItem{
+			ID:            articleGUID(article),
+			URL:           article.URL,
+			Title:         article.Title,
+			ContentText:   articleBlurb(article),
+			Summary:       article.Summary,
+			DatePublished: articleDate(article).UTC().Format(time.RFC3339),
+			DateModified:  article.UpdatedAt.UTC().Format(time.RFC3339),
+			Language:      article.Language,
+		}
+		if article.Author != "" {
+			item.Authors = []jsonFeedAuthor{{Name: article.Author}}
+		}
+		if article.Category != "" {
+			item.Tags = []string{article.Category}
+		}
+		if image, mediaType, ok := imageEnclosure(article); ok {
+			item.Image = image
+			item.Attachments = []jsonFeedAttachment{{URL: image, MimeType: mediaType}}
+		}
+		doc.Items = append(doc.Items, item)
+	}
+	return doc
+}
@@ -0,0 +1,334 @@
+package endpoints
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_news_api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxFeedItems caps the articles of one feed
+const maxFeedItems = 100
+
+// splitFeedName splits the last path segment of a feed URL, such as
+// latest.rss, into its name and format. Without an extension the format
+// query parameter is used, and RSS by default.
+func splitFeedName(c *gin.Context, segment string) (string, string, error) {
+	name, format := segment, c.DefaultQuery("format", FeedRSS)
+	if dot := strings.LastIndex(segment, "."); dot >= 0 {
+		if _, ok := feedContentTypes[segment[dot+1:]]; ok {
+			name, format = segment[:dot], segment[dot+1:]
+		}
+	}
+	if _, ok := feedContentTypes[format]; !ok {
+		return name, format, fmt.Errorf("%w: format must be %s, %s or %s", ErrBadRequest, FeedRSS, FeedAtom, FeedJSON)
+	}
+	return name, format, nil
+}
+
+// feedLimit reads the limit query parameter, 50 by default
+func feedLimit(c *gin.Context) (int, error) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > maxFeedItems {
+		return 0, fmt.Errorf("%w: limit must be between 1 and %d", ErrBadRequest, maxFeedItems)
+	}
+	return limit, nil
+}
+
+// feedBaseURL is FEED_BASE_URL, or else the scheme and host the request was
+// made to
+func feedBaseURL(c *gin.Context) string {
+	if base := os.Getenv("FEED_BASE_URL"); base != "" {
+		return strings.TrimRight(base, "/")
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// newFeed starts a feed in the requested format, identified by kind and key
+func newFeed(c *gin.Context, format, kind, key string) *feed {
+	base := feedBaseURL(c)
+	id := "urn:go-news-api:feed:" + kind
+	if key != "" {
+		id += ":" + url.QueryEscape(key)
+	}
+	return &feed{ID: id, Format: format, SelfURL: base + c.Request.URL.RequestURI(), HomeURL: base + "/"}
+}
+
+// latestOrder puts articles newest first, by publish date or, for undated
+// ones, by when they were first seen
+const latestOrder = "COALESCE(articles.published_at, articles.first_seen_at) DESC, articles.id DESC"
+
+// latestArticles selects articles newest first
+func latestArticles(limit int) *gorm.DB {
+	return utils.DB.Model(&utils.Article{}).
+		Preload("Source").
+		Order(latestOrder).
+		Limit(limit)
+}
+
+// feedValidators computes the ETag and Last-Modified time of a feed from
+// the ids and update times of its articles
+func feedValidators(f *feed) (string, time.Time) {
+	hash := sha256.New()
+	hash.Write([]byte(f.ID + "\x00" + f.Format + "\x00" + f.SelfURL))
+	var modified time.Time
+	var buf [16]byte
+	for i := range f.Articles {
+		article := &f.Articles[i]
+		binary.BigEndian.PutUint64(buf[:8], uint64(article.ID))
+		binary.BigEndian.PutUint64(buf[8:], uint64(article.UpdatedAt.UnixNano()))
+		hash.Write(buf[:])
+		if article.UpdatedAt.After(modified) {
+			modified = article.UpdatedAt
+		}
+	}
+	return `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`, modified
+}
+
+// notModified reports whether the client's cached copy, as described by
+// If-None-Match or else If-Modified-Since, is current
+func notModified(c *gin.Context, etag string, modified time.Time) bool {
+	if match := c.GetHeader("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				return true
+			}
+		}
+		return false
+	}
+	if since := c.GetHeader("If-Modified-Since"); since != "" && !modified.IsZero() {
+		if t, err := http.ParseTime(since); err == nil {
+			return !modified.Truncate(time.Second).After(t)
+		}
+	}
+	return false
+}
+
+// writeFeed sends a feed in its format, or 304 Not Modified when the client
+// already has it
+func writeFeed(c *gin.Context, f *feed) {
+	etag, modified := feedValidators(f)
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "public, max-age=300")
+	if !modified.IsZero() {
+		c.Header("Last-Modified", modified.UTC().Format(http.TimeFormat))
+		f.Updated = modified
+	} else {
+		f.Updated = time.Now()
+	}
+	if notModified(c, etag, modified) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	var data []byte
+	var err error
+	switch f.Format {
+	case FeedAtom:
+		data, err = xml.MarshalIndent(toAtom(f), "", "  ")
+		data = append([]byte(xml.Header), data...)
+	case FeedJSON:
+		data, err = json.MarshalIndent(toJSONFeed(f), "", "  ")
+	default:
+		data, err = xml.MarshalIndent(toRSS(f), "", "  ")
+		data = append([]byte(xml.Header), data...)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to encode feed: %v", err)})
+		return
+	}
+	c.Data(http.StatusOK, feedContentTypes[f.Format]+"; charset=utf-8", data)
+}
+
+// GetFeed serves latest.{rss,atom,json}, the newest stored articles
+// narrowed by the listing filters and lang, and search.{rss,atom,json},
+// the newest articles matching q as /news-by-keyword would find them
+func GetFeed(c *gin.Context) {
+	name, format, err := splitFeedName(c, c.Param("feed"))
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+	switch name {
+	case "latest":
+		getLatestFeed(c, format)
+	case "search":
+		getSearchFeed(c, format)
+	default:
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Unknown feed %q", name)})
+	}
+}
+
+func getLatestFeed(c *gin.Context, format string) {
+	limit, err := feedLimit(c)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+	filter, err := ParseArticleFilter(c.Request.URL.Query())
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+	lang, err := parseLangParam(c.Request.URL.Query())
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	f := newFeed(c, format, "latest", "")
+	f.Title = "Latest news"
+	f.Description = "The newest articles collected by go_news_api"
+	// sort=published_at orders by publish date alone, with undated articles
+	// last; otherwise the feed is newest first
+	query := filter.Apply(utils.DB.Model(&utils.Article{}).Preload("Source")).
+		Order(latestOrder).
+		Limit(limit)
+	if filter.Category != "" {
+		f.Title = "Latest " + filter.Category + " news"
+	}
+	if lang != "" {
+		query = query.Where("articles.language = ?", lang)
+	}
+	if err := query.Find(&f.Articles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	writeFeed(c, f)
+}
+
+func getSearchFeed(c *gin.Context, format string) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+	limit, err := feedLimit(c)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+	filter, err := ParseArticleFilter(c.Request.URL.Query())
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+	// Feed readers expect the newest items first
+	if c.Query("sort") == "" {
+		filter.Sort = SortPublishedAt
+	}
+	lang, err := parseLangParam(c.Request.URL.Query())
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	f := newFeed(c, format, "search", q)
+	f.Title = fmt.Sprintf("News matching %q", q)
+	f.Description = f.Title
+	if f.Articles, _, err = SearchArticles(PrepareSearchQuery(q), lang, filter, 1, limit); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	writeFeed(c, f)
+}
+
+// GetKeywordFeed serves the newest articles linked to a keyword. The format
+// is given as an extension of the keyword, e.g. /feeds/keyword/climate.atom.
+func GetKeywordFeed(c *gin.Context) {
+	word, format, err := splitFeedName(c, c.Param("word"))
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+	if word = normalizeKeyword(word); word == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Keyword is required"})
+		return
+	}
+	limit, err := feedLimit(c)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	f := newFeed(c, format, "keyword", word)
+	f.Title = fmt.Sprintf("News about %s", word)
+	f.Description = fmt.Sprintf("The newest articles mentioning %q", word)
+	if err := latestArticles(limit).
+		Joins("JOIN article_keywords ON article_keywords.article_id = articles.id").
+		Joins("JOIN keywords ON keywords.id = article_keywords.keyword_id").
+		Where("keywords.word = ?", word).
+		Find(&f.Articles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	writeFeed(c, f)
+}
+
+// GetSourceFeed serves the newest articles of a source, e.g.
+// /feeds/source/12.json
+func GetSourceFeed(c *gin.Context) {
+	rawID, format, err := splitFeedName(c, c.Param("id"))
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+	id, err := strconv.ParseUint(rawID, 10, 32)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid source ID"})
+		return
+	}
+	limit, err := feedLimit(c)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+	var source utils.Source
+	if err := utils.DB.First(&source, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Source not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	f := newFeed(c, format, "source", rawID)
+	f.Title = source.Name
+	if f.Title == "" {
+		f.Title = source.Domain
+	}
+	f.Description = fmt.Sprintf("The newest articles from %s", f.Title)
+	if source.Homepage != "" {
+		f.HomeURL = source.Homepage
+	} else if source.URL != "" {
+		f.HomeURL = source.URL
+	}
+	if err := latestArticles(limit).Where("articles.source_id = ?", source.ID).Find(&f.Articles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	writeFeed(c, f)
+}
@@ -0,0 +1,327 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go_news_api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+func testFeed(format string) *feed {
+	published := time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC)
+	firstSeen := time.Date(2024, 5, 2, 9, 0, 0, 0, time.UTC)
+	articles := []utils.Article{
+		{
+			Title:       "Council approves new tram line",
+			Description: "The line will open in 2028.",
+			URL:         "https://gazette.example/city/tram-line",
+			URLToImage:  "https://gazette.example/images/tram-line.png",
+			Author:      "Jana Novak",
+			Category:    "politics",
+			Language:    "en",
+			PublishedAt: &published,
+		},
+		{
+			Title:       "Undated story without an image",
+			URL:         "https://gazette.example/undated",
+			FirstSeenAt: &firstSeen,
+		},
+	}
+	articles[0].ID, articles[0].UpdatedAt = 1, published.Add(time.Hour)
+	articles[1].ID, articles[1].UpdatedAt = 2, firstSeen
+	return &feed{
+		ID:          "urn:go-news-api:feed:latest",
+		Title:       "Latest news",
+		Description: "The newest articles collected by go_news_api",
+		SelfURL:     "https://news.example/api/v1/feeds/latest." + format,
+		HomeURL:     "https://news.example/",
+		Format:      format,
+		Articles:    articles,
+	}
+}
+
+// serveFeed runs writeFeed for a request with the given headers
+func serveFeed(t *testing.T, f *feed, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/feeds/latest."+f.Format, nil)
+	for name, values := range header {
+		c.Request.Header[name] = values
+	}
+	writeFeed(c, f)
+	// The engine writes the status of a response without a body
+	c.Writer.WriteHeaderNow()
+	return w
+}
+
+func TestRSSFeed(t *testing.T) {
+	w := serveFeed(t, testFeed(FeedRSS), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "application/rss+xml") {
+		t.Errorf("content type = %q", got)
+	}
+
+	var doc struct {
+		XMLName xml.Name `xml:"rss"`
+		Version string   `xml:"version,attr"`
+		Channel struct {
+			Title string `xml:"title"`
+			// The channel link and the Atom self link share a local name
+			Links []struct {
+				XMLName xml.Name
+				Href    string `xml:"href,attr"`
+				Rel     string `xml:"rel,attr"`
+				Value   string `xml:",chardata"`
+			} `xml:"link"`
+			Description string `xml:"description"`
+			Items       []struct {
+				Title string `xml:"title"`
+				Link  string `xml:"link"`
+				GUID  struct {
+					IsPermaLink string `xml:"isPermaLink,attr"`
+					Value       string `xml:",chardata"`
+				} `xml:"guid"`
+				PubDate   string `xml:"pubDate"`
+				Enclosure *struct {
+					URL    string `xml:"url,attr"`
+					Length string `xml:"length,attr"`
+					Type   string `xml:"type,attr"`
+				} `xml:"enclosure"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	if err := xml.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid XML: %v", err)
+	}
+	if doc.Version != "2.0" {
+		t.Errorf("version = %q, want 2.0", doc.Version)
+	}
+	var link, self string
+	for _, l := range doc.Channel.Links {
+		switch {
+		case l.XMLName.Space == "":
+			link = l.Value
+		case l.XMLName.Space == "http://www.w3.org/2005/Atom" && l.Rel == "self":
+			self = l.Href
+		}
+	}
+	// A channel requires title, link and description
+	if doc.Channel.Title == "" || link != "https://news.example/" || doc.Channel.Description == "" {
+		t.Errorf("channel is missing required elements: title %q, link %q, description %q", doc.Channel.Title, link, doc.Channel.Description)
+	}
+	if self != "https://news.example/api/v1/feeds/latest.rss" {
+		t.Errorf("atom:link self = %q", self)
+	}
+	if len(doc.Channel.Items) != 2 {
+		t.Fatalf("got %d items, want 2", len(doc.Channel.Items))
+	}
+
+	first, second := doc.Channel.Items[0], doc.Channel.Items[1]
+	if first.GUID.Value != "urn:go-news-api:article:1" || first.GUID.IsPermaLink != "false" {
+		t.Errorf("guid = %+v", first.GUID)
+	}
+	// pubDate must be an RFC 822 date
+	if pubDate, err := time.Parse(time.RFC1123Z, first.PubDate); err != nil || !pubDate.Equal(time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC)) {
+		t.Errorf("pubDate = %q (%v)", first.PubDate, err)
+	}
+	if pubDate, err := time.Parse(time.RFC1123Z, second.PubDate); err != nil || !pubDate.Equal(time.Date(2024, 5, 2, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("undated item pubDate = %q, want when it was first seen (%v)", second.PubDate, err)
+	}
+	// An enclosure requires url, length and type
+	if first.Enclosure == nil {
+		t.Fatal("missing enclosure for the article image")
+	}
+	if first.Enclosure.URL != "https://gazette.example/images/tram-line.png" || first.Enclosure.Length == "" || first.Enclosure.Type != "image/png" {
+		t.Errorf("enclosure = %+v", *first.Enclosure)
+	}
+	if second.Enclosure != nil {
+		t.Errorf("article without an image has an enclosure: %+v", *second.Enclosure)
+	}
+}
+
+func TestAtomFeed(t *testing.T) {
+	w := serveFeed(t, testFeed(FeedAtom), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+
+	var doc struct {
+		XMLName xml.Name   `xml:"http://www.w3.org/2005/Atom feed"`
+		ID      string     `xml:"id"`
+		Title   string     `xml:"title"`
+		Updated string     `xml:"updated"`
+		Links   []atomLink `xml:"link"`
+		Author  struct {
+			Name string `xml:"name"`
+		} `xml:"author"`
+		Entries []struct {
+			ID        string     `xml:"id"`
+			Title     string     `xml:"title"`
+			Updated   string     `xml:"updated"`
+			Published string     `xml:"published"`
+			Links     []atomLink `xml:"link"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid Atom document: %v", err)
+	}
+	// A feed requires id, title and updated, and an author unless every
+	// entry has one
+	if doc.ID == "" || doc.Title == "" || doc.Author.Name == "" {
+		t.Errorf("feed is missing required elements: id %q, title %q, author %q", doc.ID, doc.Title, doc.Author.Name)
+	}
+	if updated, err := time.Parse(time.RFC3339, doc.Updated); err != nil || !updated.Equal(time.Date(2024, 5, 2, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("feed updated = %q, want the newest article update (%v)", doc.Updated, err)
+	}
+	if !hasAtomLink(doc.Links, "self", "https://news.example/api/v1/feeds/latest.atom") {
+		t.Errorf("missing self link: %+v", doc.Links)
+	}
+	if len(doc.Entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(doc.Entries))
+	}
+
+	entry := doc.Entries[0]
+	if entry.ID != "urn:go-news-api:article:1" || entry.Title == "" {
+		t.Errorf("entry id %q, title %q", entry.ID, entry.Title)
+	}
+	if updated, err := time.Parse(time.RFC3339, entry.Updated); err != nil || !updated.Equal(time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)) {
+		t.Errorf("entry updated = %q (%v)", entry.Updated, err)
+	}
+	if _, err := time.Parse(time.RFC3339, entry.Published); err != nil {
+		t.Errorf("entry published = %q: %v", entry.Published, err)
+	}
+	if !hasAtomLink(entry.Links, "alternate", "https://gazette.example/city/tram-line") {
+		t.Errorf("missing alternate link: %+v", entry.Links)
+	}
+	found := false
+	for _, l := range entry.Links {
+		if l.Rel == "enclosure" {
+			found = l.Href == "https://gazette.example/images/tram-line.png" && l.Type == "image/png"
+		}
+	}
+	if !found {
+		t.Errorf("missing image enclosure link: %+v", entry.Links)
+	}
+	for _, l := range doc.Entries[1].Links {
+		if l.Rel == "enclosure" {
+			t.Errorf("article without an image has an enclosure: %+v", l)
+		}
+	}
+}
+
+func hasAtomLink(links []atomLink, rel, href string) bool {
+	for _, l := range links {
+		if l.Rel == rel && l.Href == href {
+			return true
+		}
+	}
+	return false
+}
+
+func TestJSONFeed(t *testing.T) {
+	w := serveFeed(t, testFeed(FeedJSON), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "application/feed+json") {
+		t.Errorf("content type = %q", got)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if doc["version"] != "https://jsonfeed.org/version/1.1" {
+		t.Errorf("version = %v", doc["version"])
+	}
+	if doc["title"] == "" || doc["feed_url"] != "https://news.example/api/v1/feeds/latest.json" {
+		t.Errorf("title %v, feed_url %v", doc["title"], doc["feed_url"])
+	}
+	items, ok := doc["items"].([]interface{})
+	if !ok || len(items) != 2 {
+		t.Fatalf("items = %v", doc["items"])
+	}
+
+	first := items[0].(map[string]interface{})
+	if first["id"] != "urn:go-news-api:article:1" {
+		t.Errorf("id = %v", first["id"])
+	}
+	// Items require content_html or content_text
+	if first["content_text"] == "" {
+		t.Error("missing content_text")
+	}
+	if published, err := time.Parse(time.RFC3339, first["date_published"].(string)); err != nil || !published.Equal(time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC)) {
+		t.Errorf("date_published = %v (%v)", first["date_published"], err)
+	}
+	if first["image"] != "https://gazette.example/images/tram-line.png" {
+		t.Errorf("image = %v", first["image"])
+	}
+	attachments, _ := first["attachments"].([]interface{})
+	if len(attachments) != 1 {
+		t.Fatalf("attachments = %v", first["attachments"])
+	}
+	attachment := attachments[0].(map[string]interface{})
+	if attachment["url"] != "https://gazette.example/images/tram-line.png" || attachment["mime_type"] != "image/png" {
+		t.Errorf("attachment = %v", attachment)
+	}
+	// 1.1 lists authors rather than a single author
+	if _, ok := first["author"]; ok {
+		t.Error("item uses the JSON Feed 1.0 author field")
+	}
+	if _, ok := items[1].(map[string]interface{})["attachments"]; ok {
+		t.Error("article without an image has attachments")
+	}
+}
+
+func TestFeedConditionalGet(t *testing.T) {
+	w := serveFeed(t, testFeed(FeedRSS), nil)
+	etag, lastModified := w.Header().Get("ETag"), w.Header().Get("Last-Modified")
+	if etag == "" || lastModified == "" {
+		t.Fatalf("missing validators: ETag %q, Last-Modified %q", etag, lastModified)
+	}
+
+	tests := []struct {
+		name   string
+		header http.Header
+		status int
+	}{
+		{"matching ETag", http.Header{"If-None-Match": {etag}}, http.StatusNotModified},
+		{"weak matching ETag", http.Header{"If-None-Match": {`"other", W/` + etag}}, http.StatusNotModified},
+		{"stale ETag", http.Header{"If-None-Match": {`"stale"`}}, http.StatusOK},
+		{"stale ETag wins over a current date", http.Header{"If-None-Match": {`"stale"`}, "If-Modified-Since": {lastModified}}, http.StatusOK},
+		{"not modified since", http.Header{"If-Modified-Since": {lastModified}}, http.StatusNotModified},
+		{"modified since", http.Header{"If-Modified-Since": {"Wed, 01 May 2024 00:00:00 GMT"}}, http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := serveFeed(t, testFeed(FeedRSS), test.header)
+			if w.Code != test.status {
+				t.Fatalf("status = %d, want %d", w.Code, test.status)
+			}
+			if test.status == http.StatusNotModified && w.Body.Len() != 0 {
+				t.Errorf("304 response has a body of %d bytes", w.Body.Len())
+			}
+			if w.Header().Get("ETag") != etag {
+				t.Errorf("ETag = %q, want %q", w.Header().Get("ETag"), etag)
+			}
+		})
+	}
+
+	// Any change to an article makes a new ETag
+	changed := testFeed(FeedRSS)
+	changed.Articles[1].UpdatedAt = changed.Articles[1].UpdatedAt.Add(time.Minute)
+	if w := serveFeed(t, changed, http.Header{"If-None-Match": {etag}}); w.Code != http.StatusOK {
+		t.Errorf("changed feed: status = %d, want 200", w.Code)
+	}
+}
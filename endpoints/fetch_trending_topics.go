@@ -1,6 +1,8 @@
 package endpoints
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"net/http"
 	"sort"
@@ -22,14 +24,21 @@ func ParseGrowth(growth string) float64 {
 	return value
 }
 
-func FetchTrendingTopics() ([]utils.TrendingTopic, error) {
-	resp, err := http.Get("https://explodingtopics.com/blog/trending-topics")
+func FetchTrendingTopics(ctx context.Context) ([]utils.TrendingTopic, error) {
+	resp, err := scraperClient.Get(ctx, "https://explodingtopics.com/blog/trending-topics")
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch trending topics: %v", err)
+		return nil, fmt.Errorf("failed to fetch trending topics: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &ProviderError{
+			Provider:   "explodingtopics",
+			StatusCode: resp.StatusCode,
+			Message:    "failed to fetch trending topics",
+			Kind:       kindForStatus(resp.StatusCode),
+		}
 	}
-	defer resp.Body.Close()
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(resp.Body))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse HTML: %v", err)
 	}
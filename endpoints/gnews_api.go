@@ -1,33 +1,31 @@
 package endpoints
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"go_news_api/utils"
-	"io"
 	"log"
-	"net/http"
 	"net/url"
-	"os"
-	"strings"
 )
 
-func GetGNewsTopHeadlines(country, category string) (*utils.APIResponse, error) {
-	apikey := os.Getenv("GNEWS_API_KEY")
-	url := fmt.Sprintf("https://gnews.io/api/v4/top-headlines?category=%s&lang=en&country=%s&max=10&apikey=%s", category, country, apikey)
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
+const gNewsBaseURL = "https://gnews.io/api/v4"
+
+// GetGNewsTopHeadlines fetches top headlines from GNews. An empty language
+// means English and an empty country means any country.
+func GetGNewsTopHeadlines(ctx context.Context, country, category, language string) (*utils.APIResponse, error) {
+	if language == "" {
+		language = "en"
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	params := url.Values{}
+	params.Set("category", category)
+	params.Set("lang", language)
+	if country != "" {
+		params.Set("country", country)
 	}
+	params.Set("max", "10")
 
-	var gNewsResponse utils.GNewsResponse
-	err = json.Unmarshal(body, &gNewsResponse)
+	gNewsResponse, err := fetchGNews(ctx, "/top-headlines", params)
 	if err != nil {
 		return nil, err
 	}
@@ -36,16 +34,27 @@ func GetGNewsTopHeadlines(country, category string) (*utils.APIResponse, error)
 		TotalArticles: gNewsResponse.TotalArticles,
 		Articles:      gNewsResponse.Articles,
 		APISource:     "gnews",
+		Country:       country,
+		Language:      language,
 	}
 
 	return apiResponse, nil
 }
 
-func GetGNewsTrendingTopicsNews(topics []utils.TrendingTopic) (*utils.APIResponse, error) {
+// GetGNewsTrendingTopicsNews searches GNews for each topic. An empty language
+// means English and an empty country means the United States.
+func GetGNewsTrendingTopicsNews(ctx context.Context, topics []utils.TrendingTopic, country, language string) (*utils.APIResponse, error) {
+	if language == "" {
+		language = "en"
+	}
+	if country == "" {
+		country = "us"
+	}
+
 	var gNewsResponses []utils.APIResponse
 	for _, topic := range topics {
 		// Make API call for each trending topic
-		gNewsResponse, err := GetGNewsSearchByTopic(topic.Topic)
+		gNewsResponse, err := GetGNewsSearchByTopic(ctx, topic.Topic, country, language)
 		if err != nil {
 			return nil, err
 		}
@@ -60,63 +69,70 @@ func GetGNewsTrendingTopicsNews(topics []utils.TrendingTopic) (*utils.APIRespons
 		TotalArticles: combinedResponse.TotalArticles,
 		Articles:      combinedResponse.Articles,
 		APISource:     "gnews",
+		Country:       country,
+		Language:      language,
 	}
 
 	return apiResponse, nil
 }
 
-func GetGNewsSearchByTopic(topic string) (*utils.APIResponse, error) {
-	apikey := os.Getenv("GNEWS_API_KEY")
-
+func GetGNewsSearchByTopic(ctx context.Context, topic, country, language string) (*utils.APIResponse, error) {
 	// Create a url.Values to hold the query parameters
 	params := url.Values{}
 	params.Add("q", topic)
-	params.Add("lang", "en")
-	params.Add("country", "us")
+	params.Add("lang", language)
+	params.Add("country", country)
 	params.Add("max", "10")
-	params.Add("apikey", apikey)
 	params.Add("from", utils.GetYesterdayDate())
 	params.Add("to", utils.GetTodayDate())
 
-	// Construct the URL with properly encoded query parameters
-	baseURL := "https://gnews.io/api/v4/search"
-	fullURL := baseURL + "?" + params.Encode()
-
-	// Log the URL (remove sensitive information like API key in production)
-	logURL := strings.Replace(fullURL, apikey, "REDACTED", 1)
-	log.Printf("GNews API request URL: %s", logURL)
-
-	resp, err := http.Get(fullURL)
+	gNewsResponse, err := fetchGNews(ctx, "/search", params)
 	if err != nil {
-		return nil, fmt.Errorf("HTTP request failed: %v", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %v", err)
+	apiResponse := &utils.APIResponse{
+		TotalArticles: gNewsResponse.TotalArticles,
+		Articles:      gNewsResponse.Articles,
+		APISource:     "gnews",
+		Country:       country,
+		Language:      language,
 	}
 
-	// Log the response status code and headers
-	log.Printf("GNews API response status: %s", resp.Status)
-	log.Printf("GNews API response headers: %v", resp.Header)
-
-	// Check if the response is HTML (error page)
-	if strings.Contains(string(body), "<html") {
-		return nil, fmt.Errorf("received HTML response instead of JSON. Status: %s, Response: %s", resp.Status, string(body))
-	}
+	return apiResponse, nil
+}
 
-	var gNewsResponse utils.GNewsResponse
-	err = json.Unmarshal(body, &gNewsResponse)
+// fetchGNews calls a GNews endpoint and decodes the response, turning error
+// payloads into a ProviderError and rotating API keys on rate limit or auth
+// errors
+func fetchGNews(ctx context.Context, path string, params url.Values) (*utils.GNewsResponse, error) {
+	var resp *ProviderResponse
+	err := gNewsKeys.Do(func(key *APIKey) error {
+		params.Set("apikey", key.Value)
+		fullURL := gNewsBaseURL + path + "?" + params.Encode()
+		log.Printf("GNews API request URL: %s (key %s)", redactURL(fullURL), key.Fingerprint)
+
+		var err error
+		resp, err = gNewsClient.Get(ctx, fullURL)
+		if err != nil {
+			return err
+		}
+		log.Printf("GNews API response status: %d", resp.StatusCode)
+		return checkGNewsResponse(resp)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal JSON: %v. Response body: %s", err, string(body))
+		return nil, err
 	}
 
-	apiResponse := &utils.APIResponse{
-		TotalArticles: gNewsResponse.TotalArticles,
-		Articles:      gNewsResponse.Articles,
-		APISource:     "gnews",
+	var gNewsResponse utils.GNewsResponse
+	if err := json.Unmarshal(resp.Body, &gNewsResponse); err != nil {
+		return nil, &ProviderError{
+			Provider:   "gnews",
+			StatusCode: resp.StatusCode,
+			Message:    "failed to unmarshal JSON: " + err.Error(),
+			Kind:       ErrInvalidResponse,
+		}
 	}
 
-	return apiResponse, nil
+	return &gNewsResponse, nil
 }
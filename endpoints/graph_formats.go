@@ -0,0 +1,188 @@
+package endpoints
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"go_news_api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GraphML document of a keyword graph; see http://graphml.graphdrawing.org
+type graphMLDocument struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	ID     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// GEXF document of a keyword graph; see https://gexf.net
+type gexfDocument struct {
+	XMLName xml.Name  `xml:"gexf"`
+	XMLNS   string    `xml:"xmlns,attr"`
+	Version string    `xml:"version,attr"`
+	Meta    gexfMeta  `xml:"meta"`
+	Graph   gexfGraph `xml:"graph"`
+}
+
+type gexfMeta struct {
+	LastModified string `xml:"lastmodifieddate,attr"`
+	Creator      string `xml:"creator"`
+	Description  string `xml:"description"`
+}
+
+type gexfGraph struct {
+	Mode            string           `xml:"mode,attr"`
+	DefaultEdgeType string           `xml:"defaultedgetype,attr"`
+	Attributes      []gexfAttributes `xml:"attributes"`
+	Nodes           []gexfNode       `xml:"nodes>node"`
+	Edges           []gexfEdge       `xml:"edges>edge"`
+}
+
+type gexfAttributes struct {
+	Class      string          `xml:"class,attr"`
+	Attributes []gexfAttribute `xml:"attribute"`
+}
+
+type gexfAttribute struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+type gexfNode struct {
+	ID        string         `xml:"id,attr"`
+	Label     string         `xml:"label,attr"`
+	AttValues []gexfAttValue `xml:"attvalues>attvalue"`
+}
+
+type gexfEdge struct {
+	ID        string         `xml:"id,attr"`
+	Source    string         `xml:"source,attr"`
+	Target    string         `xml:"target,attr"`
+	Weight    float64        `xml:"weight,attr"`
+	AttValues []gexfAttValue `xml:"attvalues>attvalue"`
+}
+
+type gexfAttValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// graphNodeIDs numbers the nodes of a graph, since keywords are not valid XML
+// ids
+func graphNodeIDs(graph *utils.KeywordGraph) map[string]string {
+	ids := make(map[string]string, len(graph.Nodes))
+	for i, node := range graph.Nodes {
+		ids[node.ID] = "n" + strconv.Itoa(i)
+	}
+	return ids
+}
+
+func formatWeight(weight float64) string {
+	return strconv.FormatFloat(weight, 'g', -1, 64)
+}
+
+// toGraphML converts a keyword graph to GraphML
+func toGraphML(graph *utils.KeywordGraph) graphMLDocument {
+	ids := graphNodeIDs(graph)
+	doc := graphMLDocument{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "label", For: "node", Name: "label", Type: "string"},
+			{ID: "node_articles", For: "node", Name: "articles", Type: "long"},
+			{ID: "weight", For: "edge", Name: "weight", Type: "double"},
+			{ID: "edge_articles", For: "edge", Name: "articles", Type: "long"},
+		},
+		Graph: graphMLGraph{ID: "keywords", EdgeDefault: "undirected"},
+	}
+	for _, node := range graph.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{ID: ids[node.ID], Data: []graphMLData{
+			{Key: "label", Value: node.ID},
+			{Key: "node_articles", Value: strconv.FormatInt(node.Articles, 10)},
+		}})
+	}
+	for i, edge := range graph.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{ID: "e" + strconv.Itoa(i), Source: ids[edge.Source], Target: ids[edge.Target], Data: []graphMLData{
+			{Key: "weight", Value: formatWeight(edge.Weight)},
+			{Key: "edge_articles", Value: strconv.FormatInt(edge.Articles, 10)},
+		}})
+	}
+	return doc
+}
+
+// toGEXF converts a keyword graph to GEXF 1.3
+func toGEXF(graph *utils.KeywordGraph) gexfDocument {
+	ids := graphNodeIDs(graph)
+	articles := []gexfAttribute{{ID: "0", Title: "articles", Type: "long"}}
+	doc := gexfDocument{
+		XMLNS:   "http://gexf.net/1.3",
+		Version: "1.3",
+		Meta: gexfMeta{
+			LastModified: graph.To.Format("2006-01-02"),
+			Creator:      "go_news_api",
+			Description:  fmt.Sprintf("Keyword co-occurrence from %s to %s, weighted by %s", graph.From.Format("2006-01-02T15:04Z07:00"), graph.To.Format("2006-01-02T15:04Z07:00"), graph.Weighting),
+		},
+		Graph: gexfGraph{
+			Mode:            "static",
+			DefaultEdgeType: "undirected",
+			Attributes:      []gexfAttributes{{Class: "node", Attributes: articles}, {Class: "edge", Attributes: articles}},
+		},
+	}
+	for _, node := range graph.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, gexfNode{ID: ids[node.ID], Label: node.ID, AttValues: []gexfAttValue{
+			{For: "0", Value: strconv.FormatInt(node.Articles, 10)},
+		}})
+	}
+	for i, edge := range graph.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, gexfEdge{ID: "e" + strconv.Itoa(i), Source: ids[edge.Source], Target: ids[edge.Target], Weight: edge.Weight, AttValues: []gexfAttValue{
+			{For: "0", Value: strconv.FormatInt(edge.Articles, 10)},
+		}})
+	}
+	return doc
+}
+
+// writeGraphFile sends an XML graph document as a download
+func writeGraphFile(c *gin.Context, contentType, filename string, doc interface{}) {
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to encode graph: %v", err)})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Data(http.StatusOK, contentType+"; charset=utf-8", append([]byte(xml.Header), data...))
+}
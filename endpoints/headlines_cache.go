@@ -0,0 +1,205 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"go_news_api/utils"
+
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// X-Cache header values
+const (
+	CacheHit   = "HIT"
+	CacheMiss  = "MISS"
+	CacheStale = "STALE"
+)
+
+// HeadlinesKey identifies a cached top headlines response
+type HeadlinesKey struct {
+	Provider string
+	Country  string
+	Category string
+	Language string
+}
+
+func (k HeadlinesKey) String() string {
+	return strings.Join([]string{"top-headlines", k.Provider, k.Country, k.Category, k.Language}, ":")
+}
+
+// CachedResponse is a rendered response held by the cache
+type CachedResponse struct {
+	Body       []byte
+	ServedBy   string
+	FetchedAt  time.Time
+	ExpiresAt  time.Time
+	StaleUntil time.Time
+}
+
+// HeadlinesCache caches top headlines in an in-memory LRU backed by TTL rows in
+// Postgres. Expired entries are served as stale while they are refreshed in
+// the background, and concurrent misses for the same key share one upstream
+// call.
+type HeadlinesCache struct {
+	TTL      time.Duration
+	StaleTTL time.Duration
+
+	memory *utils.LRU[string, *CachedResponse]
+	group  singleflight.Group
+}
+
+var headlinesCache *HeadlinesCache
+
+// InitHeadlinesCache configures the top headlines cache from the environment
+func InitHeadlinesCache() {
+	headlinesCache = &HeadlinesCache{
+		TTL:      utils.GetEnvDuration("HEADLINES_CACHE_TTL", 15*time.Minute),
+		StaleTTL: utils.GetEnvDuration("HEADLINES_CACHE_STALE_TTL", time.Hour),
+		memory:   utils.NewLRU[string, *CachedResponse](utils.GetEnvInt("HEADLINES_CACHE_SIZE", 256)),
+	}
+
+	go func() {
+		for range time.Tick(time.Hour) {
+			if err := purgeExpiredCacheEntries(); err != nil {
+				log.Printf("Failed to purge expired cache entries: %v", err)
+			}
+		}
+	}()
+}
+
+// GetCachedTopHeadlines returns top headlines for key, calling fetch on a miss.
+// fetch must persist whatever it needs; its result is rendered to JSON and
+// cached. With refresh set the cache is bypassed and overwritten.
+func GetCachedTopHeadlines(ctx context.Context, key HeadlinesKey, refresh bool, fetch func(ctx context.Context) (*utils.APIResponse, error)) (*CachedResponse, string, error) {
+	hc := headlinesCache
+	if !refresh {
+		if entry := hc.lookup(key); entry != nil {
+			now := time.Now()
+			if now.Before(entry.ExpiresAt) {
+				return entry, CacheHit, nil
+			}
+			if now.Before(entry.StaleUntil) {
+				go hc.revalidate(key, fetch)
+				return entry, CacheStale, nil
+			}
+		}
+	}
+
+	entry, err := hc.load(ctx, key, fetch)
+	if err != nil {
+		return nil, "", err
+	}
+	return entry, CacheMiss, nil
+}
+
+// load fetches key from upstream, coalescing concurrent calls. The upstream
+// call is detached from the caller's cancellation so that one client going
+// away does not fail everyone waiting on the same key.
+func (hc *HeadlinesCache) load(ctx context.Context, key HeadlinesKey, fetch func(ctx context.Context) (*utils.APIResponse, error)) (*CachedResponse, error) {
+	result := hc.group.DoChan(key.String(), func() (interface{}, error) {
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), utils.GetEnvDuration("HEADLINES_FETCH_TIMEOUT", time.Minute))
+		defer cancel()
+
+		apiResponse, err := fetch(fetchCtx)
+		if err != nil {
+			return nil, err
+		}
+		return hc.store(key, apiResponse)
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-result:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*CachedResponse), nil
+	}
+}
+
+// revalidate refreshes a stale entry in the background
+func (hc *HeadlinesCache) revalidate(key HeadlinesKey, fetch func(ctx context.Context) (*utils.APIResponse, error)) {
+	if _, err := hc.load(context.Background(), key, fetch); err != nil {
+		log.Printf("Failed to revalidate %s: %v", key, err)
+	}
+}
+
+// lookup checks memory first and falls back to Postgres
+func (hc *HeadlinesCache) lookup(key HeadlinesKey) *CachedResponse {
+	if entry, ok := hc.memory.Get(key.String()); ok {
+		return entry
+	}
+
+	var row utils.CacheEntry
+	err := utils.DB.Where("key = ? AND stale_until > ?", key.String(), time.Now()).First(&row).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Failed to read cache entry %s: %v", key, err)
+		}
+		return nil
+	}
+
+	entry := &CachedResponse{
+		Body:       row.Body,
+		ServedBy:   row.ServedBy,
+		FetchedAt:  row.FetchedAt,
+		ExpiresAt:  row.ExpiresAt,
+		StaleUntil: row.StaleUntil,
+	}
+	hc.memory.Add(key.String(), entry)
+	return entry
+}
+
+// store renders apiResponse and saves it in memory and Postgres
+func (hc *HeadlinesCache) store(key HeadlinesKey, apiResponse *utils.APIResponse) (*CachedResponse, error) {
+	body, err := json.Marshal(apiResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	entry := &CachedResponse{
+		Body:       body,
+		ServedBy:   apiResponse.APISource,
+		FetchedAt:  now,
+		ExpiresAt:  now.Add(hc.TTL),
+		StaleUntil: now.Add(hc.TTL + hc.StaleTTL),
+	}
+	hc.memory.Add(key.String(), entry)
+
+	row := utils.CacheEntry{
+		Key:        key.String(),
+		Provider:   key.Provider,
+		Country:    key.Country,
+		Category:   key.Category,
+		Language:   key.Language,
+		ServedBy:   entry.ServedBy,
+		Body:       entry.Body,
+		FetchedAt:  entry.FetchedAt,
+		ExpiresAt:  entry.ExpiresAt,
+		StaleUntil: entry.StaleUntil,
+	}
+	err = utils.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"updated_at", "served_by", "body", "fetched_at", "expires_at", "stale_until"}),
+	}).Create(&row).Error
+	if err != nil {
+		// The in-memory copy still serves; a broken DB cache is not fatal
+		log.Printf("Failed to persist cache entry %s: %v", key, err)
+	}
+
+	return entry, nil
+}
+
+// purgeExpiredCacheEntries deletes cache rows that are past their stale window
+func purgeExpiredCacheEntries() error {
+	return utils.DB.Unscoped().Where("stale_until < ?", time.Now()).Delete(&utils.CacheEntry{}).Error
+}
@@ -0,0 +1,449 @@
+package endpoints
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"go_news_api/utils"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html/charset"
+)
+
+// Import formats. NewsAPI and GNews files hold raw provider responses, one
+// after another or in a JSON array.
+const (
+	ImportNDJSON  = "ndjson"
+	ImportNewsAPI = "newsapi"
+	ImportGNews   = "gnews"
+	ImportRSS     = "rss"
+)
+
+// importProviders are the providers recorded for the articles of each
+// format unless the import names one
+var importProviders = map[string]string{
+	ImportNDJSON:  "import",
+	ImportNewsAPI: "newsapi",
+	ImportGNews:   "gnews",
+	ImportRSS:     "rss",
+}
+
+// maxImportLine caps an NDJSON line
+const maxImportLine = 4 << 20
+
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// importRecord is a record read from an import file: an article or, when
+// the record cannot be imported, the reason why
+type importRecord struct {
+	Article utils.Article
+	Err     error
+}
+
+// importReader reads the records of an import file in order. Errors that
+// make the rest of the file unreadable are returned; io.EOF ends the file.
+type importReader interface {
+	Next() (importRecord, error)
+}
+
+// newImportReader starts reading r in format
+func newImportReader(format string, r io.Reader) importReader {
+	switch format {
+	case ImportNewsAPI, ImportGNews:
+		return &responseImportReader{br: bufio.NewReader(r)}
+	case ImportRSS:
+		dec := xml.NewDecoder(r)
+		dec.CharsetReader = charset.NewReaderLabel
+		dec.Strict = false
+		return &feedImportReader{dec: dec}
+	default:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), maxImportLine)
+		return &ndjsonImportReader{scanner: scanner}
+	}
+}
+
+// DetectImportFormat guesses the format of an import file from its first
+// bytes: XML is RSS or Atom, a first line that is a whole JSON object
+// without articles is NDJSON, and anything else JSON a provider response,
+// GNews if it counts totalArticles
+func DetectImportFormat(head []byte) (string, error) {
+	head = bytes.TrimLeft(bytes.TrimPrefix(head, utf8BOM), " \t\r\n")
+	if len(head) == 0 {
+		return "", fmt.Errorf("%w: the file is empty", ErrBadRequest)
+	}
+	switch head[0] {
+	case '<':
+		return ImportRSS, nil
+	case '{', '[':
+	default:
+		return "", fmt.Errorf("%w: the format could not be detected; give one of %s, %s, %s or %s", ErrBadRequest, ImportNDJSON, ImportNewsAPI, ImportGNews, ImportRSS)
+	}
+	if head[0] == '{' {
+		if end := bytes.IndexByte(head, '\n'); end > 0 {
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(head[:end], &fields); err == nil {
+				if _, ok := fields["articles"]; !ok {
+					return ImportNDJSON, nil
+				}
+			}
+		}
+	}
+	if bytes.Contains(head, []byte(`"totalArticles"`)) {
+		return ImportGNews, nil
+	}
+	return ImportNewsAPI, nil
+}
+
+// ndjsonImportReader reads one utils.Article per line. Blank lines are not
+// records.
+type ndjsonImportReader struct {
+	scanner *bufio.Scanner
+}
+
+func (r *ndjsonImportReader) Next() (importRecord, error) {
+	for r.scanner.Scan() {
+		line := bytes.TrimSpace(bytes.TrimPrefix(r.scanner.Bytes(), utf8BOM))
+		if len(line) == 0 {
+			continue
+		}
+		var record importRecord
+		if err := json.Unmarshal(line, &record.Article); err != nil {
+			record.Err = fmt.Errorf("invalid JSON: %v", err)
+		}
+		return record, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return importRecord{}, fmt.Errorf("failed to read line: %v", err)
+	}
+	return importRecord{}, io.EOF
+}
+
+// importedResponse is a News API or GNews response. Articles are decoded
+// one by one so that a malformed article only rejects itself.
+type importedResponse struct {
+	Status   string            `json:"status"`
+	Message  string            `json:"message"`
+	Articles []json.RawMessage `json:"articles"`
+}
+
+// responseImportReader reads the articles of successive provider responses.
+// Both providers' articles decode as utils.Article, as they do when fetched.
+type responseImportReader struct {
+	br      *bufio.Reader
+	dec     *json.Decoder
+	pending []json.RawMessage
+}
+
+func (r *responseImportReader) Next() (importRecord, error) {
+	if r.dec == nil {
+		if err := r.openArray(); err != nil {
+			return importRecord{}, fmt.Errorf("failed to decode provider response: %v", err)
+		}
+	}
+	for len(r.pending) == 0 {
+		if !r.dec.More() {
+			return importRecord{}, io.EOF
+		}
+		var response importedResponse
+		if err := r.dec.Decode(&response); err != nil {
+			return importRecord{}, fmt.Errorf("failed to decode provider response: %v", err)
+		}
+		r.pending = response.Articles
+	}
+
+	var record importRecord
+	if err := json.Unmarshal(r.pending[0], &record.Article); err != nil {
+		record.Err = fmt.Errorf("invalid article: %v", err)
+	}
+	r.pending = r.pending[1:]
+	return record, nil
+}
+
+// openArray starts decoding, consuming the opening bracket when the
+// responses are in an array
+func (r *responseImportReader) openArray() error {
+	if bom, _ := r.br.Peek(3); bytes.Equal(bom, utf8BOM) {
+		r.br.Discard(3)
+	}
+	for {
+		b, err := r.br.Peek(1)
+		if err != nil || (b[0] != ' ' && b[0] != '\t' && b[0] != '\r' && b[0] != '\n') {
+			break
+		}
+		r.br.Discard(1)
+	}
+	r.dec = json.NewDecoder(r.br)
+	if b, err := r.br.Peek(1); err == nil && b[0] == '[' {
+		_, err := r.dec.Token()
+		return err
+	}
+	return nil
+}
+
+// feedImportReader reads the items of an RSS 2.0 feed or the entries of an
+// Atom feed. The feed itself is the source of its items unless an item
+// names its own.
+type feedImportReader struct {
+	dec    *xml.Decoder
+	path   []string
+	source utils.Source
+}
+
+type rssImportItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	Description string `xml:"description"`
+	Encoded     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Author      string `xml:"author"`
+	Creator     string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	PubDate     string `xml:"pubDate"`
+	Date        string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Source      struct {
+		URL  string `xml:"url,attr"`
+		Name string `xml:",chardata"`
+	} `xml:"source"`
+	Enclosures []struct {
+		URL  string `xml:"url,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"enclosure"`
+	Media []struct {
+		URL    string `xml:"url,attr"`
+		Medium string `xml:"medium,attr"`
+		Type   string `xml:"type,attr"`
+	} `xml:"http://search.yahoo.com/mrss/ content"`
+	Thumbnail struct {
+		URL string `xml:"url,attr"`
+	} `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+}
+
+type atomImportLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type atomImportEntry struct {
+	Title     string           `xml:"title"`
+	Links     []atomImportLink `xml:"link"`
+	Summary   string           `xml:"summary"`
+	Content   string           `xml:"content"`
+	Author    string           `xml:"author>name"`
+	Published string           `xml:"published"`
+	Updated   string           `xml:"updated"`
+}
+
+// feedLink reads a <link> of the feed itself: the text of an RSS link or
+// the href of an Atom alternate link
+type feedLink struct {
+	atomImportLink
+	Text string `xml:",chardata"`
+}
+
+func (r *feedImportReader) Next() (importRecord, error) {
+	for {
+		token, err := r.dec.Token()
+		if err == io.EOF {
+			return importRecord{}, io.EOF
+		}
+		if err != nil {
+			return importRecord{}, fmt.Errorf("failed to parse feed: %v", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			parent := ""
+			if len(r.path) > 0 {
+				parent = r.path[len(r.path)-1]
+			}
+			feedLevel := parent == "channel" || parent == "feed"
+			switch {
+			case t.Name.Local == "item" || (t.Name.Local == "entry" && parent == "feed"):
+				return r.decodeItem(t)
+			case feedLevel && t.Name.Local == "title":
+				var title string
+				if err := r.dec.DecodeElement(&title, &t); err != nil {
+					return importRecord{}, fmt.Errorf("failed to parse feed: %v", err)
+				}
+				r.source.Name = cleanImportText(title)
+				continue
+			case feedLevel && t.Name.Local == "link":
+				var link feedLink
+				if err := r.dec.DecodeElement(&link, &t); err != nil {
+					return importRecord{}, fmt.Errorf("failed to parse feed: %v", err)
+				}
+				if text := strings.TrimSpace(link.Text); text != "" && r.source.URL == "" {
+					r.source.URL = text
+				} else if link.Href != "" && (link.Rel == "" || link.Rel == "alternate") {
+					r.source.URL = strings.TrimSpace(link.Href)
+				}
+				continue
+			}
+			r.path = append(r.path, t.Name.Local)
+		case xml.EndElement:
+			if len(r.path) > 0 {
+				r.path = r.path[:len(r.path)-1]
+			}
+		}
+	}
+}
+
+// decodeItem reads an RSS item or Atom entry. A malformed item rejects only
+// itself when the decoder can carry on after it.
+func (r *feedImportReader) decodeItem(start xml.StartElement) (importRecord, error) {
+	var record importRecord
+	if start.Name.Local == "entry" {
+		var entry atomImportEntry
+		if err := r.dec.DecodeElement(&entry, &start); err != nil {
+			return record, fmt.Errorf("failed to parse feed entry: %v", err)
+		}
+		record.Article = r.atomArticle(&entry)
+		return record, nil
+	}
+	var item rssImportItem
+	if err := r.dec.DecodeElement(&item, &start); err != nil {
+		return record, fmt.Errorf("failed to parse feed item: %v", err)
+	}
+	record.Article = r.rssArticle(&item)
+	return record, nil
+}
+
+func (r *feedImportReader) rssArticle(item *rssImportItem) utils.Article {
+	article := utils.Article{
+		Title:          cleanImportText(item.Title),
+		URL:            strings.TrimSpace(item.Link),
+		Description:    cleanImportText(item.Description),
+		Content:        cleanImportText(item.Encoded),
+		Author:         strings.TrimSpace(item.Author),
+		PublishedAtRaw: strings.TrimSpace(item.PubDate),
+		Source:         r.source,
+	}
+	if article.URL == "" && isHTTPURL(item.GUID) {
+		article.URL = strings.TrimSpace(item.GUID)
+	}
+	if article.Author == "" {
+		article.Author = strings.TrimSpace(item.Creator)
+	}
+	if article.PublishedAtRaw == "" {
+		article.PublishedAtRaw = strings.TrimSpace(item.Date)
+	}
+	if name := strings.TrimSpace(item.Source.Name); name != "" {
+		article.Source = utils.Source{Name: name, URL: strings.TrimSpace(item.Source.URL)}
+	}
+	for _, enclosure := range item.Enclosures {
+		if strings.HasPrefix(enclosure.Type, "image/") {
+			article.URLToImage = strings.TrimSpace(enclosure.URL)
+			break
+		}
+	}
+	for _, media := range item.Media {
+		if article.URLToImage == "" && (media.Medium == "image" || strings.HasPrefix(media.Type, "image/")) {
+			article.URLToImage = strings.TrimSpace(media.URL)
+		}
+	}
+	if article.URLToImage == "" {
+		article.URLToImage = strings.TrimSpace(item.Thumbnail.URL)
+	}
+	return article
+}
+
+func (r *feedImportReader) atomArticle(entry *atomImportEntry) utils.Article {
+	article := utils.Article{
+		Title:          cleanImportText(entry.Title),
+		Description:    cleanImportText(entry.Summary),
+		Content:        cleanImportText(entry.Content),
+		Author:         strings.TrimSpace(entry.Author),
+		PublishedAtRaw: strings.TrimSpace(entry.Published),
+		Source:         r.source,
+	}
+	if article.PublishedAtRaw == "" {
+		article.PublishedAtRaw = strings.TrimSpace(entry.Updated)
+	}
+	for _, link := range entry.Links {
+		switch link.Rel {
+		case "", "alternate":
+			if article.URL == "" {
+				article.URL = strings.TrimSpace(link.Href)
+			}
+		case "enclosure":
+			if article.URLToImage == "" && strings.HasPrefix(link.Type, "image/") {
+				article.URLToImage = strings.TrimSpace(link.Href)
+			}
+		}
+	}
+	return article
+}
+
+// cleanImportText reduces feed text, which is often HTML, to plain text
+func cleanImportText(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.ContainsAny(s, "<&") {
+		return s
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(s))
+	if err != nil {
+		return s
+	}
+	return strings.Join(strings.Fields(doc.Text()), " ")
+}
+
+func isHTTPURL(raw string) bool {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// errRemovedArticle marks the placeholders News API returns for articles
+// taken down since
+var errRemovedArticle = errors.New("removed by the provider")
+
+// normalizeImportedArticle validates an imported article and keeps only the
+// fields a provider would supply, trimmed. Undated articles are kept.
+func normalizeImportedArticle(in *utils.Article) (utils.Article, error) {
+	article := utils.Article{
+		Author:         strings.TrimSpace(in.Author),
+		Title:          strings.TrimSpace(in.Title),
+		Description:    strings.TrimSpace(in.Description),
+		URL:            strings.TrimSpace(in.URL),
+		URLToImage:     strings.TrimSpace(in.URLToImage),
+		PublishedAtRaw: strings.TrimSpace(in.PublishedAtRaw),
+		Content:        strings.TrimSpace(in.Content),
+		Source: utils.Source{
+			ExternalID: in.Source.ExternalID,
+			Name:       strings.TrimSpace(in.Source.Name),
+			URL:        strings.TrimSpace(in.Source.URL),
+		},
+	}
+	if article.Title == "[Removed]" || article.URL == "https://removed.com" {
+		return article, errRemovedArticle
+	}
+	if article.Title == "" {
+		return article, errors.New("title is required")
+	}
+	if !isHTTPURL(article.URL) {
+		return article, errors.New("url must be an absolute http or https URL")
+	}
+	if !isHTTPURL(article.URLToImage) {
+		article.URLToImage = ""
+	}
+	if !isHTTPURL(article.Source.URL) {
+		article.Source.URL = ""
+	}
+
+	article.PublishedAt = in.PublishedAt
+	if article.PublishedAt == nil {
+		article.PublishedAt = utils.ParsePublishedAt(article.PublishedAtRaw)
+	}
+	if article.PublishedAt != nil && article.PublishedAt.After(time.Now().Add(24*time.Hour)) {
+		return article, fmt.Errorf("published_at %s is in the future", article.PublishedAt.Format(time.RFC3339))
+	}
+	return article, nil
+}
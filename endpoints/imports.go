@@ -0,0 +1,491 @@
+package endpoints
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go_news_api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxImportRejections caps the rejections an import run keeps; all of them
+// are counted
+const maxImportRejections = 100
+
+// ErrImportInProgress is returned when the same file is already being
+// imported
+var ErrImportInProgress = errors.New("this file is already being imported")
+
+// ImportParams describes an import file
+type ImportParams struct {
+	// Format is ndjson, newsapi, gnews or rss, detected when empty
+	Format string
+	// Provider is recorded on the sightings of the imported articles; it
+	// defaults to newsapi or gnews for their dumps and import or rss otherwise
+	Provider string
+	// Language is the language hint for detecting the articles' language
+	Language string
+	Filename string
+	DryRun   bool
+}
+
+// ParseImportParams reads format, provider, lang, filename and dry_run from
+// a query string
+func ParseImportParams(values url.Values) (ImportParams, error) {
+	params := ImportParams{
+		Format:   strings.ToLower(values.Get("format")),
+		Provider: strings.ToLower(strings.TrimSpace(values.Get("provider"))),
+		Filename: filepath.Base(strings.TrimSpace(values.Get("filename"))),
+		DryRun:   values.Get("dry_run") == "true",
+	}
+	if params.Filename == "." {
+		params.Filename = ""
+	}
+	if _, ok := importProviders[params.Format]; params.Format != "" && !ok {
+		return params, fmt.Errorf("%w: format must be %s, %s, %s or %s", ErrBadRequest, ImportNDJSON, ImportNewsAPI, ImportGNews, ImportRSS)
+	}
+	var err error
+	if params.Language, err = parseLangParam(values); err != nil {
+		return params, err
+	}
+	return params, nil
+}
+
+// ImportResult reports an import. A dry run saves nothing and its Run is
+// not stored; Created and Updated then say what the import would do.
+// Skipped counts the records an earlier attempt had already imported.
+type ImportResult struct {
+	Run             *utils.ImportRun `json:"run"`
+	DryRun          bool             `json:"dry_run"`
+	AlreadyImported bool             `json:"already_imported,omitempty"`
+	Skipped         int              `json:"skipped,omitempty"`
+}
+
+// rejectRecord counts a rejected record, keeping the first few reasons
+func rejectRecord(run *utils.ImportRun, record int, article *utils.Article, err error) {
+	run.Rejected++
+	if len(run.Rejections) < maxImportRejections {
+		run.Rejections = append(run.Rejections, utils.ImportRejection{Record: record, URL: article.URL, Reason: err.Error()})
+	}
+}
+
+// checksumFile returns the SHA-256 of a file and its first bytes, for
+// format detection
+func checksumFile(path string) (string, []byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer file.Close()
+
+	br := bufio.NewReaderSize(file, 64*1024)
+	head, _ := br.Peek(64 * 1024)
+	head = append([]byte(nil), head...)
+	hash := sha256.New()
+	if _, err := io.Copy(hash, br); err != nil {
+		return "", nil, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), head, nil
+}
+
+// RunImport imports the articles of a file through the same save path as
+// provider fetches, in batches of IMPORT_BATCH_SIZE records, each committed
+// with the run's progress. Importing a file again resumes where an
+// interrupted run stopped, or reports the finished run without changes.
+func RunImport(ctx context.Context, params ImportParams, path string) (*ImportResult, error) {
+	checksum, head, err := checksumFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read import file: %v", err)
+	}
+	if params.Format == "" {
+		if params.Format, err = DetectImportFormat(head); err != nil {
+			return nil, err
+		}
+	}
+	if params.Provider == "" {
+		params.Provider = importProviders[params.Format]
+	}
+
+	if params.DryRun {
+		return dryRunImport(ctx, params, checksum, path)
+	}
+
+	run, apiResponse, err := startImportRun(params, checksum)
+	if err != nil {
+		return nil, err
+	}
+	result := &ImportResult{Run: run}
+	if run.Status == utils.ImportDone {
+		result.AlreadyImported = true
+		return result, nil
+	}
+	result.Skipped = run.Processed
+
+	if err := importRecords(ctx, params, path, run, apiResponse); err != nil {
+		finished := time.Now()
+		run.Status, run.Error, run.FinishedAt = utils.ImportFailed, err.Error(), &finished
+		utils.DB.Model(&utils.ImportRun{}).Where("id = ?", run.ID).
+			Updates(map[string]interface{}{"status": run.Status, "error": run.Error, "finished_at": run.FinishedAt})
+		return result, err
+	}
+	return result, nil
+}
+
+// startImportRun creates the run of a file, or takes over the earlier run
+// of the same file when it failed or went stale. A run stops being renewed
+// when its process dies, so one not updated for IMPORT_STALE_AFTER can be
+// resumed.
+func startImportRun(params ImportParams, checksum string) (*utils.ImportRun, *utils.APIResponse, error) {
+	var run utils.ImportRun
+	var apiResponse utils.APIResponse
+	staleAfter := utils.GetEnvDuration("IMPORT_STALE_AFTER", 10*time.Minute)
+	err := utils.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("checksum = ? AND format = ?", checksum, params.Format).
+			Limit(1).Find(&run)
+		if result.Error != nil {
+			return fmt.Errorf("Failed to look up import run: %v", result.Error)
+		}
+
+		if result.RowsAffected == 0 {
+			apiResponse = utils.APIResponse{
+				Status:    "ok",
+				APISource: params.Provider,
+				Type:      "import",
+				Topic:     params.Filename,
+				Language:  params.Language,
+			}
+			if err := tx.Omit("Articles").Create(&apiResponse).Error; err != nil {
+				return fmt.Errorf("Failed to save API response: %v", err)
+			}
+			run = utils.ImportRun{
+				Checksum:      checksum,
+				Format:        params.Format,
+				Filename:      params.Filename,
+				Provider:      params.Provider,
+				Status:        utils.ImportRunning,
+				APIResponseID: apiResponse.ID,
+			}
+			created := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&run)
+			if created.Error != nil {
+				return fmt.Errorf("Failed to save import run: %v", created.Error)
+			}
+			if created.RowsAffected == 0 {
+				// Another request created the run since we looked
+				return ErrImportInProgress
+			}
+			return nil
+		}
+
+		switch {
+		case run.Status == utils.ImportDone:
+			return nil
+		case run.Status == utils.ImportRunning && time.Since(run.UpdatedAt) < staleAfter:
+			return ErrImportInProgress
+		}
+		if err := tx.First(&apiResponse, run.APIResponseID).Error; err != nil {
+			return fmt.Errorf("Failed to load the import's API response: %v", err)
+		}
+		run.Status = utils.ImportRunning
+		run.Error = ""
+		run.FinishedAt = nil
+		if err := tx.Save(&run).Error; err != nil {
+			return fmt.Errorf("Failed to resume import run: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &run, &apiResponse, nil
+}
+
+// importRecords reads the file and saves its records after those the run
+// has already processed
+func importRecords(ctx context.Context, params ImportParams, path string, run *utils.ImportRun, apiResponse *utils.APIResponse) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("Failed to read import file: %v", err)
+	}
+	defer file.Close()
+
+	reader := newImportReader(params.Format, file)
+	batchSize := utils.GetEnvInt("IMPORT_BATCH_SIZE", 500)
+	skip := run.Processed
+	progress := *run
+	var batch []importRecord
+	for number := 1; ; number++ {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: record %d: %v", ErrBadRequest, number, err)
+		}
+		if number <= skip {
+			continue
+		}
+		batch = append(batch, record)
+		if len(batch) < batchSize {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("Import interrupted after %d records: %v", run.Processed, err)
+		}
+		if err := saveImportBatch(&progress, apiResponse, batch); err != nil {
+			return err
+		}
+		*run = progress
+		batch = batch[:0]
+	}
+
+	progress.Status = utils.ImportDone
+	finished := time.Now()
+	progress.FinishedAt = &finished
+	if err := saveImportBatch(&progress, apiResponse, batch); err != nil {
+		return err
+	}
+	*run = progress
+	log.Printf("Imported %s: %d created, %d updated, %d rejected", run.Filename, run.Created, run.Updated, run.Rejected)
+	return nil
+}
+
+// saveImportBatch saves a batch of records that follow progress.Processed,
+// and the run's progress with them, in one transaction. Each record is
+// saved under a savepoint, so one that fails to save is rejected without
+// losing the rest of the batch. The saved articles are announced to
+// ingestion listeners once committed. progress is only advanced if the
+// batch commits.
+func saveImportBatch(progress *utils.ImportRun, apiResponse *utils.APIResponse, batch []importRecord) error {
+	run := *progress
+	run.Rejections = append([]utils.ImportRejection(nil), progress.Rejections...)
+	var saved []IngestedArticle
+	err := utils.DB.Transaction(func(tx *gorm.DB) error {
+		for _, record := range batch {
+			run.Processed++
+			article, err := record.Article, record.Err
+			if err == nil {
+				article, err = normalizeImportedArticle(&record.Article)
+			}
+			if err != nil {
+				rejectRecord(&run, run.Processed, &article, err)
+				continue
+			}
+
+			if err := tx.SavePoint("import_record").Error; err != nil {
+				return fmt.Errorf("Failed to create savepoint: %v", err)
+			}
+			created, err := SaveArticle(tx, apiResponse, &article, run.Processed, SaveImported)
+			if err != nil {
+				if err := tx.RollbackTo("import_record").Error; err != nil {
+					return fmt.Errorf("Record %d: %v", run.Processed, err)
+				}
+				rejectRecord(&run, run.Processed, &article, err)
+				continue
+			}
+			if created {
+				run.Created++
+			} else {
+				run.Updated++
+			}
+			saved = append(saved, IngestedArticle{Article: article, Created: created, Imported: true})
+		}
+		if err := tx.Save(&run).Error; err != nil {
+			return fmt.Errorf("Failed to save import progress: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	*progress = run
+	PublishIngestedArticles(apiResponse.APISource, saved)
+	return nil
+}
+
+// dryRunImport validates a file and reports which of its articles an
+// import would create or update, without saving anything
+func dryRunImport(ctx context.Context, params ImportParams, checksum, path string) (*ImportResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read import file: %v", err)
+	}
+	defer file.Close()
+
+	run := &utils.ImportRun{Checksum: checksum, Format: params.Format, Filename: params.Filename, Provider: params.Provider}
+	result := &ImportResult{Run: run, DryRun: true}
+	var done utils.ImportRun
+	if err := utils.DB.Where("checksum = ? AND format = ? AND status = ?", checksum, params.Format, utils.ImportDone).
+		Limit(1).Find(&done).Error; err != nil {
+		return nil, fmt.Errorf("Failed to look up import run: %v", err)
+	}
+	result.AlreadyImported = done.ID != 0
+
+	reader := newImportReader(params.Format, file)
+	batchSize := utils.GetEnvInt("IMPORT_BATCH_SIZE", 500)
+	// URLs seen earlier in the file would be updated, not created, by the
+	// time their later records were saved
+	seen := make(map[string]bool)
+	var urls []string
+	flush := func() error {
+		if len(urls) == 0 {
+			return nil
+		}
+		var existing []string
+		if err := utils.DB.Model(&utils.Article{}).Where("url IN ?", urls).Distinct().Pluck("url", &existing).Error; err != nil {
+			return fmt.Errorf("Error checking for existing articles: %v", err)
+		}
+		stored := make(map[string]bool, len(existing))
+		for _, u := range existing {
+			stored[u] = true
+		}
+		for _, u := range urls {
+			if stored[u] || seen[u] {
+				run.Updated++
+			} else {
+				run.Created++
+			}
+			seen[u] = true
+		}
+		urls = urls[:0]
+		return nil
+	}
+
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: record %d: %v", ErrBadRequest, run.Processed+1, err)
+		}
+		run.Processed++
+		article, err := record.Article, record.Err
+		if err == nil {
+			article, err = normalizeImportedArticle(&record.Article)
+		}
+		if err != nil {
+			rejectRecord(run, run.Processed, &article, err)
+			continue
+		}
+		urls = append(urls, article.URL)
+		if len(urls) >= batchSize {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ImportArticles imports the request body, a file in one of the import
+// formats. The body is spooled to a temporary file first, up to
+// IMPORT_MAX_BYTES.
+func ImportArticles(c *gin.Context) {
+	params, err := ParseImportParams(c.Request.URL.Query())
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	spool, err := os.CreateTemp("", "go-news-import-*")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to spool import: %v", err)})
+		return
+	}
+	defer os.Remove(spool.Name())
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, int64(utils.GetEnvInt("IMPORT_MAX_BYTES", 256<<20)))
+	_, err = io.Copy(spool, body)
+	if closeErr := spool.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("The import is larger than %d bytes", tooLarge.Limit)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Failed to read import: %v", err)})
+		return
+	}
+
+	result, err := RunImport(c.Request.Context(), params, spool.Name())
+	switch {
+	case errors.Is(err, ErrBadRequest):
+		RespondError(c, err)
+	case errors.Is(err, ErrImportInProgress):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		response := gin.H{"error": err.Error()}
+		if result != nil {
+			response["run"] = result.Run
+		}
+		c.JSON(http.StatusInternalServerError, response)
+	default:
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// ListImportRuns lists import runs, newest first
+func ListImportRuns(c *gin.Context) {
+	page, perPage := GetPaginationParams(c)
+	var runs []utils.ImportRun
+	var total int64
+	query := utils.DB.Model(&utils.ImportRun{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := query.Order("id DESC").Offset((page - 1) * perPage).Limit(perPage).Find(&runs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"total":    total,
+		"page":     page,
+		"per_page": perPage,
+		"imports":  runs,
+	})
+}
+
+// GetImportRun returns an import run with its counts and first rejections
+func GetImportRun(c *gin.Context) {
+	id, ok := ParseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var run utils.ImportRun
+	if err := utils.DB.First(&run, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Import not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
@@ -0,0 +1,48 @@
+package endpoints
+
+import (
+	"sync"
+
+	"go_news_api/utils"
+)
+
+// IngestedArticle is an article committed by an ingestion path
+type IngestedArticle struct {
+	utils.Article
+	// Created is set when the ingestion created the article rather than
+	// seeing it again
+	Created bool
+	// Imported is set for articles from an archive import or a payload
+	// replay, which are not news: listeners announcing new articles pass
+	// over them
+	Imported bool
+}
+
+// ArticlesIngestedFunc is called after articles have been committed by an
+// ingestion path. It must not block; hand slow work off to a goroutine.
+type ArticlesIngestedFunc func(provider string, articles []IngestedArticle)
+
+var (
+	ingestListenersMu sync.RWMutex
+	ingestListeners   []ArticlesIngestedFunc
+)
+
+// OnArticlesIngested registers a listener for newly committed articles
+func OnArticlesIngested(fn ArticlesIngestedFunc) {
+	ingestListenersMu.Lock()
+	defer ingestListenersMu.Unlock()
+	ingestListeners = append(ingestListeners, fn)
+}
+
+// PublishIngestedArticles notifies listeners about articles that have just
+// been committed. Call it only after the transaction that saved them commits.
+func PublishIngestedArticles(provider string, articles []IngestedArticle) {
+	if len(articles) == 0 {
+		return
+	}
+	ingestListenersMu.RLock()
+	defer ingestListenersMu.RUnlock()
+	for _, fn := range ingestListeners {
+		fn(provider, articles)
+	}
+}
@@ -0,0 +1,94 @@
+package endpoints
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"go_news_api/utils"
+)
+
+// IngestionScheduler periodically fetches top headlines for every combination
+// of the configured countries, languages and categories
+type IngestionScheduler struct {
+	Source     string
+	Countries  []string
+	Languages  []string
+	Categories []string
+	Interval   time.Duration
+}
+
+// StartIngestionScheduler starts scheduled ingestion when INGESTION_ENABLED is
+// "true". An empty entry in INGESTION_COUNTRIES or INGESTION_LANGUAGES, as in
+// the default language list, means the provider's default.
+func StartIngestionScheduler(ctx context.Context) {
+	if os.Getenv("INGESTION_ENABLED") != "true" {
+		return
+	}
+
+	s := &IngestionScheduler{
+		Source:     utils.GetEnvList("INGESTION_SOURCE", []string{SourceAuto})[0],
+		Countries:  lowerAll(utils.GetEnvList("INGESTION_COUNTRIES", []string{"us"})),
+		Languages:  lowerAll(utils.GetEnvList("INGESTION_LANGUAGES", []string{""})),
+		Categories: utils.GetEnvList("INGESTION_CATEGORIES", []string{"general"}),
+		Interval:   utils.GetEnvDuration("INGESTION_INTERVAL", time.Hour),
+	}
+	log.Printf("Scheduled ingestion every %s from %s for countries %v, languages %v, categories %v",
+		s.Interval, s.Source, s.Countries, s.Languages, s.Categories)
+	go s.run(ctx)
+}
+
+func lowerAll(values []string) []string {
+	result := make([]string, len(values))
+	for i, value := range values {
+		result[i] = strings.ToLower(value)
+	}
+	return result
+}
+
+func (s *IngestionScheduler) run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.RunOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce fetches every country, language and category combination once.
+// Combinations still fresh in the headlines cache cost no provider quota.
+func (s *IngestionScheduler) RunOnce(ctx context.Context) {
+	for _, country := range s.Countries {
+		for _, language := range s.Languages {
+			for _, category := range s.Categories {
+				if ctx.Err() != nil {
+					return
+				}
+				key := HeadlinesKey{Provider: s.Source, Country: country, Category: category, Language: language}
+				_, status, err := GetCachedTopHeadlines(ctx, key, false, func(ctx context.Context) (*utils.APIResponse, error) {
+					apiResponse, err := FetchTopHeadlines(ctx, s.Source, country, category, language)
+					if err != nil {
+						return nil, err
+					}
+					if err := SaveTopHeadlines(apiResponse); err != nil {
+						return nil, err
+					}
+					return apiResponse, nil
+				})
+				if err != nil {
+					log.Printf("Scheduled ingestion of %s failed: %v", key, err)
+					continue
+				}
+				log.Printf("Scheduled ingestion of %s: %s", key, status)
+			}
+		}
+	}
+}
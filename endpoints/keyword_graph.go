@@ -0,0 +1,278 @@
+package endpoints
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"go_news_api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Edge weightings of the keyword graph
+const (
+	WeightPMI     = "pmi"
+	WeightJaccard = "jaccard"
+	WeightCount   = "count"
+)
+
+// maxGraphWindow caps the time span a keyword graph is computed over
+const maxGraphWindow = 90 * 24 * time.Hour
+
+// maxGraphEdges caps the edges, or the neighbors of a seed, of one graph
+const maxGraphEdges = 2000
+
+// keywordArticlesSQL selects the keywords of the articles published in a
+// range, one row per article and distinct word
+const keywordArticlesSQL = `
+    SELECT DISTINCT article_keywords.article_id, keywords.word
+    FROM article_keywords
+    JOIN keywords ON keywords.id = article_keywords.keyword_id AND keywords.deleted_at IS NULL
+    JOIN articles ON articles.id = article_keywords.article_id AND articles.deleted_at IS NULL
+    WHERE COALESCE(articles.published_at, articles.first_seen_at) BETWEEN @from AND @to`
+
+// graphParams are the parameters of a keyword graph
+type graphParams struct {
+	From       time.Time
+	To         time.Time
+	Weighting  string
+	MinSupport int
+	MinWeight  *float64
+	Limit      int
+	Seed       string
+}
+
+// parseGraphParams reads the window (or from and to), weight, min_support,
+// min_weight, limit and seed query parameters
+func parseGraphParams(c *gin.Context) (graphParams, error) {
+	params := graphParams{To: time.Now().UTC(), Weighting: c.DefaultQuery("weight", WeightPMI), Seed: normalizeKeyword(c.Query("seed"))}
+	if to := c.Query("to"); to != "" {
+		parsed := utils.ParsePublishedAt(to)
+		if parsed == nil {
+			return params, fmt.Errorf("%w: invalid to date %q", ErrBadRequest, to)
+		}
+		params.To = parsed.UTC()
+	}
+	if from := c.Query("from"); from != "" {
+		parsed := utils.ParsePublishedAt(from)
+		if parsed == nil {
+			return params, fmt.Errorf("%w: invalid from date %q", ErrBadRequest, from)
+		}
+		params.From = parsed.UTC()
+	} else {
+		window, err := parseWindow(c.DefaultQuery("window", "7d"))
+		if err != nil {
+			return params, err
+		}
+		params.From = params.To.Add(-window)
+	}
+	if params.To.Before(params.From) {
+		return params, fmt.Errorf("%w: to must not be before from", ErrBadRequest)
+	}
+	if params.To.Sub(params.From) > maxGraphWindow {
+		return params, fmt.Errorf("%w: the graph can span at most %d days", ErrBadRequest, int(maxGraphWindow.Hours()/24))
+	}
+
+	switch params.Weighting {
+	case WeightPMI, WeightJaccard, WeightCount:
+	default:
+		return params, fmt.Errorf("%w: weight must be %s, %s or %s", ErrBadRequest, WeightPMI, WeightJaccard, WeightCount)
+	}
+
+	var err error
+	if params.MinSupport, err = strconv.Atoi(c.DefaultQuery("min_support", "3")); err != nil || params.MinSupport < 1 {
+		return params, fmt.Errorf("%w: min_support must be a positive number", ErrBadRequest)
+	}
+	if params.Limit, err = strconv.Atoi(c.DefaultQuery("limit", "200")); err != nil || params.Limit < 1 || params.Limit > maxGraphEdges {
+		return params, fmt.Errorf("%w: limit must be between 1 and %d", ErrBadRequest, maxGraphEdges)
+	}
+	if raw := c.Query("min_weight"); raw != "" {
+		minWeight, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return params, fmt.Errorf("%w: invalid min_weight %q", ErrBadRequest, raw)
+		}
+		params.MinWeight = &minWeight
+	}
+	return params, nil
+}
+
+// weigh sets the weight of an edge from the article counts of its keywords,
+// of the pair, and of the window
+func weigh(edge *utils.GraphEdge, source, target, total int64, weighting string) {
+	switch weighting {
+	case WeightPMI:
+		// log of how much more often the pair occurs than if independent
+		edge.Weight = math.Log(float64(edge.Articles) * float64(total) / (float64(source) * float64(target)))
+	case WeightJaccard:
+		edge.Weight = float64(edge.Articles) / float64(source+target-edge.Articles)
+	default:
+		edge.Weight = float64(edge.Articles)
+	}
+}
+
+type pairRow struct {
+	Source   string
+	Target   string
+	Articles int64
+}
+
+// keywordPairs counts the articles mentioning each pair of frequent keywords.
+// Only pairs for which restrict holds, if given, are counted.
+func keywordPairs(params graphParams, restrict string, vars map[string]interface{}) ([]pairRow, error) {
+	sql := `
+        WITH windowed AS (` + keywordArticlesSQL + `),
+        frequent AS (
+            SELECT word FROM windowed GROUP BY word HAVING COUNT(*) >= @min_support
+        )
+        SELECT a.word AS source, b.word AS target, COUNT(*) AS articles
+        FROM windowed AS a
+        JOIN windowed AS b ON b.article_id = a.article_id AND b.word > a.word
+        WHERE a.word IN (SELECT word FROM frequent) AND b.word IN (SELECT word FROM frequent)`
+	if restrict != "" {
+		sql += " AND (" + restrict + ")"
+	}
+	sql += `
+        GROUP BY a.word, b.word
+        HAVING COUNT(*) >= @min_support`
+
+	named := map[string]interface{}{"from": params.From, "to": params.To, "min_support": params.MinSupport}
+	for name, value := range vars {
+		named[name] = value
+	}
+	var pairs []pairRow
+	if err := utils.DB.Raw(sql, named).Scan(&pairs).Error; err != nil {
+		return nil, fmt.Errorf("Failed to count keyword pairs: %v", err)
+	}
+	return pairs, nil
+}
+
+// BuildKeywordGraph computes the co-occurrence graph of the keywords of the
+// articles published in the range. Keywords and pairs need min_support
+// articles. Without a seed the graph is the limit strongest edges; with one
+// it is the seed's ego network: its limit strongest neighbors and every edge
+// among them.
+func BuildKeywordGraph(params graphParams) (*utils.KeywordGraph, error) {
+	graph := &utils.KeywordGraph{From: params.From, To: params.To, Weighting: params.Weighting, Seed: params.Seed, Nodes: []utils.GraphNode{}, Edges: []utils.GraphEdge{}}
+
+	var counts []struct {
+		Word     string
+		Articles int64
+	}
+	if err := utils.DB.Raw(`SELECT word, COUNT(*) AS articles FROM (`+keywordArticlesSQL+`) AS windowed GROUP BY word HAVING COUNT(*) >= @min_support`,
+		map[string]interface{}{"from": params.From, "to": params.To, "min_support": params.MinSupport}).
+		Scan(&counts).Error; err != nil {
+		return nil, fmt.Errorf("Failed to count keywords: %v", err)
+	}
+	if err := utils.DB.Raw(`SELECT COUNT(DISTINCT article_id) FROM (`+keywordArticlesSQL+`) AS windowed`,
+		map[string]interface{}{"from": params.From, "to": params.To}).
+		Scan(&graph.Articles).Error; err != nil {
+		return nil, fmt.Errorf("Failed to count articles: %v", err)
+	}
+	keywordArticles := make(map[string]int64, len(counts))
+	for _, count := range counts {
+		keywordArticles[count.Word] = count.Articles
+	}
+
+	edgesOf := func(pairs []pairRow) []utils.GraphEdge {
+		edges := make([]utils.GraphEdge, 0, len(pairs))
+		for _, pair := range pairs {
+			edge := utils.GraphEdge{Source: pair.Source, Target: pair.Target, Articles: pair.Articles}
+			weigh(&edge, keywordArticles[pair.Source], keywordArticles[pair.Target], graph.Articles, params.Weighting)
+			if params.MinWeight == nil || edge.Weight >= *params.MinWeight {
+				edges = append(edges, edge)
+			}
+		}
+		sort.Slice(edges, func(i, j int) bool {
+			if edges[i].Weight != edges[j].Weight {
+				return edges[i].Weight > edges[j].Weight
+			}
+			if edges[i].Articles != edges[j].Articles {
+				return edges[i].Articles > edges[j].Articles
+			}
+			return edges[i].Source+"\x00"+edges[i].Target < edges[j].Source+"\x00"+edges[j].Target
+		})
+		return edges
+	}
+
+	if params.Seed == "" {
+		pairs, err := keywordPairs(params, "", nil)
+		if err != nil {
+			return nil, err
+		}
+		graph.Edges = edgesOf(pairs)
+		if len(graph.Edges) > params.Limit {
+			graph.Edges = graph.Edges[:params.Limit]
+		}
+	} else if _, ok := keywordArticles[params.Seed]; ok {
+		pairs, err := keywordPairs(params, "a.word = @seed OR b.word = @seed", map[string]interface{}{"seed": params.Seed})
+		if err != nil {
+			return nil, err
+		}
+		members := []string{params.Seed}
+		for i, edge := range edgesOf(pairs) {
+			if i == params.Limit {
+				break
+			}
+			if edge.Source == params.Seed {
+				members = append(members, edge.Target)
+			} else {
+				members = append(members, edge.Source)
+			}
+		}
+		if pairs, err = keywordPairs(params, "a.word IN @members AND b.word IN @members", map[string]interface{}{"members": members}); err != nil {
+			return nil, err
+		}
+		graph.Edges = edgesOf(pairs)
+		graph.Nodes = append(graph.Nodes, utils.GraphNode{ID: params.Seed, Articles: keywordArticles[params.Seed]})
+	}
+
+	seen := map[string]bool{params.Seed: params.Seed != ""}
+	for _, edge := range graph.Edges {
+		for _, word := range []string{edge.Source, edge.Target} {
+			if !seen[word] {
+				seen[word] = true
+				graph.Nodes = append(graph.Nodes, utils.GraphNode{ID: word, Articles: keywordArticles[word]})
+			}
+		}
+	}
+	return graph, nil
+}
+
+// GetKeywordGraph returns the keyword co-occurrence graph of the last window
+// (default 7d) or of from to to, as JSON or, with format=graphml or gexf, as
+// a file for tools such as Gephi. weight is pmi (default), jaccard or count.
+func GetKeywordGraph(c *gin.Context) {
+	params, err := parseGraphParams(c)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "graphml" && format != "gexf" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json, graphml or gexf"})
+		return
+	}
+
+	graph, err := BuildKeywordGraph(params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if params.Seed != "" && len(graph.Nodes) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Keyword %q is not in at least %d articles of the window", params.Seed, params.MinSupport)})
+		return
+	}
+
+	switch format {
+	case "graphml":
+		writeGraphFile(c, "application/graphml+xml", "keywords.graphml", toGraphML(graph))
+	case "gexf":
+		writeGraphFile(c, "application/gexf+xml", "keywords.gexf", toGEXF(graph))
+	default:
+		c.JSON(http.StatusOK, graph)
+	}
+}
@@ -0,0 +1,400 @@
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_news_api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Keyword time series intervals
+const (
+	IntervalHour = "hour"
+	IntervalDay  = "day"
+	IntervalWeek = "week"
+)
+
+// maxSeriesBuckets caps the points of one keyword time series
+const maxSeriesBuckets = 1000
+
+// maxCompareKeywords caps the keywords of one comparison
+const maxCompareKeywords = 10
+
+// defaultSeriesRange is how far back a time series reaches without from
+var defaultSeriesRange = map[string]time.Duration{
+	IntervalHour: 48 * time.Hour,
+	IntervalDay:  30 * 24 * time.Hour,
+	IntervalWeek: 26 * 7 * 24 * time.Hour,
+}
+
+// keywordBucket is the rollup hour of an article, as RebuildKeywordRollups
+// computes it in SQL
+func keywordBucket(article *utils.Article) time.Time {
+	t := time.Now()
+	if article.PublishedAt != nil {
+		t = *article.PublishedAt
+	} else if article.FirstSeenAt != nil {
+		t = *article.FirstSeenAt
+	}
+	return t.UTC().Truncate(time.Hour)
+}
+
+// countKeyword adds an article to the rollups of a keyword
+func countKeyword(tx *gorm.DB, keywordID, sourceID uint, bucket time.Time) error {
+	err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "keyword_id"}, {Name: "bucket"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"articles": gorm.Expr("keyword_rollups.articles + 1")}),
+	}).Create(&utils.KeywordRollup{KeywordID: keywordID, Bucket: bucket, Articles: 1}).Error
+	if err != nil {
+		return fmt.Errorf("Failed to count keyword: %v", err)
+	}
+	if sourceID == 0 {
+		return nil
+	}
+	err = tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&utils.KeywordSourceRollup{KeywordID: keywordID, Bucket: bucket, SourceID: sourceID}).Error
+	if err != nil {
+		return fmt.Errorf("Failed to count keyword source: %v", err)
+	}
+	return nil
+}
+
+// RederiveKeywords links every article to the keywords ExtractKeywords finds
+// in its title and description now, dropping the links it no longer finds,
+// then rebuilds the keyword rollups and deletes keywords no article is linked
+// to. Run it after changing how keywords are extracted.
+func RederiveKeywords(ctx context.Context) (int, error) {
+	count := 0
+	err := eachArticleBatch(ctx, utils.DB.Model(&utils.Article{}), func(articles []utils.Article) error {
+		return utils.DB.Transaction(func(tx *gorm.DB) error {
+			for i := range articles {
+				if err := rederiveArticleKeywords(tx, &articles[i]); err != nil {
+					return err
+				}
+			}
+			count += len(articles)
+			return nil
+		})
+	})
+	if err != nil {
+		return count, err
+	}
+	if err := utils.RebuildKeywordRollups(); err != nil {
+		return count, err
+	}
+	if err := utils.DB.Exec("DELETE FROM keywords WHERE NOT EXISTS (SELECT 1 FROM article_keywords WHERE article_keywords.keyword_id = keywords.id)").Error; err != nil {
+		return count, fmt.Errorf("Failed to delete unused keywords: %v", err)
+	}
+	return count, nil
+}
+
+// rederiveArticleKeywords replaces an article's keyword links with those of
+// its current text. The rollups are left to the caller to rebuild.
+func rederiveArticleKeywords(tx *gorm.DB, article *utils.Article) error {
+	words := ExtractKeywords(article.Title + " " + article.Description)
+	ids := make([]uint, 0, len(words))
+	for _, word := range words {
+		var keyword utils.Keyword
+		if err := tx.Where(utils.Keyword{Word: word}).FirstOrCreate(&keyword).Error; err != nil {
+			return fmt.Errorf("Failed to save keyword: %v", err)
+		}
+		ids = append(ids, keyword.ID)
+	}
+
+	var unlink *gorm.DB
+	if len(ids) == 0 {
+		unlink = tx.Exec("DELETE FROM article_keywords WHERE article_id = ?", article.ID)
+	} else {
+		unlink = tx.Exec("DELETE FROM article_keywords WHERE article_id = ? AND keyword_id NOT IN ?", article.ID, ids)
+	}
+	if err := unlink.Error; err != nil {
+		return fmt.Errorf("Failed to unlink keywords of article %d: %v", article.ID, err)
+	}
+	for _, id := range ids {
+		if err := tx.Exec("INSERT INTO article_keywords (article_id, keyword_id) VALUES (?, ?) ON CONFLICT DO NOTHING", article.ID, id).Error; err != nil {
+			return fmt.Errorf("Failed to associate keyword with article: %v", err)
+		}
+	}
+	return nil
+}
+
+// truncateToInterval returns the start (UTC) of the interval containing t.
+// Weeks start on Monday, as in Postgres.
+func truncateToInterval(t time.Time, interval string) time.Time {
+	t = t.UTC()
+	switch interval {
+	case IntervalHour:
+		return t.Truncate(time.Hour)
+	case IntervalWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// nextInterval returns the start of the interval after the one starting at t
+func nextInterval(t time.Time, interval string) time.Time {
+	switch interval {
+	case IntervalHour:
+		return t.Add(time.Hour)
+	case IntervalWeek:
+		return t.AddDate(0, 0, 7)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+// seriesParams are the parameters shared by the time series endpoints
+type seriesParams struct {
+	Interval string
+	From     time.Time
+	To       time.Time
+	Sources  bool
+}
+
+// parseSeriesParams reads the interval, from, to and sources query
+// parameters. from is moved back to the start of its interval.
+func parseSeriesParams(c *gin.Context) (seriesParams, error) {
+	params := seriesParams{Interval: c.DefaultQuery("interval", IntervalDay), To: time.Now().UTC()}
+	rangeBack, ok := defaultSeriesRange[params.Interval]
+	if !ok {
+		return params, fmt.Errorf("%w: interval must be %s, %s or %s", ErrBadRequest, IntervalHour, IntervalDay, IntervalWeek)
+	}
+	if to := c.Query("to"); to != "" {
+		parsed := utils.ParsePublishedAt(to)
+		if parsed == nil {
+			return params, fmt.Errorf("%w: invalid to date %q", ErrBadRequest, to)
+		}
+		params.To = parsed.UTC()
+	}
+	params.From = params.To.Add(-rangeBack)
+	if from := c.Query("from"); from != "" {
+		parsed := utils.ParsePublishedAt(from)
+		if parsed == nil {
+			return params, fmt.Errorf("%w: invalid from date %q", ErrBadRequest, from)
+		}
+		params.From = parsed.UTC()
+	}
+	if params.To.Before(params.From) {
+		return params, fmt.Errorf("%w: to must not be before from", ErrBadRequest)
+	}
+	params.From = truncateToInterval(params.From, params.Interval)
+
+	buckets := 0
+	for t := params.From; !t.After(params.To); t = nextInterval(t, params.Interval) {
+		if buckets++; buckets > maxSeriesBuckets {
+			return params, fmt.Errorf("%w: more than %d %ss between from and to; narrow the range or use a longer interval", ErrBadRequest, maxSeriesBuckets, params.Interval)
+		}
+	}
+
+	params.Sources, _ = strconv.ParseBool(c.DefaultQuery("sources", "false"))
+	return params, nil
+}
+
+type keywordBucketRow struct {
+	Word   string
+	Period time.Time
+	Count  int64
+}
+
+// keywordBuckets sums a rollup table per keyword and interval. The interval
+// is named period so it cannot be confused with the bucket column.
+func keywordBuckets(table, count string, words []string, params seriesParams) ([]keywordBucketRow, error) {
+	var rows []keywordBucketRow
+	err := utils.DB.Table(table+" AS r").
+		Select("keywords.word, date_trunc(?, r.bucket AT TIME ZONE 'UTC') AT TIME ZONE 'UTC' AS period, "+count+" AS count", params.Interval).
+		Joins("JOIN keywords ON keywords.id = r.keyword_id AND keywords.deleted_at IS NULL").
+		Where("keywords.word IN ? AND r.bucket >= ? AND r.bucket <= ?", words, params.From, params.To).
+		Group("keywords.word, period").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// keywordSeries builds the zero-filled time series of each keyword
+func keywordSeries(words []string, params seriesParams) ([]utils.KeywordSeries, error) {
+	articles, err := keywordBuckets("keyword_rollups", "SUM(r.articles)", words, params)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load keyword counts: %v", err)
+	}
+	var sources []keywordBucketRow
+	if params.Sources {
+		if sources, err = keywordBuckets("keyword_source_rollups", "COUNT(DISTINCT r.source_id)", words, params); err != nil {
+			return nil, fmt.Errorf("Failed to load keyword sources: %v", err)
+		}
+	}
+
+	type key struct {
+		word   string
+		period int64
+	}
+	articleCounts := map[key]int64{}
+	for _, row := range articles {
+		articleCounts[key{row.Word, row.Period.Unix()}] = row.Count
+	}
+	sourceCounts := map[key]int64{}
+	for _, row := range sources {
+		sourceCounts[key{row.Word, row.Period.Unix()}] = row.Count
+	}
+
+	series := make([]utils.KeywordSeries, len(words))
+	for i, word := range words {
+		series[i] = utils.KeywordSeries{Keyword: word, Points: []utils.KeywordPoint{}}
+		for t := params.From; !t.After(params.To); t = nextInterval(t, params.Interval) {
+			point := utils.KeywordPoint{Bucket: t, Articles: articleCounts[key{word, t.Unix()}]}
+			if params.Sources {
+				count := sourceCounts[key{word, t.Unix()}]
+				point.Sources = &count
+			}
+			series[i].Total += point.Articles
+			series[i].Points = append(series[i].Points, point)
+		}
+	}
+	return series, nil
+}
+
+// normalizeKeyword reduces a keyword parameter to how keywords are stored
+func normalizeKeyword(word string) string {
+	return strings.ToLower(strings.TrimSpace(word))
+}
+
+// GetKeywordTimeSeries returns the number of articles mentioning a keyword
+// per hour, day or week between from and to
+func GetKeywordTimeSeries(c *gin.Context) {
+	word := normalizeKeyword(c.Param("word"))
+	if word == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Keyword is required"})
+		return
+	}
+	params, err := parseSeriesParams(c)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+	series, err := keywordSeries([]string{word}, params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"keyword":  word,
+		"interval": params.Interval,
+		"from":     params.From,
+		"to":       params.To,
+		"total":    series[0].Total,
+		"points":   series[0].Points,
+	})
+}
+
+// CompareKeywordTimeSeries returns the time series of several keywords
+// (words, comma-separated) over the same buckets
+func CompareKeywordTimeSeries(c *gin.Context) {
+	var words []string
+	seen := map[string]bool{}
+	for _, raw := range strings.Split(c.Query("words"), ",") {
+		if word := normalizeKeyword(raw); word != "" && !seen[word] {
+			seen[word] = true
+			words = append(words, word)
+		}
+	}
+	if len(words) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "words is required"})
+		return
+	}
+	if len(words) > maxCompareKeywords {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d keywords can be compared", maxCompareKeywords)})
+		return
+	}
+	params, err := parseSeriesParams(c)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+	series, err := keywordSeries(words, params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"interval": params.Interval,
+		"from":     params.From,
+		"to":       params.To,
+		"series":   series,
+	})
+}
+
+// GetTopKeywords ranks keywords by the articles mentioning them in the last
+// window (default 24h, in whole hours), or with by=growth by how much that
+// rose from the window before. Growth rankings ignore keywords with fewer
+// than min_articles articles (default 3).
+func GetTopKeywords(c *gin.Context) {
+	window, err := parseWindow(c.DefaultQuery("window", "24h"))
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+	if window = window.Truncate(time.Hour); window == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "window must be at least 1h"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+		return
+	}
+	minArticles, err := strconv.Atoi(c.DefaultQuery("min_articles", "3"))
+	if err != nil || minArticles < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "min_articles must be a positive number"})
+		return
+	}
+	by := c.DefaultQuery("by", "volume")
+	if by != "volume" && by != "growth" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "by must be volume or growth"})
+		return
+	}
+
+	// Both windows cover whole hours, the current one up to the end of this hour
+	end := time.Now().UTC().Truncate(time.Hour).Add(time.Hour)
+	start := end.Add(-window)
+	previousStart := start.Add(-window)
+
+	totals := utils.DB.Table("keyword_rollups").
+		Select(`keywords.word,
+			SUM(CASE WHEN keyword_rollups.bucket >= ? THEN keyword_rollups.articles ELSE 0 END) AS current_articles,
+			SUM(CASE WHEN keyword_rollups.bucket < ? THEN keyword_rollups.articles ELSE 0 END) AS previous_articles`, start, start).
+		Joins("JOIN keywords ON keywords.id = keyword_rollups.keyword_id AND keywords.deleted_at IS NULL").
+		Where("keyword_rollups.bucket >= ? AND keyword_rollups.bucket < ?", previousStart, end).
+		Group("keywords.word")
+	query := utils.DB.Table("(?) AS totals", totals).
+		Select("totals.*, (current_articles - previous_articles)::float8 / GREATEST(previous_articles, 1) AS growth").
+		Where("current_articles > 0")
+	if by == "growth" {
+		query = query.Where("current_articles >= ?", minArticles).Order("growth DESC, current_articles DESC")
+	} else {
+		query = query.Order("current_articles DESC, growth DESC")
+	}
+
+	var keywords []utils.TopKeyword
+	if err := query.Order("word").Limit(limit).Scan(&keywords).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"window":   c.DefaultQuery("window", "24h"),
+		"by":       by,
+		"from":     start,
+		"to":       end,
+		"keywords": keywords,
+	})
+}
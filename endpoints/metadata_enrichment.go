@@ -0,0 +1,332 @@
+package endpoints
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go_news_api/utils"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Article fields that enrichment may fill, as recorded in EnrichedFields
+const (
+	FieldImage       = "urlToImage"
+	FieldAuthor      = "author"
+	FieldDescription = "description"
+	FieldPublishedAt = "publishedAt"
+)
+
+// vagueDescriptionLength is the length below which a provider description is
+// treated as missing
+const vagueDescriptionLength = 40
+
+// PageMetadata is what Open Graph, Twitter Card and JSON-LD tags say about a page
+type PageMetadata struct {
+	Title       string
+	Description string
+	Image       string
+	Authors     []string
+	Section     string
+	Keywords    []string
+	PublishedAt *time.Time
+	ModifiedAt  *time.Time
+}
+
+// newsArticleTypes are the schema.org types we read JSON-LD metadata from
+var newsArticleTypes = map[string]bool{
+	"NewsArticle":          true,
+	"Article":              true,
+	"ReportageNewsArticle": true,
+	"AnalysisNewsArticle":  true,
+	"OpinionNewsArticle":   true,
+	"BlogPosting":          true,
+	"LiveBlogPosting":      true,
+}
+
+// ExtractMetadata reads Open Graph, Twitter Card and JSON-LD NewsArticle
+// metadata from a parsed page. JSON-LD wins over meta tags when both are set.
+func ExtractMetadata(doc *goquery.Document, pageURL string) *PageMetadata {
+	base, _ := url.Parse(pageURL)
+
+	meta := &PageMetadata{
+		Title:       metaContent(doc, "og:title", "twitter:title"),
+		Description: metaContent(doc, "og:description", "twitter:description", "description"),
+		Image:       metaContent(doc, "og:image", "og:image:url", "twitter:image", "twitter:image:src"),
+		Section:     metaContent(doc, "article:section"),
+	}
+	if t, ok := parseLooseDate(metaContent(doc, "article:published_time", "og:published_time")); ok {
+		meta.PublishedAt = &t
+	}
+	if t, ok := parseLooseDate(metaContent(doc, "article:modified_time", "og:updated_time")); ok {
+		meta.ModifiedAt = &t
+	}
+
+	// article:author is often a profile URL; only names are useful here
+	for _, author := range metaContents(doc, "article:author", "author") {
+		if !strings.HasPrefix(author, "http") {
+			meta.Authors = appendUnique(meta.Authors, author)
+		}
+	}
+	if creator := metaContent(doc, "twitter:creator"); creator != "" && len(meta.Authors) == 0 {
+		meta.Authors = append(meta.Authors, creator)
+	}
+	for _, tag := range metaContents(doc, "article:tag") {
+		meta.Keywords = appendUnique(meta.Keywords, tag)
+	}
+	for _, list := range metaContents(doc, "news_keywords", "keywords") {
+		for _, keyword := range strings.Split(list, ",") {
+			meta.Keywords = appendUnique(meta.Keywords, keyword)
+		}
+	}
+
+	if article := findJSONLDArticle(doc); article != nil {
+		applyJSONLD(meta, article)
+	}
+
+	meta.Image = resolveURL(base, meta.Image)
+	return meta
+}
+
+// findJSONLDArticle returns the first JSON-LD object of a news article type
+func findJSONLDArticle(doc *goquery.Document) map[string]interface{} {
+	var found map[string]interface{}
+	doc.Find(`script[type="application/ld+json"]`).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		var data interface{}
+		if err := json.Unmarshal([]byte(s.Text()), &data); err != nil {
+			return true
+		}
+		found = findTypedObject(data)
+		return found == nil
+	})
+	return found
+}
+
+func findTypedObject(data interface{}) map[string]interface{} {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		for _, t := range jsonLDStrings(v["@type"]) {
+			if newsArticleTypes[t] {
+				return v
+			}
+		}
+		if graph, ok := v["@graph"]; ok {
+			return findTypedObject(graph)
+		}
+	case []interface{}:
+		for _, item := range v {
+			if obj := findTypedObject(item); obj != nil {
+				return obj
+			}
+		}
+	}
+	return nil
+}
+
+func applyJSONLD(meta *PageMetadata, article map[string]interface{}) {
+	if headline := firstString(article["headline"]); headline != "" {
+		meta.Title = headline
+	}
+	if description := firstString(article["description"]); description != "" {
+		meta.Description = description
+	}
+	if image := jsonLDStrings(article["image"]); len(image) > 0 {
+		meta.Image = image[0]
+	} else if image := jsonLDStrings(article["thumbnailUrl"]); len(image) > 0 {
+		meta.Image = image[0]
+	}
+	if authors := jsonLDStrings(article["author"]); len(authors) > 0 {
+		meta.Authors = nil
+		for _, author := range authors {
+			if !strings.HasPrefix(author, "http") {
+				meta.Authors = appendUnique(meta.Authors, author)
+			}
+		}
+	}
+	if section := jsonLDStrings(article["articleSection"]); len(section) > 0 {
+		meta.Section = section[0]
+	}
+	for _, keywords := range jsonLDStrings(article["keywords"]) {
+		for _, keyword := range strings.Split(keywords, ",") {
+			meta.Keywords = appendUnique(meta.Keywords, keyword)
+		}
+	}
+	if t, ok := parseLooseDate(firstString(article["datePublished"])); ok {
+		meta.PublishedAt = &t
+	}
+	if t, ok := parseLooseDate(firstString(article["dateModified"])); ok {
+		meta.ModifiedAt = &t
+	}
+}
+
+// jsonLDStrings flattens a JSON-LD value that may be a string, an object with
+// a name or url, or a list of those
+func jsonLDStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		if v = strings.TrimSpace(v); v != "" {
+			return []string{v}
+		}
+	case map[string]interface{}:
+		for _, key := range []string{"name", "url", "@id"} {
+			if s, ok := v[key].(string); ok && strings.TrimSpace(s) != "" {
+				return []string{strings.TrimSpace(s)}
+			}
+		}
+	case []interface{}:
+		var out []string
+		for _, item := range v {
+			out = append(out, jsonLDStrings(item)...)
+		}
+		return out
+	}
+	return nil
+}
+
+func firstString(value interface{}) string {
+	if values := jsonLDStrings(value); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// metaContents returns every non-empty <meta> content for the given keys
+func metaContents(doc *goquery.Document, keys ...string) []string {
+	var values []string
+	doc.Find("meta[content]").Each(func(i int, s *goquery.Selection) {
+		for _, attr := range []string{"property", "name"} {
+			for _, key := range keys {
+				if strings.EqualFold(s.AttrOr(attr, ""), key) {
+					if value := strings.TrimSpace(s.AttrOr("content", "")); value != "" {
+						values = append(values, value)
+					}
+				}
+			}
+		}
+	})
+	return values
+}
+
+func appendUnique(list []string, value string) []string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return list
+	}
+	for _, existing := range list {
+		if strings.EqualFold(existing, value) {
+			return list
+		}
+	}
+	return append(list, value)
+}
+
+// ApplyEnrichment fills an article's missing fields from page metadata and
+// records what came from the page. Fields the provider supplied are never
+// touched, while fields filled by an earlier enrichment are refreshed, so
+// running it again for the same page gives the same result.
+func ApplyEnrichment(tx *gorm.DB, article *utils.Article, meta *PageMetadata) (*utils.ArticleEnrichment, error) {
+	var enrichment utils.ArticleEnrichment
+	if err := tx.Where(utils.ArticleEnrichment{ArticleID: article.ID}).FirstOrInit(&enrichment).Error; err != nil {
+		return nil, err
+	}
+
+	previous := map[string]bool{}
+	for _, field := range strings.Split(enrichment.EnrichedFields, ",") {
+		previous[field] = true
+	}
+	var filled []string
+	fill := func(field string, current *string, value string, missing bool) {
+		if value == "" || !(missing || previous[field]) {
+			return
+		}
+		*current = value
+		filled = append(filled, field)
+	}
+
+	fill(FieldImage, &article.URLToImage, meta.Image, article.URLToImage == "")
+	fill(FieldAuthor, &article.Author, strings.Join(meta.Authors, ", "), article.Author == "")
+	fill(FieldDescription, &article.Description, meta.Description, len(article.Description) < vagueDescriptionLength && len(meta.Description) > len(article.Description))
+	if meta.PublishedAt != nil && (article.PublishedAt == nil || previous[FieldPublishedAt]) {
+		article.PublishedAt = meta.PublishedAt
+		filled = append(filled, FieldPublishedAt)
+	}
+
+	if len(filled) > 0 {
+		// Keep the hash in step so the next fetch does not see a change
+		article.ContentHash = ArticleContentHash(article)
+		if err := tx.Model(article).Select("url_to_image", "author", "description", "published_at", "content_hash").Updates(article).Error; err != nil {
+			return nil, fmt.Errorf("failed to update enriched article: %v", err)
+		}
+	}
+
+	enrichment.Authors = strings.Join(meta.Authors, ", ")
+	enrichment.Section = meta.Section
+	enrichment.Keywords = strings.Join(meta.Keywords, ", ")
+	enrichment.Image = meta.Image
+	enrichment.PublishedAt = meta.PublishedAt
+	enrichment.ModifiedAt = meta.ModifiedAt
+	enrichment.EnrichedFields = strings.Join(filled, ",")
+	enrichment.EnrichedAt = time.Now()
+	if err := tx.Save(&enrichment).Error; err != nil {
+		return nil, fmt.Errorf("failed to save enrichment: %v", err)
+	}
+
+	article.Enrichment = &enrichment
+	return &enrichment, nil
+}
+
+// keepsEnrichedDescription reports whether an article's description was
+// filled by enrichment and the provider still sends only a vague one, which
+// enrichment would replace again
+func keepsEnrichedDescription(tx *gorm.DB, article *utils.Article, description string) (bool, error) {
+	if len(description) >= vagueDescriptionLength || description == article.Description {
+		return false, nil
+	}
+	var enrichment utils.ArticleEnrichment
+	result := tx.Where(utils.ArticleEnrichment{ArticleID: article.ID}).Limit(1).Find(&enrichment)
+	if result.Error != nil {
+		return false, fmt.Errorf("Failed to load article enrichment: %v", result.Error)
+	}
+	for _, field := range strings.Split(enrichment.EnrichedFields, ",") {
+		if field == FieldDescription {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// EnrichArticle fetches an article's page and applies its metadata
+func EnrichArticle(ctx context.Context, article *utils.Article) (*utils.ArticleEnrichment, error) {
+	resp, err := pageFetcher.Fetch(ctx, article.URL)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(resp.Body))
+	if err != nil {
+		return nil, err
+	}
+	return ApplyEnrichment(utils.DB, article, ExtractMetadata(doc, article.URL))
+}
+
+// EnrichArticleByID re-runs enrichment for one stored article
+func EnrichArticleByID(c *gin.Context) {
+	article, ok := findArticle(c, "Source")
+	if !ok {
+		return
+	}
+
+	if _, err := EnrichArticle(c.Request.Context(), article); err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, article)
+}
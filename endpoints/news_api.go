@@ -1,95 +1,64 @@
 package endpoints
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"net/url"
-	"os"
 	"strings"
 	"time"
 
 	"go_news_api/utils"
 )
 
-// GetNewsAPITopHeadlinesByCategory fetches top headlines from News API
-func GetNewsAPITopHeadlinesByCategory(country, category string) (*utils.APIResponse, error) {
-	// Check and reset request count if necessary
-	if err := CheckRequestLimit(); err != nil {
-		return nil, err
-	}
-
-	url := fmt.Sprintf("https://newsapi.org/v2/top-headlines?country=%s&category=%s&apiKey=%s", country, category, os.Getenv("NEWS_API_KEY"))
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
+const newsAPIBaseURL = "https://newsapi.org/v2"
 
-	// Check the response content type
-	contentType := resp.Header.Get("Content-Type")
-	if strings.Contains(contentType, "application/xml") {
-		// Handle XML response
-		return nil, fmt.Errorf("received XML response from NewsAPI: %s", string(body))
+// GetNewsAPITopHeadlinesByCategory fetches top headlines from News API. News
+// API cannot filter headlines by language, so language is not sent.
+func GetNewsAPITopHeadlinesByCategory(ctx context.Context, country, category, language string) (*utils.APIResponse, error) {
+	params := url.Values{}
+	if country != "" {
+		params.Set("country", country)
 	}
+	params.Set("category", category)
 
-	var newsAPIResponse utils.NewsAPIResponse
-	err = json.Unmarshal(body, &newsAPIResponse)
+	newsAPIResponse, err := fetchNewsAPI(ctx, "/top-headlines", params)
 	if err != nil {
 		return nil, err
 	}
 
-	var articles []utils.Article
-	for _, article := range newsAPIResponse.Articles {
-		articles = append(articles, utils.Article{
-			Source:      article.Source,
-			Author:      article.Author,
-			Title:       article.Title,
-			Description: article.Description,
-			URL:         article.URL,
-			PublishedAt: article.PublishedAt,
-			Content:     article.Content,
-		})
-	}
-
 	apiResponse := &utils.APIResponse{
 		Status:       newsAPIResponse.Status,
 		TotalResults: newsAPIResponse.TotalResults,
-		Articles:     articles,
+		Articles:     newsAPIArticles(newsAPIResponse),
 		APISource:    "newsapi",
+		Country:      country,
 	}
 
 	return apiResponse, nil
 }
 
-// GetNewsAPITrendingTopicsNews fetches news for trending topics from News API
-func GetNewsAPITrendingTopicsNews(topics []utils.TrendingTopic) (*utils.APIResponse, error) {
-	// Check and reset request count if necessary
-	if err := CheckRequestLimit(); err != nil {
-		return nil, err
+// GetNewsAPITrendingTopicsNews fetches news for trending topics from News API.
+// News API cannot search by country, so country is not sent.
+func GetNewsAPITrendingTopicsNews(ctx context.Context, topics []utils.TrendingTopic, country, language string) (*utils.APIResponse, error) {
+	if language == "" {
+		language = "en"
 	}
 
 	var newsAPIResponses []utils.APIResponse
 	for _, topic := range topics {
 		// Check if we've already made this request recently
 		var existingRequest utils.NewsAPIRequest
-		if err := utils.DB.Where("topic = ? AND source = ? AND requested_at > ?", topic.Topic, "newsapi", time.Now().AddDate(0, 0, -7)).First(&existingRequest).Error; err == nil {
+		if err := utils.DB.Where("topic = ? AND source = ? AND language = ? AND requested_at > ?", topic.Topic, "newsapi", language, time.Now().AddDate(0, 0, -7)).First(&existingRequest).Error; err == nil {
 			// We've already made this request in the last week, skip it
 			continue
 		}
 
 		// Prepare the search query
-		query := url.QueryEscape(strings.Join(strings.Fields(topic.Topic), " OR "))
+		query := strings.Join(strings.Fields(topic.Topic), " OR ")
 
 		// Make API call for each trending topic
-		newsAPIResponse, err := GetNewsAPIEverythingByTopic(query)
+		newsAPIResponse, err := GetNewsAPIEverythingByTopic(ctx, query, language)
 		if err != nil {
 			return nil, err
 		}
@@ -102,6 +71,7 @@ func GetNewsAPITrendingTopicsNews(topics []utils.TrendingTopic) (*utils.APIRespo
 		utils.DB.Create(&utils.NewsAPIRequest{
 			Topic:       topic.Topic,
 			Source:      "newsapi",
+			Language:    language,
 			RequestedAt: time.Now(),
 		})
 	}
@@ -115,6 +85,7 @@ func GetNewsAPITrendingTopicsNews(topics []utils.TrendingTopic) (*utils.APIRespo
 			APISource:    "newsapi",
 			Type:         "topic",
 			Topic:        strings.Join(GetTopicNames(topics), ", "),
+			Language:     language,
 		}, nil
 	}
 
@@ -129,57 +100,110 @@ func GetNewsAPITrendingTopicsNews(topics []utils.TrendingTopic) (*utils.APIRespo
 		APISource:    "newsapi",
 		Type:         "topic",
 		Topic:        strings.Join(GetTopicNames(topics), ", "),
+		Language:     language,
 	}
 
 	return apiResponse, nil
 }
 
-// getNewsAPIEverythingByTopic fetches everything from News API for a given topic
-func GetNewsAPIEverythingByTopic(topic string) (*utils.APIResponse, error) {
-	apiKey := os.Getenv("NEWS_API_KEY")
-	url := fmt.Sprintf("https://newsapi.org/v2/everything?q=%s&from=%s&to=%s&sortBy=popularity&apiKey=%s&language=en",
-		topic, utils.GetLastWeekDate(), utils.GetTodayDate(), apiKey)
-
-	resp, err := http.Get(url)
+// GetNewsAPIEverythingByTopic fetches everything from News API for a given
+// topic in the given language
+func GetNewsAPIEverythingByTopic(ctx context.Context, topic, language string) (*utils.APIResponse, error) {
+	params := url.Values{}
+	params.Set("q", topic)
+	params.Set("from", utils.GetLastWeekDate())
+	params.Set("to", utils.GetTodayDate())
+	params.Set("sortBy", "popularity")
+	params.Set("language", language)
+
+	newsAPIResponse, err := fetchNewsAPI(ctx, "/everything", params)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	log.Printf("NewsAPI returned %d articles for topic '%s'", len(newsAPIResponse.Articles), topic)
+
+	apiResponse := &utils.APIResponse{
+		Status:       newsAPIResponse.Status,
+		TotalResults: newsAPIResponse.TotalResults,
+		Articles:     newsAPIArticles(newsAPIResponse),
+		APISource:    "newsapi",
+		Language:     language,
 	}
 
-	// Log the response for debugging
-	log.Printf("NewsAPI response for topic '%s': %s", topic, string(body))
+	return apiResponse, nil
+}
 
+// fetchNewsAPI calls a News API endpoint and decodes the response, turning
+// error payloads into a ProviderError and rotating API keys on rate limit or
+// auth errors
+func fetchNewsAPI(ctx context.Context, path string, params url.Values) (*utils.NewsAPIResponse, error) {
 	var newsAPIResponse utils.NewsAPIResponse
-	err = json.Unmarshal(body, &newsAPIResponse)
-	if err != nil {
+	if err := getNewsAPIJSON(ctx, path, params, &newsAPIResponse); err != nil {
+		return nil, err
+	}
+	return &newsAPIResponse, nil
+}
+
+// GetNewsAPISources fetches News API's source catalogue. country, language
+// and category may be empty.
+func GetNewsAPISources(ctx context.Context, country, language, category string) ([]utils.NewsAPISource, error) {
+	params := url.Values{}
+	for key, value := range map[string]string{"country": country, "language": language, "category": category} {
+		if value != "" {
+			params.Set(key, value)
+		}
+	}
+
+	var sourcesResponse utils.NewsAPISourcesResponse
+	if err := getNewsAPIJSON(ctx, "/top-headlines/sources", params, &sourcesResponse); err != nil {
 		return nil, err
 	}
+	return sourcesResponse.Sources, nil
+}
 
+// getNewsAPIJSON calls a News API endpoint and decodes the response into v
+func getNewsAPIJSON(ctx context.Context, path string, params url.Values, v interface{}) error {
+	var resp *ProviderResponse
+	err := newsAPIKeys.Do(func(key *APIKey) error {
+		params.Set("apiKey", key.Value)
+		var err error
+		resp, err = newsAPIClient.Get(ctx, newsAPIBaseURL+path+"?"+params.Encode())
+		if err != nil {
+			return err
+		}
+		return checkNewsAPIResponse(resp)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(resp.Body, v); err != nil {
+		return &ProviderError{
+			Provider:   "newsapi",
+			StatusCode: resp.StatusCode,
+			Message:    "failed to unmarshal JSON: " + err.Error(),
+			Kind:       ErrInvalidResponse,
+		}
+	}
+	return nil
+}
+
+// newsAPIArticles maps News API articles onto our Article model
+func newsAPIArticles(newsAPIResponse *utils.NewsAPIResponse) []utils.Article {
 	var articles []utils.Article
 	for _, article := range newsAPIResponse.Articles {
 		articles = append(articles, utils.Article{
-			Source:      article.Source,
-			Author:      article.Author,
-			Title:       article.Title,
-			Description: article.Description,
-			URL:         article.URL,
-			PublishedAt: article.PublishedAt,
-			Content:     article.Content,
-			URLToImage:  article.URLToImage,
+			Source:         article.Source,
+			Author:         article.Author,
+			Title:          article.Title,
+			Description:    article.Description,
+			URL:            article.URL,
+			URLToImage:     article.URLToImage,
+			PublishedAt:    utils.ParsePublishedAt(article.PublishedAt),
+			PublishedAtRaw: article.PublishedAt,
+			Content:        article.Content,
 		})
 	}
-
-	apiResponse := &utils.APIResponse{
-		Status:       newsAPIResponse.Status,
-		TotalResults: newsAPIResponse.TotalResults,
-		Articles:     articles,
-		APISource:    "newsapi",
-	}
-
-	return apiResponse, nil
+	return articles
 }
@@ -0,0 +1,153 @@
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go_news_api/utils"
+)
+
+// ErrDisallowedByRobots is returned when robots.txt forbids fetching a page
+var ErrDisallowedByRobots = errors.New("disallowed by robots.txt")
+
+// PageFetcher downloads article pages politely: it honors robots.txt
+// (including Crawl-delay) and spaces out requests to the same host
+type PageFetcher struct {
+	MinDomainInterval time.Duration
+	RobotsTTL         time.Duration
+	// RobotsErrorTTL is how long a host whose robots.txt could not be
+	// fetched is left alone before trying again
+	RobotsErrorTTL time.Duration
+
+	client *ProviderClient
+
+	mu       sync.Mutex
+	nextSlot map[string]time.Time
+	robots   *utils.LRU[string, *cachedRobots]
+}
+
+// cachedRobots is a host's robots.txt rules, or the error fetching it
+type cachedRobots struct {
+	rules     *robotsRules
+	err       error
+	expiresAt time.Time
+}
+
+var pageFetcher *PageFetcher
+
+// NewPageFetcher creates a fetcher using the scraper client settings
+func NewPageFetcher() *PageFetcher {
+	return &PageFetcher{
+		MinDomainInterval: utils.GetEnvDuration("FETCH_DOMAIN_INTERVAL", 5*time.Second),
+		RobotsTTL:         utils.GetEnvDuration("ROBOTS_TTL", 24*time.Hour),
+		RobotsErrorTTL:    utils.GetEnvDuration("ROBOTS_ERROR_TTL", 10*time.Minute),
+		client:            NewProviderClient("pages", utils.GetEnvDuration("SCRAPER_TIMEOUT", 15*time.Second)),
+		nextSlot:          make(map[string]time.Time),
+		robots:            utils.NewLRU[string, *cachedRobots](1024),
+	}
+}
+
+// Fetch downloads pageURL once robots.txt allows it and the host's rate limit
+// slot has come up
+func (f *PageFetcher) Fetch(ctx context.Context, pageURL string) (*ProviderResponse, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid article URL %q", pageURL)
+	}
+
+	rules, err := f.robotsFor(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	if !rules.Allowed(path) {
+		return nil, ErrDisallowedByRobots
+	}
+
+	if err := f.wait(ctx, u.Host, rules.crawlDelay); err != nil {
+		return nil, err
+	}
+
+	resp, err := f.client.Get(ctx, pageURL)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, &ProviderError{
+			Provider:   u.Host,
+			StatusCode: resp.StatusCode,
+			Message:    "failed to fetch " + pageURL,
+			Kind:       kindForStatus(resp.StatusCode),
+		}
+	}
+	return resp, nil
+}
+
+// robotsFor returns the cached robots.txt rules for the URL's host, fetching
+// them when missing or expired. A missing robots.txt allows all and an
+// access-restricted one allows nothing. While robots.txt is unreachable
+// (a transport error or a 5xx) nothing may be fetched either: that error is
+// returned, and cached for RobotsErrorTTL so the host is not asked again at
+// once. A cancelled context is returned without caching anything.
+func (f *PageFetcher) robotsFor(ctx context.Context, u *url.URL) (*robotsRules, error) {
+	origin := u.Scheme + "://" + u.Host
+	if cached, ok := f.robots.Get(origin); ok && time.Now().Before(cached.expiresAt) {
+		return cached.rules, cached.err
+	}
+
+	if err := f.wait(ctx, u.Host, 0); err != nil {
+		return nil, err
+	}
+	resp, err := f.client.Get(ctx, origin+"/robots.txt")
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	cached := &cachedRobots{rules: &robotsRules{}, expiresAt: time.Now().Add(f.RobotsTTL)}
+	switch {
+	case err != nil:
+		cached.rules, cached.err = nil, fmt.Errorf("robots.txt of %s is unreachable: %w", u.Host, err)
+	case resp.StatusCode >= 500:
+		cached.rules, cached.err = nil, fmt.Errorf("%w: robots.txt of %s returned %d", ErrUpstreamUnavailable, u.Host, resp.StatusCode)
+	case resp.StatusCode == http.StatusOK:
+		cached.rules = parseRobots(resp.Body)
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		// Access-restricted robots.txt means the whole site is off limits
+		cached.rules = &robotsRules{disallow: []string{"/"}}
+	}
+	if cached.err != nil {
+		cached.expiresAt = time.Now().Add(f.RobotsErrorTTL)
+	}
+
+	f.robots.Add(origin, cached)
+	return cached.rules, cached.err
+}
+
+// wait blocks until the host may be contacted again and reserves the next slot
+func (f *PageFetcher) wait(ctx context.Context, host string, crawlDelay time.Duration) error {
+	interval := max(f.MinDomainInterval, crawlDelay)
+	host = strings.ToLower(host)
+
+	f.mu.Lock()
+	now := time.Now()
+	slot := f.nextSlot[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	f.nextSlot[host] = slot.Add(interval)
+	f.mu.Unlock()
+
+	return sleepContext(ctx, time.Until(slot))
+}
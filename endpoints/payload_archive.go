@@ -0,0 +1,511 @@
+package endpoints
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_news_api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PayloadArchive keeps upstream responses: the gzipped body in a store and
+// the request metadata in the raw_payloads table. Responses are archived in
+// the background from a bounded queue, so that fetches do not wait on it.
+type PayloadArchive struct {
+	store   PayloadStore
+	timeout time.Duration
+	queue   chan archivedResponse
+}
+
+// archivedResponse is a response waiting in the archive queue
+type archivedResponse struct {
+	provider string
+	rawURL   string
+	resp     *ProviderResponse
+	attempt  int
+	started  time.Time
+	duration time.Duration
+}
+
+// NewPayloadArchive configures the archive from the environment.
+// PAYLOAD_ARCHIVE selects the store: postgres (the default) keeps bodies as
+// large objects, fs as files under PAYLOAD_ARCHIVE_DIR, s3 in an
+// S3-compatible bucket, and off disables archiving. A nil archive archives
+// nothing. Up to PAYLOAD_ARCHIVE_QUEUE responses wait to be archived.
+func NewPayloadArchive() (*PayloadArchive, error) {
+	archive := &PayloadArchive{
+		timeout: utils.GetEnvDuration("PAYLOAD_ARCHIVE_TIMEOUT", 5*time.Second),
+		queue:   make(chan archivedResponse, utils.GetEnvInt("PAYLOAD_ARCHIVE_QUEUE", 1000)),
+	}
+	switch kind := os.Getenv("PAYLOAD_ARCHIVE"); kind {
+	case "", "postgres":
+		archive.store = postgresPayloadStore{}
+	case "fs":
+		dir := os.Getenv("PAYLOAD_ARCHIVE_DIR")
+		if dir == "" {
+			dir = "archive"
+		}
+		archive.store = &fsPayloadStore{dir: dir}
+	case "s3":
+		store := &s3PayloadStore{
+			endpoint:  strings.TrimRight(os.Getenv("PAYLOAD_ARCHIVE_S3_ENDPOINT"), "/"),
+			bucket:    os.Getenv("PAYLOAD_ARCHIVE_S3_BUCKET"),
+			region:    os.Getenv("PAYLOAD_ARCHIVE_S3_REGION"),
+			accessKey: os.Getenv("PAYLOAD_ARCHIVE_S3_ACCESS_KEY"),
+			secretKey: os.Getenv("PAYLOAD_ARCHIVE_S3_SECRET_KEY"),
+			client:    &http.Client{Timeout: archive.timeout},
+		}
+		if store.endpoint == "" || store.bucket == "" || store.accessKey == "" || store.secretKey == "" {
+			return nil, errors.New("PAYLOAD_ARCHIVE_S3_ENDPOINT, PAYLOAD_ARCHIVE_S3_BUCKET, PAYLOAD_ARCHIVE_S3_ACCESS_KEY and PAYLOAD_ARCHIVE_S3_SECRET_KEY are required")
+		}
+		if store.region == "" {
+			store.region = "us-east-1"
+		}
+		archive.store = store
+	case "off":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown PAYLOAD_ARCHIVE %q (postgres, fs, s3 or off)", kind)
+	}
+	return archive, nil
+}
+
+// archivedHeaders drops the response headers not worth keeping
+func archivedHeaders(header http.Header) map[string][]string {
+	kept := make(map[string][]string, len(header))
+	for name, values := range header {
+		if name != "Set-Cookie" {
+			kept[name] = values
+		}
+	}
+	return kept
+}
+
+// stripCredentials removes the credential parameters from a URL
+func stripCredentials(u *url.URL) string {
+	query := u.Query()
+	for _, key := range credentialParams {
+		query.Del(key)
+	}
+	stripped := *u
+	stripped.RawQuery = query.Encode()
+	return stripped.String()
+}
+
+// Save queues a response received for rawURL to be archived. Archiving never
+// fails or holds up the fetch: when the queue is full the response is
+// dropped, and errors are logged.
+func (a *PayloadArchive) Save(provider, rawURL string, resp *ProviderResponse, attempt int, started time.Time) {
+	if a == nil {
+		return
+	}
+	select {
+	case a.queue <- archivedResponse{provider, rawURL, resp, attempt, started, time.Since(started)}:
+	default:
+		log.Printf("Payload archive queue is full, dropping %s response from %s", provider, redactURL(rawURL))
+	}
+}
+
+// StartPayloadArchive archives queued responses in the background,
+// PAYLOAD_ARCHIVE_WORKERS at a time
+func StartPayloadArchive(ctx context.Context) {
+	if payloadArchive == nil {
+		return
+	}
+	for i := 0; i < utils.GetEnvInt("PAYLOAD_ARCHIVE_WORKERS", 2); i++ {
+		go payloadArchive.run(ctx)
+	}
+}
+
+func (a *PayloadArchive) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case r := <-a.queue:
+			saveCtx, cancel := context.WithTimeout(ctx, a.timeout)
+			if _, err := a.save(saveCtx, r); err != nil {
+				log.Printf("Failed to archive %s response from %s: %v", r.provider, redactURL(r.rawURL), err)
+			}
+			cancel()
+		}
+	}
+}
+
+func (a *PayloadArchive) save(ctx context.Context, r archivedResponse) (*utils.RawPayload, error) {
+	resp := r.resp
+	u, err := url.Parse(r.rawURL)
+	if err != nil {
+		return nil, err
+	}
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write(resp.Body); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	payload := utils.RawPayload{
+		Provider:   r.provider,
+		Method:     http.MethodGet,
+		URL:        stripCredentials(u),
+		Endpoint:   u.Path,
+		StatusCode: resp.StatusCode,
+		Header:     archivedHeaders(resp.Header),
+		Attempt:    r.attempt,
+		FetchedAt:  r.started.UTC(),
+		DurationMS: r.duration.Milliseconds(),
+		Size:       int64(len(resp.Body)),
+		StoredSize: int64(compressed.Len()),
+		SHA256:     sha256Hex(resp.Body),
+		Store:      a.store.Name(),
+	}
+	key := fmt.Sprintf("%s/%s-%s.gz", r.provider, payload.FetchedAt.Format("2006/01/02/150405.000000"), payload.SHA256[:16])
+	if payload.Key, err = a.store.Put(ctx, key, compressed.Bytes()); err != nil {
+		return nil, fmt.Errorf("failed to store payload: %v", err)
+	}
+	if err := utils.DB.WithContext(ctx).Create(&payload).Error; err != nil {
+		// Nothing refers to the stored object without its row. The save may
+		// have failed for running out of time, so the delete gets its own.
+		deleteCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), a.timeout)
+		defer cancel()
+		if deleteErr := a.store.Delete(deleteCtx, payload.Key); deleteErr != nil {
+			return nil, fmt.Errorf("failed to save payload: %v (and to delete stored object %s: %v)", err, payload.Key, deleteErr)
+		}
+		return nil, fmt.Errorf("failed to save payload: %v", err)
+	}
+	return &payload, nil
+}
+
+// Load reads back the body of an archived payload, checking it against the
+// checksum taken when it was archived
+func (a *PayloadArchive) Load(ctx context.Context, payload *utils.RawPayload) ([]byte, error) {
+	if a == nil || a.store.Name() != payload.Store {
+		return nil, fmt.Errorf("payload %d is in the %s store, which is not configured", payload.ID, payload.Store)
+	}
+	compressed, err := a.store.Get(ctx, payload.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read payload %d: %v", payload.ID, err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress payload %d: %v", payload.ID, err)
+	}
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress payload %d: %v", payload.ID, err)
+	}
+	if sha256Hex(body) != payload.SHA256 {
+		return nil, fmt.Errorf("payload %d does not match its checksum", payload.ID)
+	}
+	return body, nil
+}
+
+// errNotReplayable marks payloads that hold no articles to reprocess
+var errNotReplayable = errors.New("not an article response")
+
+// replayableEndpoints are the provider endpoints whose responses hold
+// articles, with the kind of fetch they answer
+var replayableEndpoints = map[string]string{
+	"newsapi /v2/top-headlines":   "category",
+	"newsapi /v2/everything":      "topic",
+	"gnews /api/v4/top-headlines": "category",
+	"gnews /api/v4/search":        "topic",
+}
+
+// payloadAPIResponse rebuilds the fetch a payload answered from its request
+// URL and decodes its articles with the current mapping, as the fetch
+// functions do
+func payloadAPIResponse(payload *utils.RawPayload, body []byte) (*utils.APIResponse, error) {
+	kind, ok := replayableEndpoints[payload.Provider+" "+payload.Endpoint]
+	if !ok || payload.StatusCode != http.StatusOK {
+		return nil, errNotReplayable
+	}
+	u, err := url.Parse(payload.URL)
+	if err != nil {
+		return nil, err
+	}
+	query := u.Query()
+	apiResponse := &utils.APIResponse{
+		APISource: payload.Provider,
+		Type:      kind,
+		Country:   query.Get("country"),
+		Language:  query.Get("language"),
+	}
+	if kind == "category" {
+		apiResponse.Topic = query.Get("category")
+	} else {
+		// News API is searched for any word of a topic
+		apiResponse.Topic = strings.ReplaceAll(query.Get("q"), " OR ", " ")
+	}
+
+	switch payload.Provider {
+	case "newsapi":
+		var newsAPIResponse utils.NewsAPIResponse
+		if err := json.Unmarshal(body, &newsAPIResponse); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal JSON: %v", err)
+		}
+		apiResponse.Status = newsAPIResponse.Status
+		apiResponse.TotalResults = newsAPIResponse.TotalResults
+		apiResponse.Articles = newsAPIArticles(&newsAPIResponse)
+	case "gnews":
+		var gNewsResponse utils.GNewsResponse
+		if err := json.Unmarshal(body, &gNewsResponse); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal JSON: %v", err)
+		}
+		apiResponse.Language = query.Get("lang")
+		apiResponse.TotalArticles = gNewsResponse.TotalArticles
+		apiResponse.Articles = gNewsResponse.Articles
+	}
+	return apiResponse, nil
+}
+
+// ReprocessOptions selects the archived payloads to replay. From and To
+// bound when they were fetched.
+type ReprocessOptions struct {
+	Provider string
+	From     *time.Time
+	To       *time.Time
+	ID       uint
+	DryRun   bool
+}
+
+// ReprocessResult counts what a reprocessing run did, or in a dry run would
+// do. Skipped payloads hold no articles.
+type ReprocessResult struct {
+	Payloads int `json:"payloads"`
+	Skipped  int `json:"skipped"`
+	Failed   int `json:"failed"`
+	Articles int `json:"articles"`
+	Created  int `json:"created"`
+	Updated  int `json:"updated"`
+}
+
+// ReprocessPayloads replays archived provider responses, oldest first,
+// through the current mapping and the save path. Only the payload of an
+// article's last sighting updates it; older payloads, as a filtered run may
+// replay alone, only fill in what it lacks. Replayed articles are history:
+// they are not announced to webhooks, streams or saved searches. Each
+// payload is saved as a fetch of its own, seen when the payload was fetched;
+// replaying a payload again replaces the sightings of its earlier replay. A
+// payload that fails is logged and counted, and the run carries on.
+func ReprocessPayloads(ctx context.Context, opts ReprocessOptions) (*ReprocessResult, error) {
+	archive := payloadArchive
+	if archive == nil {
+		return nil, errors.New("The payload archive is not configured")
+	}
+	result := &ReprocessResult{}
+	var endpoints []string
+	for endpoint := range replayableEndpoints {
+		endpoints = append(endpoints, endpoint)
+	}
+
+	var lastID uint
+	for {
+		query := utils.DB.WithContext(ctx).
+			Where("id > ? AND status_code = ? AND provider || ' ' || endpoint IN ?", lastID, http.StatusOK, endpoints)
+		if opts.ID != 0 {
+			query = query.Where("id = ?", opts.ID)
+		}
+		if opts.Provider != "" {
+			query = query.Where("provider = ?", opts.Provider)
+		}
+		if opts.From != nil {
+			query = query.Where("fetched_at >= ?", *opts.From)
+		}
+		if opts.To != nil {
+			query = query.Where("fetched_at <= ?", *opts.To)
+		}
+		var payloads []utils.RawPayload
+		if err := query.Order("id").Limit(100).Find(&payloads).Error; err != nil {
+			return result, fmt.Errorf("Failed to query payloads: %v", err)
+		}
+		if len(payloads) == 0 {
+			return result, nil
+		}
+
+		for i := range payloads {
+			payload := &payloads[i]
+			lastID = payload.ID
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			result.Payloads++
+			if err := reprocessPayload(ctx, archive, payload, opts.DryRun, result); err != nil {
+				if errors.Is(err, errNotReplayable) {
+					result.Skipped++
+					continue
+				}
+				result.Failed++
+				log.Printf("Failed to reprocess payload %d: %v", payload.ID, err)
+			}
+		}
+	}
+}
+
+func reprocessPayload(ctx context.Context, archive *PayloadArchive, payload *utils.RawPayload, dryRun bool, result *ReprocessResult) error {
+	body, err := archive.Load(ctx, payload)
+	if err != nil {
+		return err
+	}
+	apiResponse, err := payloadAPIResponse(payload, body)
+	if err != nil {
+		return err
+	}
+	if len(apiResponse.Articles) == 0 {
+		return errNotReplayable
+	}
+
+	urls := make([]string, len(apiResponse.Articles))
+	for i, article := range apiResponse.Articles {
+		urls[i] = article.URL
+	}
+	var existing []string
+	if err := utils.DB.WithContext(ctx).Model(&utils.Article{}).Where("url IN ?", urls).Distinct().Pluck("url", &existing).Error; err != nil {
+		return fmt.Errorf("Error checking for existing articles: %v", err)
+	}
+	stored := make(map[string]bool, len(existing))
+	for _, u := range existing {
+		stored[u] = true
+	}
+	created, updated := 0, 0
+	for _, u := range urls {
+		if stored[u] {
+			updated++
+		} else {
+			created++
+		}
+		stored[u] = true
+	}
+	if dryRun {
+		result.Articles += len(urls)
+		result.Created += created
+		result.Updated += updated
+		return nil
+	}
+
+	var ingested []IngestedArticle
+	err = utils.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if ingested, err = saveReplayedResponse(tx, payload, apiResponse); err != nil {
+			return err
+		}
+		now := time.Now()
+		return tx.Model(payload).Updates(map[string]interface{}{"reprocessed_at": &now, "reprocess_response_id": apiResponse.ID}).Error
+	})
+	if err != nil {
+		return err
+	}
+	result.Articles += len(urls)
+	result.Created += created
+	result.Updated += updated
+	PublishIngestedArticles(apiResponse.APISource, ingested)
+	return nil
+}
+
+// saveReplayedResponse saves a replayed payload as a fetch seen when the
+// payload was fetched. A payload replayed before reuses the fetch of its
+// earlier replay, whose sightings are replaced.
+func saveReplayedResponse(tx *gorm.DB, payload *utils.RawPayload, apiResponse *utils.APIResponse) ([]IngestedArticle, error) {
+	if payload.ReprocessResponseID != 0 {
+		var previous utils.APIResponse
+		err := tx.First(&previous, payload.ReprocessResponseID).Error
+		if err == nil {
+			if err := tx.Where("api_response_id = ?", previous.ID).Delete(&utils.ArticleSighting{}).Error; err != nil {
+				return nil, fmt.Errorf("Failed to clear earlier sightings: %v", err)
+			}
+			apiResponse.Model = previous.Model
+			if err := tx.Omit("Articles").Save(apiResponse).Error; err != nil {
+				return nil, fmt.Errorf("Failed to save API response: %v", err)
+			}
+			return saveArticles(tx, apiResponse, SaveReplayed)
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("Failed to load earlier replay: %v", err)
+		}
+	}
+	apiResponse.CreatedAt = payload.FetchedAt
+	if err := tx.Omit("Articles").Create(apiResponse).Error; err != nil {
+		return nil, fmt.Errorf("Failed to save API response: %v", err)
+	}
+	return saveArticles(tx, apiResponse, SaveReplayed)
+}
+
+// ListPayloads lists archived upstream responses, newest first
+func ListPayloads(c *gin.Context) {
+	page, perPage := GetPaginationParams(c)
+	query := utils.DB.Model(&utils.RawPayload{})
+	if provider := c.Query("provider"); provider != "" {
+		query = query.Where("provider = ?", provider)
+	}
+	if endpoint := c.Query("endpoint"); endpoint != "" {
+		query = query.Where("endpoint = ?", endpoint)
+	}
+	if status := c.Query("status"); status != "" {
+		code, err := strconv.Atoi(status)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+			return
+		}
+		query = query.Where("status_code = ?", code)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var payloads []utils.RawPayload
+	if err := query.Order("id DESC").Offset((page - 1) * perPage).Limit(perPage).Find(&payloads).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"total":    total,
+		"page":     page,
+		"per_page": perPage,
+		"payloads": payloads,
+	})
+}
+
+// GetPayloadBody sends the body of an archived response as it was received
+func GetPayloadBody(c *gin.Context) {
+	id, ok := ParseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var payload utils.RawPayload
+	if err := utils.DB.First(&payload, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Payload not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	body, err := payloadArchive.Load(c.Request.Context(), &payload)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	contentType := "application/octet-stream"
+	if values := payload.Header["Content-Type"]; len(values) > 0 {
+		contentType = values[0]
+	}
+	c.Data(http.StatusOK, contentType, body)
+}
@@ -0,0 +1,249 @@
+package endpoints
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_news_api/utils"
+)
+
+// PayloadStore keeps archived payloads. Put stores data under key and
+// returns the key to read it back with, which a store may choose itself.
+// Delete removes what Put stored under the key it returned.
+type PayloadStore interface {
+	Name() string
+	Put(ctx context.Context, key string, data []byte) (string, error)
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// postgresPayloadStore keeps payloads as Postgres large objects; the key is
+// the object's oid
+type postgresPayloadStore struct{}
+
+func (postgresPayloadStore) Name() string {
+	return "postgres"
+}
+
+func (postgresPayloadStore) Put(ctx context.Context, key string, data []byte) (string, error) {
+	var oid uint32
+	if err := utils.DB.WithContext(ctx).Raw("SELECT lo_from_bytea(0, ?)", data).Row().Scan(&oid); err != nil {
+		return "", fmt.Errorf("failed to create large object: %v", err)
+	}
+	return strconv.FormatUint(uint64(oid), 10), nil
+}
+
+func (postgresPayloadStore) Get(ctx context.Context, key string) ([]byte, error) {
+	oid, err := strconv.ParseUint(key, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid large object oid %q", key)
+	}
+	var data []byte
+	if err := utils.DB.WithContext(ctx).Raw("SELECT lo_get(?::oid)", oid).Row().Scan(&data); err != nil {
+		return nil, fmt.Errorf("failed to read large object %d: %v", oid, err)
+	}
+	return data, nil
+}
+
+func (postgresPayloadStore) Delete(ctx context.Context, key string) error {
+	oid, err := strconv.ParseUint(key, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid large object oid %q", key)
+	}
+	if err := utils.DB.WithContext(ctx).Exec("SELECT lo_unlink(?::oid)", oid).Error; err != nil {
+		return fmt.Errorf("failed to delete large object %d: %v", oid, err)
+	}
+	return nil
+}
+
+// fsPayloadStore keeps payloads as files under a directory
+type fsPayloadStore struct {
+	dir string
+}
+
+func (s *fsPayloadStore) Name() string {
+	return "fs"
+}
+
+func (s *fsPayloadStore) Put(ctx context.Context, key string, data []byte) (string, error) {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	// Written aside and renamed so a crash never leaves half a payload
+	if err := os.WriteFile(path+".part", data, 0o644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(path+".part", path); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+func (s *fsPayloadStore) Get(ctx context.Context, key string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(s.dir, filepath.FromSlash(key)))
+}
+
+func (s *fsPayloadStore) Delete(ctx context.Context, key string) error {
+	return os.Remove(filepath.Join(s.dir, filepath.FromSlash(key)))
+}
+
+// s3PayloadStore keeps payloads in a bucket of an S3-compatible store such
+// as MinIO, addressed path-style and signed with Signature Version 4
+type s3PayloadStore struct {
+	endpoint  string
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+func (s *s3PayloadStore) Name() string {
+	return "s3"
+}
+
+// objectURL is the path-style URL of an object. Archive keys only use
+// characters that need no escaping.
+func (s *s3PayloadStore) objectURL(key string) string {
+	return s.endpoint + "/" + s.bucket + "/" + key
+}
+
+func (s *s3PayloadStore) do(ctx context.Context, method, key string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(key), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if method == http.MethodPut {
+		req.Header.Set("Content-Type", "application/gzip")
+	}
+	signV4(req, body, s.region, "s3", s.accessKey, s.secretKey, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("%s %s returned %d: %s", method, key, resp.StatusCode, truncate(strings.TrimSpace(string(data)), 200))
+	}
+	return data, nil
+}
+
+func (s *s3PayloadStore) Put(ctx context.Context, key string, data []byte) (string, error) {
+	if _, err := s.do(ctx, http.MethodPut, key, data); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+func (s *s3PayloadStore) Get(ctx context.Context, key string) ([]byte, error) {
+	return s.do(ctx, http.MethodGet, key, nil)
+}
+
+func (s *s3PayloadStore) Delete(ctx context.Context, key string) error {
+	_, err := s.do(ctx, http.MethodDelete, key, nil)
+	return err
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// signV4 signs a request with AWS Signature Version 4, covering the host
+// and every header already set on the request
+func signV4(req *http.Request, payload []byte, region, service, accessKey, secretKey string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	payloadHash := sha256Hex(payload)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, awsEscape(key)+"="+awsEscape(value))
+		}
+	}
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		strings.Join(pairs, "&"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+// awsEscape percent-encodes everything but the unreserved characters, as
+// Signature Version 4 requires
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
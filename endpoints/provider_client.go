@@ -0,0 +1,250 @@
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go_news_api/utils"
+)
+
+// maxResponseBytes caps how much of an upstream body we are willing to read
+const maxResponseBytes = 10 << 20
+
+// userAgent identifies us to providers and scraped sites
+const userAgent = "go_news_api/1.0 (+https://news.tadeasfort.cz)"
+
+// ProviderClient is the shared HTTP client used for news providers and scrapers.
+// It applies a per-provider timeout, propagates the caller's context, retries
+// network errors and 5xx responses with exponential backoff and jitter, and
+// honors Retry-After on 429 responses.
+type ProviderClient struct {
+	Name          string
+	MaxRetries    int
+	BaseBackoff   time.Duration
+	MaxBackoff    time.Duration
+	MaxRetryAfter time.Duration
+	// Archive, when set, keeps every response received
+	Archive    *PayloadArchive
+	httpClient *http.Client
+}
+
+// ProviderResponse is a fully read upstream response
+type ProviderResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+var (
+	newsAPIClient *ProviderClient
+	gNewsClient   *ProviderClient
+	scraperClient *ProviderClient
+	// payloadArchive keeps the responses of all three
+	payloadArchive *PayloadArchive
+)
+
+// InitProviders configures the provider clients from the environment. It must
+// be called after the .env file has been loaded.
+func InitProviders() {
+	newsAPIClient = NewProviderClient("newsapi", utils.GetEnvDuration("NEWS_API_TIMEOUT", 10*time.Second))
+	gNewsClient = NewProviderClient("gnews", utils.GetEnvDuration("GNEWS_TIMEOUT", 10*time.Second))
+	scraperClient = NewProviderClient("scraper", utils.GetEnvDuration("SCRAPER_TIMEOUT", 15*time.Second))
+	pageFetcher = NewPageFetcher()
+
+	var err error
+	if payloadArchive, err = NewPayloadArchive(); err != nil {
+		log.Printf("Warning: payload archive disabled: %v", err)
+	}
+	for _, client := range []*ProviderClient{newsAPIClient, gNewsClient, scraperClient} {
+		client.Archive = payloadArchive
+	}
+
+	registerProviders()
+}
+
+// NewProviderClient creates a client with the given request timeout and the
+// retry settings from the environment
+func NewProviderClient(name string, timeout time.Duration) *ProviderClient {
+	return &ProviderClient{
+		Name:          name,
+		MaxRetries:    utils.GetEnvInt("PROVIDER_MAX_RETRIES", 3),
+		BaseBackoff:   utils.GetEnvDuration("PROVIDER_BASE_BACKOFF", 500*time.Millisecond),
+		MaxBackoff:    utils.GetEnvDuration("PROVIDER_MAX_BACKOFF", 10*time.Second),
+		MaxRetryAfter: utils.GetEnvDuration("PROVIDER_MAX_RETRY_AFTER", 30*time.Second),
+		httpClient:    &http.Client{Timeout: timeout},
+	}
+}
+
+// Get performs a GET request, retrying transient failures. Once retries are
+// exhausted the last 429 or 5xx response is returned as-is so that callers can
+// map the provider's error payload; only transport failures return an error.
+func (pc *ProviderClient) Get(ctx context.Context, rawURL string) (*ProviderResponse, error) {
+	var lastResp *ProviderResponse
+	var lastErr error
+
+	for attempt := 0; attempt <= pc.MaxRetries; attempt++ {
+		started := time.Now()
+		resp, err := pc.do(ctx, rawURL)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, pc.transportError(rawURL, ctx.Err())
+			}
+			lastResp, lastErr = nil, pc.transportError(rawURL, err)
+			if attempt < pc.MaxRetries {
+				log.Printf("%s request to %s failed (attempt %d): %v", pc.Name, redactURL(rawURL), attempt+1, err)
+				if err := sleepContext(ctx, pc.backoff(attempt)); err != nil {
+					return nil, pc.transportError(rawURL, err)
+				}
+			}
+			continue
+		}
+
+		pc.Archive.Save(pc.Name, rawURL, resp, attempt+1, started)
+		lastResp, lastErr = resp, nil
+		var delay time.Duration
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests:
+			retryAfter := parseRetryAfter(resp.Header)
+			if retryAfter > pc.MaxRetryAfter {
+				return resp, nil
+			}
+			delay = max(retryAfter, pc.backoff(attempt))
+		case resp.StatusCode >= 500:
+			delay = pc.backoff(attempt)
+		default:
+			return resp, nil
+		}
+
+		if attempt < pc.MaxRetries {
+			log.Printf("%s request to %s returned %d (attempt %d), retrying in %s", pc.Name, redactURL(rawURL), resp.StatusCode, attempt+1, delay)
+			if err := sleepContext(ctx, delay); err != nil {
+				return nil, pc.transportError(rawURL, err)
+			}
+		}
+	}
+
+	return lastResp, lastErr
+}
+
+func (pc *ProviderClient) do(ctx context.Context, rawURL string) (*ProviderResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := pc.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %v", err)
+	}
+
+	return &ProviderResponse{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+	}, nil
+}
+
+// backoff returns the exponential delay for the given attempt with full jitter
+func (pc *ProviderClient) backoff(attempt int) time.Duration {
+	ceiling := pc.BaseBackoff << attempt
+	if ceiling <= 0 || ceiling > pc.MaxBackoff {
+		ceiling = pc.MaxBackoff
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+// transportError converts a network or context error into a ProviderError,
+// stripping the request URL so that API keys never end up in messages
+func (pc *ProviderClient) transportError(rawURL string, err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+
+	kind := ErrUpstreamUnavailable
+	var netErr interface{ Timeout() bool }
+	switch {
+	case errors.Is(err, context.Canceled):
+		// The caller went away; this says nothing about the provider
+		kind = context.Canceled
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		kind = ErrUpstreamTimeout
+	}
+
+	return &ProviderError{
+		Provider: pc.Name,
+		Message:  fmt.Sprintf("request to %s failed: %v", redactURL(rawURL), err),
+		Kind:     kind,
+	}
+}
+
+// parseRetryAfter understands both the delay-seconds and HTTP-date forms
+func parseRetryAfter(header http.Header) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if when, err := http.ParseTime(value); err == nil {
+		if d := time.Until(when); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// credentialParams are the query parameters providers take credentials in
+var credentialParams = []string{"apiKey", "apikey", "token"}
+
+// redactURL hides credentials passed as query parameters
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "<invalid url>"
+	}
+	query := u.Query()
+	for _, key := range credentialParams {
+		if query.Has(key) {
+			query.Set(key, "REDACTED")
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
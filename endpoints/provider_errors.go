@@ -0,0 +1,212 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Error kinds a ProviderError can wrap. Use errors.Is to test for them.
+var (
+	ErrRateLimited         = errors.New("provider rate limit exceeded")
+	ErrUnauthorized        = errors.New("provider rejected credentials")
+	ErrBadRequest          = errors.New("provider rejected request")
+	ErrUpstreamUnavailable = errors.New("provider unavailable")
+	ErrUpstreamTimeout     = errors.New("provider timed out")
+	ErrInvalidResponse     = errors.New("provider returned an invalid response")
+	ErrInvalidSource       = errors.New("invalid source")
+)
+
+// ProviderError describes a failed upstream call
+type ProviderError struct {
+	Provider   string
+	StatusCode int
+	Code       string
+	Message    string
+	RetryAfter time.Duration
+	Kind       error
+}
+
+func (e *ProviderError) Error() string {
+	msg := fmt.Sprintf("%s: %v", e.Provider, e.Kind)
+	if e.StatusCode != 0 && e.Code != "" {
+		msg += fmt.Sprintf(" (%d %s)", e.StatusCode, e.Code)
+	} else if e.StatusCode != 0 {
+		msg += fmt.Sprintf(" (%d)", e.StatusCode)
+	} else if e.Code != "" {
+		msg += fmt.Sprintf(" (%s)", e.Code)
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Kind
+}
+
+// kindForStatus maps an HTTP status code onto an error kind
+func kindForStatus(status int) error {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrUnauthorized
+	case status == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case status >= 500:
+		return ErrUpstreamUnavailable
+	case status >= 400:
+		return ErrBadRequest
+	}
+	return ErrInvalidResponse
+}
+
+// newsAPIErrorKinds maps NewsAPI error codes onto error kinds
+var newsAPIErrorKinds = map[string]error{
+	"apiKeyDisabled":     ErrUnauthorized,
+	"apiKeyInvalid":      ErrUnauthorized,
+	"apiKeyMissing":      ErrUnauthorized,
+	"apiKeyExhausted":    ErrRateLimited,
+	"rateLimited":        ErrRateLimited,
+	"parameterInvalid":   ErrBadRequest,
+	"parametersMissing":  ErrBadRequest,
+	"sourcesTooMany":     ErrBadRequest,
+	"sourceDoesNotExist": ErrBadRequest,
+	"unexpectedError":    ErrUpstreamUnavailable,
+}
+
+// checkNewsAPIResponse returns a ProviderError for non-2xx responses and for
+// bodies carrying NewsAPI's {"status":"error","code":...,"message":...} shape
+func checkNewsAPIResponse(resp *ProviderResponse) error {
+	var payload struct {
+		Status  string `json:"status"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	jsonErr := json.Unmarshal(resp.Body, &payload)
+
+	if resp.StatusCode < 300 && (jsonErr != nil || payload.Status != "error") {
+		if strings.Contains(resp.Header.Get("Content-Type"), "application/xml") {
+			return &ProviderError{
+				Provider:   "newsapi",
+				StatusCode: resp.StatusCode,
+				Message:    "received XML response: " + truncate(string(resp.Body), 200),
+				Kind:       ErrInvalidResponse,
+			}
+		}
+		return nil
+	}
+
+	kind := kindForStatus(resp.StatusCode)
+	if k, ok := newsAPIErrorKinds[payload.Code]; ok {
+		kind = k
+	}
+	message := payload.Message
+	if jsonErr != nil {
+		message = truncate(string(resp.Body), 200)
+	}
+
+	return &ProviderError{
+		Provider:   "newsapi",
+		StatusCode: resp.StatusCode,
+		Code:       payload.Code,
+		Message:    message,
+		RetryAfter: parseRetryAfter(resp.Header),
+		Kind:       kind,
+	}
+}
+
+// checkGNewsResponse returns a ProviderError for non-2xx responses, decoding
+// GNews' "errors" field, which is either a list of messages or a map of
+// parameter names to messages
+func checkGNewsResponse(resp *ProviderResponse) error {
+	if resp.StatusCode < 300 {
+		if strings.Contains(string(resp.Body), "<html") {
+			return &ProviderError{
+				Provider:   "gnews",
+				StatusCode: resp.StatusCode,
+				Message:    "received HTML response instead of JSON",
+				Kind:       ErrInvalidResponse,
+			}
+		}
+		return nil
+	}
+
+	var payload struct {
+		Errors json.RawMessage `json:"errors"`
+	}
+	var messages []string
+	if err := json.Unmarshal(resp.Body, &payload); err == nil && len(payload.Errors) > 0 {
+		var list []string
+		var fields map[string]string
+		if err := json.Unmarshal(payload.Errors, &list); err == nil {
+			messages = list
+		} else if err := json.Unmarshal(payload.Errors, &fields); err == nil {
+			for field, msg := range fields {
+				messages = append(messages, field+": "+msg)
+			}
+		}
+	}
+	if len(messages) == 0 {
+		messages = []string{truncate(string(resp.Body), 200)}
+	}
+
+	kind := kindForStatus(resp.StatusCode)
+	// GNews reports an exhausted daily quota as 403
+	if resp.StatusCode == http.StatusForbidden {
+		kind = ErrRateLimited
+	}
+
+	return &ProviderError{
+		Provider:   "gnews",
+		StatusCode: resp.StatusCode,
+		Message:    strings.Join(messages, "; "),
+		RetryAfter: parseRetryAfter(resp.Header),
+		Kind:       kind,
+	}
+}
+
+// ErrorStatus translates an error into the HTTP status we should answer with
+func ErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrBadRequest), errors.Is(err, ErrInvalidSource):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ErrDisallowedByRobots):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, ErrCircuitOpen), errors.Is(err, ErrNoProviderAvailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrUpstreamTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, ErrUnauthorized),
+		errors.Is(err, ErrUpstreamUnavailable),
+		errors.Is(err, ErrInvalidResponse):
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+// RespondError writes err as a JSON error with the matching HTTP status,
+// forwarding any Retry-After hint from the provider
+func RespondError(c *gin.Context, err error) {
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) && providerErr.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(providerErr.RetryAfter.Seconds()))))
+	}
+	c.JSON(ErrorStatus(err), gin.H{"error": err.Error()})
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
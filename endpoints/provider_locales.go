@@ -0,0 +1,65 @@
+package endpoints
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ProviderLocales lists the countries and languages a provider endpoint can
+// filter by. A nil list means the endpoint cannot filter by that at all, so
+// any value for it is rejected.
+type ProviderLocales struct {
+	Countries []string
+	Languages []string
+}
+
+// Validate checks a requested country and language, either of which may be
+// empty to use the provider's default
+func (l ProviderLocales) Validate(provider, country, language string) error {
+	if err := validateLocale(provider, "country", country, l.Countries); err != nil {
+		return err
+	}
+	return validateLocale(provider, "language", language, l.Languages)
+}
+
+func validateLocale(provider, kind, value string, supported []string) error {
+	if value == "" {
+		return nil
+	}
+	if supported == nil {
+		return fmt.Errorf("%w: %s cannot filter by %s", ErrBadRequest, provider, kind)
+	}
+	for _, s := range supported {
+		if s == value {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s does not support %s %q (supported: %s)", ErrBadRequest, provider, kind, value, strings.Join(supported, ", "))
+}
+
+// News API: top headlines filter by country only, /everything by language only
+var (
+	newsAPICountries = []string{
+		"ae", "ar", "at", "au", "be", "bg", "br", "ca", "ch", "cn", "co", "cu", "cz", "de", "eg", "fr", "gb", "gr",
+		"hk", "hu", "id", "ie", "il", "in", "it", "jp", "kr", "lt", "lv", "ma", "mx", "my", "ng", "nl", "no", "nz",
+		"ph", "pl", "pt", "ro", "rs", "ru", "sa", "se", "sg", "si", "sk", "th", "tr", "tw", "ua", "us", "ve", "za",
+	}
+	newsAPILanguages = []string{"ar", "de", "en", "es", "fr", "he", "it", "nl", "no", "pt", "ru", "sv", "ud", "zh"}
+
+	newsAPIHeadlineLocales = ProviderLocales{Countries: newsAPICountries}
+	newsAPISearchLocales   = ProviderLocales{Languages: newsAPILanguages}
+)
+
+// GNews filters both endpoints by country and language
+var (
+	gNewsCountries = []string{
+		"au", "br", "ca", "ch", "cn", "de", "eg", "es", "fr", "gb", "gr", "hk", "ie", "il", "in", "it", "jp", "nl",
+		"no", "pe", "ph", "pk", "pt", "ro", "ru", "se", "sg", "tw", "ua", "us",
+	}
+	gNewsLanguages = []string{
+		"ar", "de", "el", "en", "es", "fr", "he", "hi", "it", "ja", "ml", "mr", "nl", "no", "pt", "ro", "ru", "sv",
+		"ta", "te", "uk", "zh",
+	}
+
+	gNewsLocales = ProviderLocales{Countries: gNewsCountries, Languages: gNewsLanguages}
+)
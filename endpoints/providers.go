@@ -0,0 +1,218 @@
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go_news_api/utils"
+)
+
+// ErrNoProviderAvailable is returned by source=auto when every provider was
+// skipped or failed
+var ErrNoProviderAvailable = errors.New("no news provider available")
+
+// ErrCircuitOpen is returned when a provider's circuit breaker refuses a call
+var ErrCircuitOpen = errors.New("provider circuit open")
+
+// SourceAuto selects the first healthy provider in priority order
+const SourceAuto = "auto"
+
+// NewsProvider is a registered upstream news source
+type NewsProvider struct {
+	Name    string
+	Breaker *CircuitBreaker
+	Keys    *KeyRing
+
+	// HeadlineLocales and SearchLocales are the countries and languages
+	// TopHeadlines and TrendingTopicsNews accept
+	HeadlineLocales ProviderLocales
+	SearchLocales   ProviderLocales
+
+	TopHeadlines       func(ctx context.Context, country, category, language string) (*utils.APIResponse, error)
+	TrendingTopicsNews func(ctx context.Context, topics []utils.TrendingTopic, country, language string) (*utils.APIResponse, error)
+}
+
+// ProviderStatus is the health view of a provider
+type ProviderStatus struct {
+	Name           string         `json:"name"`
+	Priority       int            `json:"priority"`
+	Circuit        CircuitStatus  `json:"circuit"`
+	QuotaRemaining int            `json:"quota_remaining"`
+	Keys           []APIKeyStatus `json:"keys"`
+}
+
+var (
+	providers        = map[string]*NewsProvider{}
+	providerPriority []string
+
+	newsAPIKeys *KeyRing
+	gNewsKeys   *KeyRing
+)
+
+// registerProviders sets up the built-in providers and their priority order
+func registerProviders() {
+	newsAPIKeys = NewKeyRing("newsapi", "NEWS_API_KEYS", "NEWS_API_KEY", utils.GetEnvInt("NEWS_API_DAILY_LIMIT", 100))
+	gNewsKeys = NewKeyRing("gnews", "GNEWS_API_KEYS", "GNEWS_API_KEY", utils.GetEnvInt("GNEWS_DAILY_LIMIT", 100))
+
+	providers = map[string]*NewsProvider{}
+	RegisterProvider(&NewsProvider{
+		Name:               "newsapi",
+		Breaker:            NewCircuitBreaker(),
+		Keys:               newsAPIKeys,
+		HeadlineLocales:    newsAPIHeadlineLocales,
+		SearchLocales:      newsAPISearchLocales,
+		TopHeadlines:       GetNewsAPITopHeadlinesByCategory,
+		TrendingTopicsNews: GetNewsAPITrendingTopicsNews,
+	})
+	RegisterProvider(&NewsProvider{
+		Name:               "gnews",
+		Breaker:            NewCircuitBreaker(),
+		Keys:               gNewsKeys,
+		HeadlineLocales:    gNewsLocales,
+		SearchLocales:      gNewsLocales,
+		TopHeadlines:       GetGNewsTopHeadlines,
+		TrendingTopicsNews: GetGNewsTrendingTopicsNews,
+	})
+
+	providerPriority = nil
+	for _, name := range utils.GetEnvList("PROVIDER_PRIORITY", []string{"newsapi", "gnews"}) {
+		if _, ok := providers[name]; !ok {
+			log.Printf("Warning: unknown provider %q in PROVIDER_PRIORITY, ignoring", name)
+			continue
+		}
+		providerPriority = append(providerPriority, name)
+	}
+}
+
+// RegisterProvider adds a provider to the registry
+func RegisterProvider(p *NewsProvider) {
+	providers[p.Name] = p
+}
+
+// GetProvider looks up a registered provider by name
+func GetProvider(name string) (*NewsProvider, error) {
+	p, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidSource, name)
+	}
+	return p, nil
+}
+
+// ProviderStatuses reports breaker and quota state for every registered provider
+func ProviderStatuses() []ProviderStatus {
+	var statuses []ProviderStatus
+	seen := map[string]bool{}
+	add := func(p *NewsProvider, priority int) {
+		seen[p.Name] = true
+		statuses = append(statuses, ProviderStatus{
+			Name:           p.Name,
+			Priority:       priority,
+			Circuit:        p.Breaker.Status(),
+			QuotaRemaining: p.Keys.Remaining(),
+			Keys:           p.Keys.Statuses(),
+		})
+	}
+	for i, name := range providerPriority {
+		add(providers[name], i+1)
+	}
+	for _, p := range providers {
+		if !seen[p.Name] {
+			add(p, 0)
+		}
+	}
+	return statuses
+}
+
+// FetchTopHeadlines gets top headlines from the given provider, or from the
+// first available one when source is "auto". Empty country and language use
+// the provider's defaults.
+func FetchTopHeadlines(ctx context.Context, source, country, category, language string) (*utils.APIResponse, error) {
+	supports := func(p *NewsProvider) error {
+		return p.HeadlineLocales.Validate(p.Name, country, language)
+	}
+	apiResponse, err := withProvider(source, supports, func(p *NewsProvider) (*utils.APIResponse, error) {
+		return p.TopHeadlines(ctx, country, category, language)
+	})
+	if err != nil {
+		return nil, err
+	}
+	apiResponse.Type = "category"
+	apiResponse.Topic = category
+	return apiResponse, nil
+}
+
+// FetchTrendingTopicsNews gets news for the given topics from the given
+// provider, or from the first available one when source is "auto"
+func FetchTrendingTopicsNews(ctx context.Context, source string, topics []utils.TrendingTopic, country, language string) (*utils.APIResponse, error) {
+	supports := func(p *NewsProvider) error {
+		return p.SearchLocales.Validate(p.Name, country, language)
+	}
+	return withProvider(source, supports, func(p *NewsProvider) (*utils.APIResponse, error) {
+		return p.TrendingTopicsNews(ctx, topics, country, language)
+	})
+}
+
+// withProvider runs fn against a single named provider, or tries providers in
+// priority order when source is "auto", skipping open circuits, exhausted
+// quotas and providers that supports rejects. The returned response's
+// APISource names the provider that served it.
+func withProvider(source string, supports func(p *NewsProvider) error, fn func(p *NewsProvider) (*utils.APIResponse, error)) (*utils.APIResponse, error) {
+	if source != SourceAuto {
+		p, err := GetProvider(source)
+		if err != nil {
+			return nil, err
+		}
+		if err := supports(p); err != nil {
+			return nil, err
+		}
+		return p.call(fn)
+	}
+
+	var errs []error
+	supported := 0
+	for _, name := range providerPriority {
+		p := providers[name]
+		if err := supports(p); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		supported++
+		if p.Keys.Exhausted() {
+			errs = append(errs, p.Keys.exhaustedError())
+			continue
+		}
+		apiResponse, err := p.call(fn)
+		if err == nil {
+			return apiResponse, nil
+		}
+		log.Printf("Provider %s failed, trying next: %v", p.Name, err)
+		errs = append(errs, err)
+	}
+	if supported == 0 && len(errs) > 0 {
+		// No provider can serve this request at all, which is the caller's fault
+		return nil, fmt.Errorf("%w: %v", ErrBadRequest, errors.Join(errs...))
+	}
+	return nil, fmt.Errorf("%w: %v", ErrNoProviderAvailable, errors.Join(errs...))
+}
+
+// call runs fn through the provider's circuit breaker
+func (p *NewsProvider) call(fn func(p *NewsProvider) (*utils.APIResponse, error)) (*utils.APIResponse, error) {
+	if !p.Breaker.Allow() {
+		err := &ProviderError{Provider: p.Name, Kind: ErrCircuitOpen}
+		if retryAt := p.Breaker.Status().RetryAt; retryAt != nil {
+			err.RetryAfter = time.Until(*retryAt)
+		}
+		return nil, err
+	}
+
+	apiResponse, err := fn(p)
+	p.Breaker.Record(err)
+	if err != nil {
+		return nil, err
+	}
+	apiResponse.APISource = p.Name
+	return apiResponse, nil
+}
@@ -0,0 +1,101 @@
+package endpoints
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"go_news_api/utils"
+)
+
+// RequestQuota tracks an API key's daily request allowance. The count resets at
+// UTC midnight. A quota can also be blocked for a while when the provider
+// itself tells us we are rate limited.
+type RequestQuota struct {
+	Limit int
+
+	mu           sync.Mutex
+	day          string
+	used         int
+	blockedUntil time.Time
+}
+
+// NewRequestQuota creates a quota allowing limit requests per day
+func NewRequestQuota(limit int) *RequestQuota {
+	return &RequestQuota{Limit: limit}
+}
+
+// Take consumes one request, returning false when the quota is exhausted
+func (q *RequestQuota) Take() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.resetIfNewDay()
+	if q.exhausted() {
+		return false
+	}
+	q.used++
+	return true
+}
+
+// Exhausted reports whether no more requests may be made right now
+func (q *RequestQuota) Exhausted() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.resetIfNewDay()
+	return q.exhausted()
+}
+
+// Remaining returns how many requests are left today
+func (q *RequestQuota) Remaining() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.resetIfNewDay()
+	if time.Now().Before(q.blockedUntil) {
+		return 0
+	}
+	return max(q.Limit-q.used, 0)
+}
+
+// Block refuses requests until the given time
+func (q *RequestQuota) Block(until time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if until.After(q.blockedUntil) {
+		q.blockedUntil = until
+	}
+}
+
+// BlockedUntil returns the end of the current block, or the zero time
+func (q *RequestQuota) BlockedUntil() time.Time {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if time.Now().Before(q.blockedUntil) {
+		return q.blockedUntil
+	}
+	return time.Time{}
+}
+
+// ObserveError blocks the quota when err says the provider rate limited us
+func (q *RequestQuota) ObserveError(err error) {
+	var providerErr *ProviderError
+	if !errors.As(err, &providerErr) || !errors.Is(err, ErrRateLimited) || providerErr.StatusCode == 0 {
+		return
+	}
+	wait := providerErr.RetryAfter
+	if wait <= 0 {
+		wait = utils.GetEnvDuration("QUOTA_BLOCK_DURATION", time.Hour)
+	}
+	q.Block(time.Now().Add(wait))
+}
+
+func (q *RequestQuota) exhausted() bool {
+	return q.used >= q.Limit || time.Now().Before(q.blockedUntil)
+}
+
+func (q *RequestQuota) resetIfNewDay() {
+	today := time.Now().UTC().Format("2006-01-02")
+	if q.day != today {
+		q.day = today
+		q.used = 0
+	}
+}
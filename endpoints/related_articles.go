@@ -0,0 +1,284 @@
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"go_news_api/nlp"
+	"go_news_api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// relatedIndexBatchSize is how many vectors are loaded at a time when
+// building the related articles index
+const relatedIndexBatchSize = 5000
+
+// relatedCandidates is how many candidates per requested result are taken
+// from the index and scored again with their full vectors
+const relatedCandidates = 3
+
+var (
+	relatedIndex      = nlp.NewVectorIndex()
+	relatedIndexReady atomic.Bool
+)
+
+// vectorText is the text an article's vector is made from. The text given
+// already starts with the title, so adding it again makes its terms count
+// twice, since it says the most about the story.
+func vectorText(article *utils.Article, text string) string {
+	return article.Title + "\n" + text
+}
+
+// SaveArticleVector stores the TF-IDF vector of text as an article's vector.
+// Term weights use the document frequencies of the index at the time.
+func SaveArticleVector(tx *gorm.DB, article *utils.Article, text string) error {
+	vector := nlp.WeightVector(nlp.TermCounts(vectorText(article, text)), relatedIndex.IDF)
+	data, err := vector.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("Failed to encode vector of article %d: %v", article.ID, err)
+	}
+	err = tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "article_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"data", "updated_at"}),
+	}).Create(&utils.ArticleVector{ArticleID: article.ID, Data: data}).Error
+	if err != nil {
+		return fmt.Errorf("Failed to save vector of article %d: %v", article.ID, err)
+	}
+	return nil
+}
+
+// indexedDocument describes an article to the index: its time is when it
+// was published, or first seen when the provider gave no date
+func indexedDocument(id uint, url string, publishedAt, firstSeenAt *time.Time) nlp.IndexedDocument {
+	doc := nlp.IndexedDocument{ID: id}
+	if publishedAt != nil {
+		doc.Time = publishedAt.Unix()
+	} else if firstSeenAt != nil {
+		doc.Time = firstSeenAt.Unix()
+	}
+	if canonical := utils.CanonicalURL(url); canonical != "" {
+		h := fnv.New64a()
+		h.Write([]byte(canonical))
+		doc.URLKey = h.Sum64()
+	}
+	return doc
+}
+
+type vectorRow struct {
+	ArticleID   uint
+	Data        []byte
+	URL         string
+	PublishedAt *time.Time
+	FirstSeenAt *time.Time
+}
+
+// vectorRows selects stored vectors with what the index needs of their
+// articles
+func vectorRows() *gorm.DB {
+	return utils.DB.Table("article_vectors").
+		Select("article_vectors.article_id, article_vectors.data, articles.url, articles.published_at, articles.first_seen_at").
+		Joins("JOIN articles ON articles.id = article_vectors.article_id AND articles.deleted_at IS NULL")
+}
+
+func addVectorRows(rows []vectorRow) {
+	for _, row := range rows {
+		var vector nlp.Vector
+		if err := vector.UnmarshalBinary(row.Data); err != nil {
+			log.Printf("Skipping vector of article %d: %v", row.ArticleID, err)
+			continue
+		}
+		relatedIndex.Add(indexedDocument(row.ArticleID, row.URL, row.PublishedAt, row.FirstSeenAt), vector)
+	}
+}
+
+// LoadRelatedIndex builds the related articles index from the stored vectors
+func LoadRelatedIndex(ctx context.Context) error {
+	var lastID uint
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var rows []vectorRow
+		if err := vectorRows().Where("article_vectors.article_id > ?", lastID).
+			Order("article_vectors.article_id").Limit(relatedIndexBatchSize).
+			Scan(&rows).Error; err != nil {
+			return fmt.Errorf("Failed to load article vectors: %v", err)
+		}
+		if len(rows) == 0 {
+			break
+		}
+		addVectorRows(rows)
+		lastID = rows[len(rows)-1].ArticleID
+	}
+	relatedIndexReady.Store(true)
+	return nil
+}
+
+// IndexArticleVectors adds the stored vectors of the given articles to the
+// related articles index, replacing their earlier ones
+func IndexArticleVectors(ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	var rows []vectorRow
+	if err := vectorRows().Where("article_vectors.article_id IN ?", ids).Scan(&rows).Error; err != nil {
+		return fmt.Errorf("Failed to load article vectors: %v", err)
+	}
+	addVectorRows(rows)
+	return nil
+}
+
+// StartRelatedIndex loads the related articles index in the background and
+// keeps it up to date as articles are ingested
+func StartRelatedIndex(ctx context.Context) {
+	OnArticlesIngested(func(provider string, articles []IngestedArticle) {
+		ids := make([]uint, len(articles))
+		for i, article := range articles {
+			ids[i] = article.ID
+		}
+		go func() {
+			if err := IndexArticleVectors(ids); err != nil {
+				log.Printf("Failed to index ingested articles: %v", err)
+			}
+		}()
+	})
+	go func() {
+		start := time.Now()
+		if err := LoadRelatedIndex(ctx); err != nil {
+			log.Printf("Failed to load related articles index: %v", err)
+			return
+		}
+		log.Printf("Loaded related articles index with %d articles in %s", relatedIndex.Len(), time.Since(start).Round(time.Millisecond))
+	}()
+}
+
+// IndexMissingArticles computes vectors for every article without one, such
+// as those stored before related articles existed, and indexes them
+func IndexMissingArticles(ctx context.Context) (int, error) {
+	count := 0
+	query := utils.DB.Where("NOT EXISTS (SELECT 1 FROM article_vectors WHERE article_vectors.article_id = articles.id)")
+	err := eachArticleBatch(ctx, query, func(articles []utils.Article) error {
+		ids := make([]uint, len(articles))
+		for i := range articles {
+			article := &articles[i]
+			if err := SaveArticleVector(utils.DB, article, entityText(utils.DB, article)); err != nil {
+				return err
+			}
+			ids[i] = article.ID
+			count++
+		}
+		// Index each batch so later ones are weighted with its frequencies
+		return IndexArticleVectors(ids)
+	})
+	return count, err
+}
+
+// loadVectors returns the stored vectors of the given articles
+func loadVectors(ids []uint) (map[uint]nlp.Vector, error) {
+	var stored []utils.ArticleVector
+	if err := utils.DB.Where("article_id IN ?", ids).Find(&stored).Error; err != nil {
+		return nil, fmt.Errorf("Failed to load article vectors: %v", err)
+	}
+	vectors := make(map[uint]nlp.Vector, len(stored))
+	for _, row := range stored {
+		var vector nlp.Vector
+		if err := vector.UnmarshalBinary(row.Data); err == nil {
+			vectors[row.ArticleID] = vector
+		}
+	}
+	return vectors, nil
+}
+
+// GetRelatedArticles returns the articles most similar to an article by the
+// cosine similarity of their TF-IDF vectors (limit, default 10). window, such
+// as 7d, keeps to articles published that close to it. Copies of the article
+// under the same URL are left out.
+func GetRelatedArticles(c *gin.Context) {
+	article, ok := findArticle(c)
+	if !ok {
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 || limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+		return
+	}
+	var window time.Duration
+	if c.Query("window") != "" {
+		if window, err = parseWindow(c.Query("window")); err != nil {
+			RespondError(c, err)
+			return
+		}
+	}
+	if !relatedIndexReady.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "The related articles index is still loading"})
+		return
+	}
+
+	vectors, err := loadVectors([]uint{article.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	vector, ok := vectors[article.ID]
+	if !ok {
+		// Not vectorized yet, so weigh its text now
+		vector = nlp.WeightVector(nlp.TermCounts(vectorText(article, entityText(utils.DB, article))), relatedIndex.IDF)
+	}
+
+	doc := indexedDocument(article.ID, article.URL, article.PublishedAt, article.FirstSeenAt)
+	neighbors := relatedIndex.Nearest(doc, vector, limit*relatedCandidates, int64(window.Seconds()))
+	ids := make([]uint, len(neighbors))
+	for i, neighbor := range neighbors {
+		ids[i] = neighbor.ID
+	}
+
+	// The index skips common terms; score the candidates on their full vectors
+	candidates, err := loadVectors(ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range neighbors {
+		if candidate, ok := candidates[neighbors[i].ID]; ok {
+			neighbors[i].Score = vector.Cosine(candidate)
+		}
+	}
+	sort.SliceStable(neighbors, func(i, j int) bool {
+		return neighbors[i].Score > neighbors[j].Score
+	})
+	if len(neighbors) > limit {
+		neighbors = neighbors[:limit]
+	}
+
+	var articles []utils.Article
+	if err := utils.DB.Preload("Source").Where("id IN ?", ids).Find(&articles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	byID := make(map[uint]utils.Article, len(articles))
+	for _, a := range articles {
+		byID[a.ID] = a
+	}
+	related := []utils.RelatedArticle{}
+	for _, neighbor := range neighbors {
+		if a, ok := byID[neighbor.ID]; ok {
+			related = append(related, utils.RelatedArticle{Score: neighbor.Score, Article: a})
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"article_id": article.ID,
+		"related":    related,
+	})
+}
@@ -0,0 +1,132 @@
+package endpoints
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// robotsAgent is the product token we look for in robots.txt user-agent
+// lines. It is lowercase, as parseRobots makes the lines, so that it matches
+// case-insensitively.
+const robotsAgent = "go_news_api"
+
+// robotsRules is the subset of a robots.txt file that applies to us
+type robotsRules struct {
+	allow      []string
+	disallow   []string
+	crawlDelay time.Duration
+}
+
+type robotsGroup struct {
+	agents []string
+	robotsRules
+}
+
+// parseRobots parses a robots.txt body and returns the rules for our agent,
+// falling back to the "*" group
+func parseRobots(body []byte) *robotsRules {
+	var groups []*robotsGroup
+	var current *robotsGroup
+	lastWasAgent := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		field, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		field = strings.ToLower(strings.TrimSpace(field))
+		value = strings.TrimSpace(value)
+
+		switch field {
+		case "user-agent":
+			// Consecutive user-agent lines share one group
+			if current == nil || !lastWasAgent {
+				current = &robotsGroup{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			lastWasAgent = true
+			continue
+		case "allow":
+			if current != nil && value != "" {
+				current.allow = append(current.allow, value)
+			}
+		case "disallow":
+			if current != nil && value != "" {
+				current.disallow = append(current.disallow, value)
+			}
+		case "crawl-delay":
+			if current != nil {
+				if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+					current.crawlDelay = time.Duration(seconds * float64(time.Second))
+				}
+			}
+		}
+		lastWasAgent = false
+	}
+
+	var wildcard *robotsGroup
+	for _, group := range groups {
+		for _, agent := range group.agents {
+			if agent == robotsAgent {
+				return &group.robotsRules
+			}
+			if agent == "*" && wildcard == nil {
+				wildcard = group
+			}
+		}
+	}
+	if wildcard != nil {
+		return &wildcard.robotsRules
+	}
+	return &robotsRules{}
+}
+
+// Allowed applies the longest matching rule to path; on a tie Allow wins
+func (r *robotsRules) Allowed(path string) bool {
+	best := -1
+	allowed := true
+	for _, pattern := range r.disallow {
+		if robotsMatch(pattern, path) && len(pattern) > best {
+			best, allowed = len(pattern), false
+		}
+	}
+	for _, pattern := range r.allow {
+		if robotsMatch(pattern, path) && len(pattern) >= best {
+			best, allowed = len(pattern), true
+		}
+	}
+	return allowed
+}
+
+// robotsMatch matches a robots.txt path pattern supporting "*" and a trailing "$"
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for _, part := range parts[1:] {
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(part):]
+	}
+	if anchored && rest != "" {
+		// With a wildcard before "$" the last part only needs to end the path
+		return len(parts) > 1 && strings.HasSuffix(path, parts[len(parts)-1])
+	}
+	return true
+}
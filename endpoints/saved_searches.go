@@ -0,0 +1,297 @@
+package endpoints
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go_news_api/nlp"
+	"go_news_api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// unreadMatchesSQL counts the matches of a saved search since it was last read
+const unreadMatchesSQL = `(SELECT COUNT(*) FROM saved_search_matches
+    WHERE saved_search_matches.saved_search_id = saved_searches.id
+    AND (saved_searches.last_read_at IS NULL OR saved_search_matches.matched_at > saved_searches.last_read_at)) AS unread`
+
+// matchSavedSearchesSQL records the matches of the given articles with every
+// saved search. Only articles first seen after a search was saved match it.
+const matchSavedSearchesSQL = `
+    INSERT INTO saved_search_matches (saved_search_id, article_id, matched_at)
+    SELECT saved_searches.id, articles.id, NOW()
+    FROM saved_searches
+    JOIN articles ON articles.id IN @ids AND articles.deleted_at IS NULL
+    LEFT JOIN sources ON sources.id = articles.source_id
+    WHERE saved_searches.deleted_at IS NULL
+    AND articles.first_seen_at >= saved_searches.created_at
+    AND (saved_searches.language = '' OR articles.language = saved_searches.language)
+    AND (saved_searches.category = '' OR articles.category = saved_searches.category)
+    AND (saved_searches.source_domain = '' OR sources.domain = saved_searches.source_domain)
+    AND to_tsvector(COALESCE(NULLIF(saved_searches.search_config, ''), NULLIF(articles.search_config, ''), 'english')::regconfig, ` + articleDocument + `)
+        @@ to_tsquery(COALESCE(NULLIF(saved_searches.search_config, ''), NULLIF(articles.search_config, ''), 'english')::regconfig, saved_searches.ts_query)
+    ON CONFLICT DO NOTHING`
+
+// MatchSavedSearches matches articles against every saved search and returns
+// the number of new matches
+func MatchSavedSearches(ids []uint) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result := utils.DB.Exec(matchSavedSearchesSQL, map[string]interface{}{"ids": ids})
+	if result.Error != nil {
+		return 0, fmt.Errorf("Failed to match saved searches: %v", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// StartSavedSearchMatcher matches newly ingested articles against the saved
+// searches in the background
+func StartSavedSearchMatcher() {
+	OnArticlesIngested(func(provider string, articles []IngestedArticle) {
+		ids := make([]uint, 0, len(articles))
+		for _, article := range articles {
+			if !article.Imported {
+				ids = append(ids, article.ID)
+			}
+		}
+		if len(ids) == 0 {
+			return
+		}
+		go func() {
+			if _, err := MatchSavedSearches(ids); err != nil {
+				log.Print(err)
+			}
+		}()
+	})
+}
+
+// SavedSearchRequest is the body of a saved search create or update. lang,
+// category and source (a domain such as bbc.co.uk) narrow the matches.
+type SavedSearchRequest struct {
+	Owner    string `json:"owner" binding:"required"`
+	Name     string `json:"name"`
+	Query    string `json:"query" binding:"required"`
+	Language string `json:"lang"`
+	Category string `json:"category"`
+	Source   string `json:"source"`
+}
+
+// apply validates the request and copies it into search
+func (r SavedSearchRequest) apply(search *utils.SavedSearch) error {
+	search.Owner = strings.TrimSpace(r.Owner)
+	search.Query = strings.TrimSpace(r.Query)
+	search.TSQuery = PrepareSearchQuery(search.Query)
+	if search.Owner == "" || search.TSQuery == "" {
+		return fmt.Errorf("%w: owner and query must not be blank", ErrBadRequest)
+	}
+	if search.Name = strings.TrimSpace(r.Name); search.Name == "" {
+		search.Name = search.Query
+	}
+
+	search.Language = strings.ToLower(r.Language)
+	search.SearchConfig = ""
+	if search.Language != "" {
+		if len(search.Language) != 2 {
+			return fmt.Errorf("%w: lang must be an ISO 639-1 code", ErrBadRequest)
+		}
+		search.SearchConfig = utils.SearchConfigFor(search.Language)
+	}
+	if search.Category = strings.ToLower(r.Category); search.Category != "" && !nlp.IsCategory(search.Category) {
+		return fmt.Errorf("%w: category must be one of %s", ErrBadRequest, strings.Join(nlp.Categories, ", "))
+	}
+	search.SourceDomain = ""
+	if r.Source != "" {
+		if search.SourceDomain = utils.RegistrableDomain(r.Source); search.SourceDomain == "" {
+			return fmt.Errorf("%w: invalid source domain %q", ErrBadRequest, r.Source)
+		}
+	}
+
+	// A query Postgres cannot parse would fail matching for every search
+	config := search.SearchConfig
+	if config == "" {
+		config = "english"
+	}
+	if err := utils.DB.Exec("SELECT to_tsquery(?::regconfig, ?)", config, search.TSQuery).Error; err != nil {
+		return fmt.Errorf("%w: invalid query: %v", ErrBadRequest, err)
+	}
+	return nil
+}
+
+// savedSearches selects saved searches with their unread match counts
+func savedSearches() *gorm.DB {
+	return utils.DB.Model(&utils.SavedSearch{}).Select("saved_searches.*, " + unreadMatchesSQL)
+}
+
+// findSavedSearch loads a saved search by its id path parameter
+func findSavedSearch(c *gin.Context) (*utils.SavedSearch, bool) {
+	id, ok := ParseIDParam(c, "id")
+	if !ok {
+		return nil, false
+	}
+	var search utils.SavedSearch
+	if err := savedSearches().First(&search, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Saved search not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return &search, true
+}
+
+// CreateSavedSearch saves a search for its owner
+func CreateSavedSearch(c *gin.Context) {
+	var request SavedSearchRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var search utils.SavedSearch
+	if err := request.apply(&search); err != nil {
+		RespondError(c, err)
+		return
+	}
+	if err := utils.DB.Create(&search).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, search)
+}
+
+// ListSavedSearches lists saved searches with their unread match counts,
+// optionally only those of one owner
+func ListSavedSearches(c *gin.Context) {
+	query := savedSearches()
+	if owner := c.Query("owner"); owner != "" {
+		query = query.Where("saved_searches.owner = ?", owner)
+	}
+	var searches []utils.SavedSearch
+	if err := query.Order("saved_searches.id").Find(&searches).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var unread int64
+	for _, search := range searches {
+		unread += search.Unread
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"saved_searches": searches,
+		"unread":         unread,
+	})
+}
+
+// GetSavedSearch returns a saved search with its unread match count
+func GetSavedSearch(c *gin.Context) {
+	search, ok := findSavedSearch(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, search)
+}
+
+// UpdateSavedSearch replaces a saved search's query, name and filters
+func UpdateSavedSearch(c *gin.Context) {
+	search, ok := findSavedSearch(c)
+	if !ok {
+		return
+	}
+	var request SavedSearchRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := request.apply(search); err != nil {
+		RespondError(c, err)
+		return
+	}
+	if err := utils.DB.Save(search).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, search)
+}
+
+// DeleteSavedSearch deletes a saved search and its matches
+func DeleteSavedSearch(c *gin.Context) {
+	search, ok := findSavedSearch(c)
+	if !ok {
+		return
+	}
+	err := utils.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("saved_search_id = ?", search.ID).Delete(&utils.SavedSearchMatch{}).Error; err != nil {
+			return fmt.Errorf("Failed to delete matches: %v", err)
+		}
+		return tx.Delete(search).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetSavedSearchMatches lists the articles matching a saved search, newest
+// match first. since keeps to matches recorded after that time.
+func GetSavedSearchMatches(c *gin.Context) {
+	search, ok := findSavedSearch(c)
+	if !ok {
+		return
+	}
+	page, perPage := GetPaginationParams(c)
+
+	query := utils.DB.Model(&utils.SavedSearchMatch{}).Where("saved_search_id = ?", search.ID)
+	if since := c.Query("since"); since != "" {
+		parsed := utils.ParsePublishedAt(since)
+		if parsed == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid since date %q", since)})
+			return
+		}
+		query = query.Where("matched_at > ?", *parsed)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var matches []utils.SavedSearchMatch
+	if err := query.Preload("Article.Source").
+		Order("matched_at DESC, id DESC").
+		Offset((page - 1) * perPage).Limit(perPage).
+		Find(&matches).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"saved_search_id": search.ID,
+		"unread":          search.Unread,
+		"total":           total,
+		"page":            page,
+		"per_page":        perPage,
+		"matches":         matches,
+	})
+}
+
+// MarkSavedSearchRead marks every match of a saved search so far as read
+func MarkSavedSearchRead(c *gin.Context) {
+	search, ok := findSavedSearch(c)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	if err := utils.DB.Model(search).Update("last_read_at", now).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	search.LastReadAt = &now
+	search.Unread = 0
+	c.JSON(http.StatusOK, search)
+}
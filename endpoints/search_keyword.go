@@ -3,12 +3,15 @@ package endpoints
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 
 	"go_news_api/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // GetNewsByKeyword handles the request for news articles by keyword
@@ -21,8 +24,20 @@ func GetNewsByKeyword(c *gin.Context) {
 		return
 	}
 
+	filter, err := ParseArticleFilter(c.Request.URL.Query())
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	lang, err := parseLangParam(c.Request.URL.Query())
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
 	searchQuery := PrepareSearchQuery(keyword)
-	articles, total, err := SearchArticles(searchQuery, page, perPage)
+	articles, total, err := SearchArticles(searchQuery, lang, filter, page, perPage)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -33,6 +48,15 @@ func GetNewsByKeyword(c *gin.Context) {
 	c.JSON(http.StatusOK, apiResponse)
 }
 
+// parseLangParam reads the lang query parameter, an ISO 639-1 code
+func parseLangParam(values url.Values) (string, error) {
+	lang := strings.ToLower(values.Get("lang"))
+	if lang != "" && len(lang) != 2 {
+		return "", fmt.Errorf("%w: lang must be an ISO 639-1 code", ErrBadRequest)
+	}
+	return lang, nil
+}
+
 func GetPaginationParams(c *gin.Context) (int, int) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "20"))
@@ -51,20 +75,61 @@ func PrepareSearchQuery(keyword string) string {
 	return strings.Join(strings.Fields(keyword), " & ")
 }
 
-func SearchArticles(searchQuery string, page, perPage int) ([]utils.Article, int64, error) {
+// articleDocument is the text an article is searched by
+const articleDocument = "articles.author || ' ' || articles.title || ' ' || articles.description || ' ' || articles.content"
+
+// articleSearchConfig is the text search configuration stored per article
+const articleSearchConfig = "COALESCE(NULLIF(articles.search_config, ''), 'english')::regconfig"
+
+// searchExprs builds the document and query expressions of a full-text
+// search; see SearchArticles for how lang is used
+func searchExprs(searchQuery, lang string) (clause.Expr, clause.Expr) {
+	config := clause.Expr{SQL: articleSearchConfig}
+	if lang != "" {
+		config = clause.Expr{SQL: "?::regconfig", Vars: []interface{}{utils.SearchConfigFor(lang)}}
+	}
+	tsQuery := clause.Expr{SQL: "to_tsquery(?, ?)", Vars: []interface{}{config, searchQuery}}
+	document := clause.Expr{SQL: "to_tsvector(?, " + articleDocument + ")", Vars: []interface{}{config}}
+	return document, tsQuery
+}
+
+// whereSearch restricts an articles query to the matches of a full-text
+// search
+func whereSearch(query *gorm.DB, searchQuery, lang string) *gorm.DB {
+	document, tsQuery := searchExprs(searchQuery, lang)
+	query = query.Where("? @@ ?", document, tsQuery)
+	if lang != "" {
+		query = query.Where("articles.language = ?", lang)
+	}
+	return query
+}
+
+// SearchArticles runs a full-text search. Without lang every article is
+// matched using its own language's configuration; with lang only articles in
+// that language are searched, using its configuration.
+func SearchArticles(searchQuery, lang string, filter ArticleFilter, page, perPage int) ([]utils.Article, int64, error) {
 	offset := (page - 1) * perPage
 	var articles []utils.Article
 	var total int64
 
+	document, tsQuery := searchExprs(searchQuery, lang)
 	query := utils.DB.Model(&utils.Article{}).
-		Joins("LEFT JOIN sources ON articles.source_id = sources.id").
-		Where("to_tsvector('english', articles.author || ' ' || articles.title || ' ' || articles.description || ' ' || articles.content) @@ to_tsquery('english', ?)", searchQuery).
-		Order(fmt.Sprintf("ts_rank(to_tsvector('english', articles.author || ' ' || articles.title || ' ' || articles.description || ' ' || articles.content), to_tsquery('english', '%s')) DESC", searchQuery))
+		Joins("LEFT JOIN sources ON articles.source_id = sources.id")
+	query = whereSearch(query, searchQuery, lang)
+	query = filter.Apply(query)
 
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
+	if filter.Sort != SortPublishedAt {
+		query = query.Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:                "ts_rank(?, ?) DESC",
+			Vars:               []interface{}{document, tsQuery},
+			WithoutParentheses: true,
+		}})
+	}
+
 	result := query.Preload("Source").
 		Offset(offset).
 		Limit(perPage).
@@ -0,0 +1,346 @@
+package endpoints
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go_news_api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// normalizeSourceName folds a provider's source name for alias lookups
+func normalizeSourceName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// externalSourceID renders a provider's source id, which may be a string,
+// a number or null
+func externalSourceID(id interface{}) string {
+	if id == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprint(id))
+}
+
+// findAlias returns the source a provider alias points at, if any. Aliases
+// without a provider (left behind by merges) match any provider.
+func findAlias(tx *gorm.DB, provider, kind, value string) (*utils.Source, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var alias utils.SourceAlias
+	err := tx.Where("provider IN ? AND kind = ? AND value = ?", []string{provider, ""}, kind, value).
+		Order("provider DESC").
+		First(&alias).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return findSourceBy(tx, "id = ?", alias.SourceID)
+}
+
+func findSourceBy(tx *gorm.DB, query string, args ...interface{}) (*utils.Source, error) {
+	var source utils.Source
+	err := tx.Where(query, args...).Order("id").First(&source).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &source, nil
+}
+
+// resolveSource finds the catalogue entry for a source as reported by a
+// provider: by the provider's id, then by registrable domain, then by name
+func resolveSource(tx *gorm.DB, provider, externalID, name, domain string) (*utils.Source, error) {
+	if source, err := findAlias(tx, provider, utils.AliasID, externalID); source != nil || err != nil {
+		return source, err
+	}
+	if domain != "" {
+		if source, err := findSourceBy(tx, "domain = ?", domain); source != nil || err != nil {
+			return source, err
+		}
+	}
+	if source, err := findAlias(tx, provider, utils.AliasName, normalizeSourceName(name)); source != nil || err != nil {
+		return source, err
+	}
+	// Sources stored before the catalogue existed only have a name
+	if name != "" {
+		return findSourceBy(tx, "name = ? AND (domain IS NULL OR domain = '')", name)
+	}
+	return nil, nil
+}
+
+// saveAliases records the provider's id and name for a source
+func saveAliases(tx *gorm.DB, provider string, source *utils.Source, externalID, name string) error {
+	aliases := []utils.SourceAlias{
+		{Provider: provider, Kind: utils.AliasID, Value: externalID},
+		{Provider: provider, Kind: utils.AliasName, Value: normalizeSourceName(name)},
+	}
+	for _, alias := range aliases {
+		if alias.Value == "" {
+			continue
+		}
+		alias.SourceID = source.ID
+		if err := tx.Where(utils.SourceAlias{Provider: alias.Provider, Kind: alias.Kind, Value: alias.Value}).
+			FirstOrCreate(&alias).Error; err != nil {
+			return fmt.Errorf("Failed to save source alias: %v", err)
+		}
+	}
+	return nil
+}
+
+// SaveSource resolves an article's source against the catalogue, creating
+// the entry when it is new, and records the provider's name and id for it
+func SaveSource(tx *gorm.DB, provider string, article *utils.Article) error {
+	reported := article.Source
+	externalID := externalSourceID(reported.ExternalID)
+	// GNews reports the publisher's homepage; News API only the article URL
+	domain := utils.RegistrableDomain(reported.URL)
+	if domain == "" {
+		domain = utils.RegistrableDomain(article.URL)
+	}
+
+	source, err := resolveSource(tx, provider, externalID, reported.Name, domain)
+	if err != nil {
+		return fmt.Errorf("Failed to look up source: %v", err)
+	}
+	if source == nil {
+		source = &utils.Source{Name: reported.Name, URL: reported.URL}
+	}
+	if source.Name == "" {
+		source.Name = reported.Name
+	}
+	if source.Domain == "" {
+		source.Domain = domain
+	}
+	if source.Homepage == "" && reported.URL != "" {
+		source.Homepage = reported.URL
+	}
+	if source.Favicon == "" && source.Domain != "" {
+		source.Favicon = "https://" + source.Domain + "/favicon.ico"
+	}
+	if err := tx.Save(source).Error; err != nil {
+		return fmt.Errorf("Failed to save source: %v", err)
+	}
+	if err := saveAliases(tx, provider, source, externalID, reported.Name); err != nil {
+		return err
+	}
+
+	source.ExternalID = reported.ExternalID
+	article.Source = *source
+	article.SourceID = source.ID
+	return nil
+}
+
+// SyncNewsAPISources seeds the catalogue from News API's source list,
+// filling in description, country, language, category and homepage
+func SyncNewsAPISources(ctx context.Context) (int, error) {
+	sources, err := GetNewsAPISources(ctx, "", "", "")
+	if err != nil {
+		return 0, err
+	}
+
+	err = utils.DB.Transaction(func(tx *gorm.DB) error {
+		for _, entry := range sources {
+			domain := utils.RegistrableDomain(entry.URL)
+			source, err := resolveSource(tx, "newsapi", entry.ID, entry.Name, domain)
+			if err != nil {
+				return err
+			}
+			if source == nil {
+				source = &utils.Source{}
+			}
+			source.Name = entry.Name
+			source.URL = entry.URL
+			source.Domain = domain
+			source.Description = entry.Description
+			source.Country = entry.Country
+			source.Language = entry.Language
+			source.Category = entry.Category
+			source.Homepage = entry.URL
+			if domain != "" {
+				source.Favicon = "https://" + domain + "/favicon.ico"
+			}
+			if err := tx.Save(source).Error; err != nil {
+				return fmt.Errorf("Failed to save source %s: %v", entry.ID, err)
+			}
+			if err := saveAliases(tx, "newsapi", source, entry.ID, entry.Name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(sources), nil
+}
+
+// MergeSources folds duplicate sources into target: their articles and
+// aliases move over, missing metadata is copied and the duplicates are
+// deleted. Each duplicate's name stays resolvable as a provider-less alias.
+func MergeSources(tx *gorm.DB, target *utils.Source, duplicateIDs []uint) error {
+	for _, id := range duplicateIDs {
+		if id == target.ID {
+			continue
+		}
+		var duplicate utils.Source
+		if err := tx.First(&duplicate, id).Error; err != nil {
+			return fmt.Errorf("Failed to load source %d: %w", id, err)
+		}
+
+		if err := tx.Model(&utils.Article{}).Where("source_id = ?", duplicate.ID).Update("source_id", target.ID).Error; err != nil {
+			return fmt.Errorf("Failed to move articles of source %d: %v", id, err)
+		}
+		if err := tx.Model(&utils.SourceAlias{}).Where("source_id = ?", duplicate.ID).Update("source_id", target.ID).Error; err != nil {
+			return fmt.Errorf("Failed to move aliases of source %d: %v", id, err)
+		}
+		if err := saveAliases(tx, "", target, "", duplicate.Name); err != nil {
+			return err
+		}
+
+		for _, field := range []struct{ into, from *string }{
+			{&target.URL, &duplicate.URL},
+			{&target.Domain, &duplicate.Domain},
+			{&target.Description, &duplicate.Description},
+			{&target.Country, &duplicate.Country},
+			{&target.Language, &duplicate.Language},
+			{&target.Category, &duplicate.Category},
+			{&target.Homepage, &duplicate.Homepage},
+			{&target.Favicon, &duplicate.Favicon},
+		} {
+			if *field.into == "" {
+				*field.into = *field.from
+			}
+		}
+
+		if err := tx.Delete(&duplicate).Error; err != nil {
+			return fmt.Errorf("Failed to delete source %d: %v", id, err)
+		}
+	}
+
+	if err := tx.Omit("Aliases").Save(target).Error; err != nil {
+		return fmt.Errorf("Failed to save merged source: %v", err)
+	}
+	return nil
+}
+
+// ListSources lists and searches the source catalogue. q matches names,
+// aliases and domains; country, language, category and domain filter exactly.
+func ListSources(c *gin.Context) {
+	page, perPage := GetPaginationParams(c)
+
+	query := utils.DB.Model(&utils.Source{})
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		pattern := "%" + strings.ToLower(q) + "%"
+		query = query.Where("LOWER(sources.name) LIKE ? OR sources.domain LIKE ? OR sources.id IN (?)",
+			pattern, pattern,
+			utils.DB.Model(&utils.SourceAlias{}).Select("source_id").Where("value LIKE ?", pattern))
+	}
+	for _, field := range []string{"country", "language", "category", "domain"} {
+		if value := c.Query(field); value != "" {
+			query = query.Where("sources."+field+" = ?", strings.ToLower(value))
+		}
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var sources []utils.Source
+	if err := query.Order("sources.name").Offset((page - 1) * perPage).Limit(perPage).Find(&sources).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":    total,
+		"page":     page,
+		"per_page": perPage,
+		"sources":  sources,
+	})
+}
+
+// findSource loads a source by its id path parameter with its aliases
+func findSource(c *gin.Context) (*utils.Source, bool) {
+	id, ok := ParseIDParam(c, "id")
+	if !ok {
+		return nil, false
+	}
+	var source utils.Source
+	if err := utils.DB.Preload("Aliases").First(&source, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Source not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return &source, true
+}
+
+// GetSource returns one catalogue entry with its aliases
+func GetSource(c *gin.Context) {
+	source, ok := findSource(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, source)
+}
+
+// MergeSourcesRequest lists the sources to fold into the one in the path
+type MergeSourcesRequest struct {
+	SourceIDs []uint `json:"source_ids" binding:"required,min=1"`
+}
+
+// MergeSourcesHandler merges the sources in the request body into the source
+// given by the id path parameter
+func MergeSourcesHandler(c *gin.Context) {
+	target, ok := findSource(c)
+	if !ok {
+		return
+	}
+	var request MergeSourcesRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := utils.DB.Transaction(func(tx *gorm.DB) error {
+		return MergeSources(tx, target, request.SourceIDs)
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := utils.DB.Preload("Aliases").First(target, target.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, target)
+}
+
+// SyncSourcesHandler refreshes the catalogue from News API
+func SyncSourcesHandler(c *gin.Context) {
+	count, err := SyncNewsAPISources(c.Request.Context())
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"synced": count})
+}
@@ -0,0 +1,266 @@
+package endpoints
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_news_api/nlp"
+	"go_news_api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxSummarySentences caps the sentences parameter of the summary endpoints
+const maxSummarySentences = 20
+
+// summarySentences is the length of stored article summaries
+func summarySentences() int {
+	return utils.GetEnvInt("SUMMARY_SENTENCES", 3)
+}
+
+func summaryHash(text string, sentences int) string {
+	sum := sha256.Sum256([]byte(strconv.Itoa(sentences) + "\n" + text))
+	return hex.EncodeToString(sum[:])
+}
+
+// UpdateArticleSummary stores a summary of an article's extracted full text
+// unless the stored one was already made from the same text
+func UpdateArticleSummary(tx *gorm.DB, article *utils.Article, fullText string) error {
+	sentences := summarySentences()
+	hash := summaryHash(fullText, sentences)
+	if article.SummaryHash == hash {
+		return nil
+	}
+	article.Summary = strings.Join(nlp.Summarize(fullText, sentences), " ")
+	article.SummaryHash = hash
+	if err := tx.Model(article).Select("summary", "summary_hash").Updates(article).Error; err != nil {
+		return fmt.Errorf("Failed to save summary of article %d: %v", article.ID, err)
+	}
+	return nil
+}
+
+// RequeueExtraction has the extraction worker fetch an article's page again,
+// so that its full text, entities and summary follow a changed article
+func RequeueExtraction(tx *gorm.DB, articleID uint) error {
+	err := tx.Model(&utils.ArticleContent{}).
+		Where("article_id = ? AND status = ?", articleID, utils.ExtractionDone).
+		Updates(map[string]interface{}{
+			"status":          utils.ExtractionPending,
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+		}).Error
+	if err != nil {
+		return fmt.Errorf("Failed to queue article %d for extraction: %v", articleID, err)
+	}
+	return nil
+}
+
+// SummarizeMissingArticles summarizes every article with extracted full text
+// but no summary, such as those extracted before summaries existed
+func SummarizeMissingArticles(ctx context.Context) (int, error) {
+	count := 0
+	query := utils.DB.Where("(summary_hash IS NULL OR summary_hash = '') AND EXISTS (SELECT 1 FROM article_contents WHERE article_contents.article_id = articles.id AND article_contents.status = ? AND article_contents.deleted_at IS NULL)", utils.ExtractionDone)
+	err := eachArticleBatch(ctx, query, func(articles []utils.Article) error {
+		texts, err := fullTexts(articles)
+		if err != nil {
+			return err
+		}
+		for i := range articles {
+			article := &articles[i]
+			if err := UpdateArticleSummary(utils.DB, article, texts[article.ID]); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// extractedStatuses are the extraction states with usable full text; pages
+// queued again keep theirs until the new one is in
+var extractedStatuses = []string{utils.ExtractionDone, utils.ExtractionPending}
+
+// fullTexts returns the extracted full text of those articles that have one
+func fullTexts(articles []utils.Article) (map[uint]string, error) {
+	ids := make([]uint, len(articles))
+	for i, article := range articles {
+		ids[i] = article.ID
+	}
+	var contents []utils.ArticleContent
+	if err := utils.DB.Select("article_id", "full_text").
+		Where("article_id IN ? AND status IN ?", ids, extractedStatuses).
+		Find(&contents).Error; err != nil {
+		return nil, fmt.Errorf("Failed to load extracted content: %v", err)
+	}
+	texts := make(map[uint]string, len(contents))
+	for _, content := range contents {
+		if content.FullText != "" {
+			texts[content.ArticleID] = content.FullText
+		}
+	}
+	return texts, nil
+}
+
+// parseSentencesParam reads the sentences query parameter
+func parseSentencesParam(c *gin.Context, def int) (int, error) {
+	sentences, err := strconv.Atoi(c.DefaultQuery("sentences", strconv.Itoa(def)))
+	if err != nil || sentences < 1 || sentences > maxSummarySentences {
+		return 0, fmt.Errorf("%w: sentences must be between 1 and %d", ErrBadRequest, maxSummarySentences)
+	}
+	return sentences, nil
+}
+
+// GetArticleSummary returns an article's summary. The stored summary is used
+// when the default length is asked for; other lengths are made on the fly.
+// Articles without extracted full text are summarized from the provider's
+// snippet.
+func GetArticleSummary(c *gin.Context) {
+	article, ok := findArticle(c)
+	if !ok {
+		return
+	}
+	sentences, err := parseSentencesParam(c, summarySentences())
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	texts, err := fullTexts([]utils.Article{*article})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	source := "full_text"
+	text, ok := texts[article.ID]
+	if !ok {
+		source = "snippet"
+		text = article.Description + "\n" + article.Content
+	}
+
+	summary := article.Summary
+	if source == "snippet" || summary == "" || article.SummaryHash != summaryHash(text, sentences) {
+		summary = strings.Join(nlp.Summarize(text, sentences), " ")
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"article_id": article.ID,
+		"sentences":  sentences,
+		"source":     source,
+		"summary":    summary,
+	})
+}
+
+// SummarizedArticle identifies an article a story summary draws on
+type SummarizedArticle struct {
+	ID    uint   `json:"id"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// StorySentence is a sentence of a story summary and the article it is from
+type StorySentence struct {
+	Text      string  `json:"text"`
+	ArticleID uint    `json:"article_id"`
+	Score     float64 `json:"score"`
+}
+
+// maxStoryArticles caps the articles one story summary reads
+const maxStoryArticles = 30
+
+// GetStorySummary summarizes several articles at once: those listed in ids
+// (comma-separated) or those matching keyword (at most limit, default 20).
+// Sentences repeating a better one from another article are left out.
+func GetStorySummary(c *gin.Context) {
+	sentences, err := parseSentencesParam(c, 5)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	var articles []utils.Article
+	switch {
+	case c.Query("ids") != "":
+		var ids []uint
+		for _, raw := range strings.Split(c.Query("ids"), ",") {
+			id, err := strconv.ParseUint(strings.TrimSpace(raw), 10, 64)
+			if err != nil || id == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid article id %q", raw)})
+				return
+			}
+			ids = append(ids, uint(id))
+		}
+		if len(ids) > maxStoryArticles {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d ids can be summarized at once", maxStoryArticles)})
+			return
+		}
+		if err := utils.DB.Where("id IN ?", ids).Order("published_at DESC NULLS LAST").Find(&articles).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	case c.Query("keyword") != "":
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		if err != nil || limit < 1 || limit > maxStoryArticles {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxStoryArticles)})
+			return
+		}
+		filter, err := ParseArticleFilter(c.Request.URL.Query())
+		if err != nil {
+			RespondError(c, err)
+			return
+		}
+		lang, err := parseLangParam(c.Request.URL.Query())
+		if err != nil {
+			RespondError(c, err)
+			return
+		}
+		articles, _, err = SearchArticles(PrepareSearchQuery(c.Query("keyword")), lang, filter, 1, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Either ids or keyword is required"})
+		return
+	}
+	if len(articles) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No articles to summarize"})
+		return
+	}
+
+	texts, err := fullTexts(articles)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	documents := make([]string, len(articles))
+	summarized := make([]SummarizedArticle, len(articles))
+	for i, article := range articles {
+		documents[i] = texts[article.ID]
+		if documents[i] == "" {
+			documents[i] = article.Description + "\n" + article.Content
+		}
+		summarized[i] = SummarizedArticle{ID: article.ID, Title: article.Title, URL: article.URL}
+	}
+
+	ranked := nlp.SummarizeDocuments(documents, sentences)
+	story := make([]StorySentence, len(ranked))
+	parts := make([]string, len(ranked))
+	for i, sentence := range ranked {
+		story[i] = StorySentence{Text: sentence.Text, ArticleID: articles[sentence.Document].ID, Score: sentence.Score}
+		parts[i] = sentence.Text
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"articles":  summarized,
+		"sentences": story,
+		"summary":   strings.Join(parts, " "),
+	})
+}
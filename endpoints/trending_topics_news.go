@@ -1,6 +1,7 @@
 package endpoints
 
 import (
+	"context"
 	"fmt"
 	"go_news_api/utils"
 	"net/http"
@@ -27,75 +28,92 @@ func HandleTransactionError(tx *gorm.DB, c *gin.Context) {
 	}
 }
 
-func GetSelectedTopics(tx *gorm.DB, topicsCount int) ([]utils.TrendingTopic, error) {
-	allTrendingTopics, err := FetchTrendingTopics()
+func GetSelectedTopics(ctx context.Context, tx *gorm.DB, topicsCount int) ([]utils.TrendingTopic, error) {
+	allTrendingTopics, err := FetchTrendingTopics(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to fetch trending topics: %v", err)
+		return nil, fmt.Errorf("Failed to fetch trending topics: %w", err)
 	}
 	return utils.GetRandomTopics(allTrendingTopics, topicsCount), nil
 }
 
-func GetOrFetchAPIResponse(tx *gorm.DB, source string, selectedTopics []utils.TrendingTopic) (*utils.APIResponse, error) {
+// GetOrFetchAPIResponse returns today's stored results for the selected
+// topics, or fetches and saves new ones. It also returns the articles saved
+// by a fetch, to announce to ingestion listeners once the transaction
+// commits; stored results were announced when they were fetched.
+func GetOrFetchAPIResponse(ctx context.Context, tx *gorm.DB, source, country, language string, selectedTopics []utils.TrendingTopic) (*utils.APIResponse, []IngestedArticle, error) {
 	today := time.Now().Format("2006-01-02")
-	existingSearches, err := CheckExistingSearches(tx, selectedTopics, today)
+	existingSearches, err := CheckExistingSearches(tx, selectedTopics, country, language, today)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if len(existingSearches) == len(selectedTopics) {
-		return GetExistingAPIResponse(tx, source, today)
+		apiResponse, err := GetExistingAPIResponse(tx, source, country, language, today)
+		return apiResponse, nil, err
 	}
 
-	return FetchNewAPIResponse(tx, source, selectedTopics)
+	return FetchNewAPIResponse(ctx, tx, source, country, language, selectedTopics)
 }
 
-func CheckExistingSearches(tx *gorm.DB, selectedTopics []utils.TrendingTopic, today string) ([]utils.SearchQuery, error) {
+// CheckExistingSearches returns today's searches for the selected topics made
+// with the same requested country and language
+func CheckExistingSearches(tx *gorm.DB, selectedTopics []utils.TrendingTopic, country, language, today string) ([]utils.SearchQuery, error) {
 	var existingSearches []utils.SearchQuery
-	err := tx.Where("query IN (?) AND DATE(searched_at) = ?", GetTopicNames(selectedTopics), today).Find(&existingSearches).Error
+	err := tx.Where("query IN (?) AND country = ? AND language = ? AND DATE(searched_at) = ?", GetTopicNames(selectedTopics), country, language, today).Find(&existingSearches).Error
 	if err != nil {
 		return nil, fmt.Errorf("Failed to check existing searches: %v", err)
 	}
 	return existingSearches, nil
 }
 
-func GetExistingAPIResponse(tx *gorm.DB, source, today string) (*utils.APIResponse, error) {
+func GetExistingAPIResponse(tx *gorm.DB, source, country, language, today string) (*utils.APIResponse, error) {
 	var apiResponse utils.APIResponse
-	err := tx.Where("api_source = ? AND type = ? AND DATE(created_at) = ?", source, "topic", today).First(&apiResponse).Error
+	query := tx.Where("type = ? AND DATE(created_at) = ?", "topic", today)
+	// In auto mode any provider's results will do
+	if source != SourceAuto {
+		query = query.Where("api_source = ?", source)
+	}
+	// Empty values mean the provider default, which differs between providers
+	if country != "" {
+		query = query.Where("country = ?", country)
+	}
+	if language != "" {
+		query = query.Where("language = ?", language)
+	}
+	err := query.Order("created_at DESC").First(&apiResponse).Error
 	if err != nil {
 		return nil, fmt.Errorf("Failed to retrieve existing results: %v", err)
 	}
+	if err := LoadSightedArticles(tx, &apiResponse); err != nil {
+		return nil, fmt.Errorf("Failed to retrieve existing articles: %v", err)
+	}
 	return &apiResponse, nil
 }
 
-func FetchNewAPIResponse(tx *gorm.DB, source string, selectedTopics []utils.TrendingTopic) (*utils.APIResponse, error) {
-	apiResponse, err := FetchAPIResponse(source, selectedTopics)
+func FetchNewAPIResponse(ctx context.Context, tx *gorm.DB, source, country, language string, selectedTopics []utils.TrendingTopic) (*utils.APIResponse, []IngestedArticle, error) {
+	apiResponse, err := FetchAPIResponse(ctx, source, country, language, selectedTopics)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	err = SaveAPIResponse(tx, apiResponse, selectedTopics)
+	ingested, err := SaveAPIResponse(tx, apiResponse, selectedTopics)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return apiResponse, nil
-}
-
-func FetchAPIResponse(source string, selectedTopics []utils.TrendingTopic) (*utils.APIResponse, error) {
-	var apiResponse *utils.APIResponse
-	var err error
-
-	switch source {
-	case "newsapi":
-		apiResponse, err = GetNewsAPITrendingTopicsNews(selectedTopics)
-	case "gnews":
-		apiResponse, err = GetGNewsTrendingTopicsNews(selectedTopics)
-	default:
-		return nil, fmt.Errorf("Invalid source")
+	// Record the requested locale rather than the provider default that was
+	// used so that the next identical request finds these searches
+	if err := SaveSearchQueries(tx, selectedTopics, country, language, apiResponse); err != nil {
+		return nil, nil, err
 	}
 
+	return apiResponse, ingested, nil
+}
+
+func FetchAPIResponse(ctx context.Context, source, country, language string, selectedTopics []utils.TrendingTopic) (*utils.APIResponse, error) {
+	apiResponse, err := FetchTrendingTopicsNews(ctx, source, selectedTopics, country, language)
 	if err != nil {
-		return nil, fmt.Errorf("API request failed: %v", err)
+		return nil, fmt.Errorf("API request failed: %w", err)
 	}
 
 	apiResponse.Type = "topic"
@@ -104,40 +122,40 @@ func FetchAPIResponse(source string, selectedTopics []utils.TrendingTopic) (*uti
 	return apiResponse, nil
 }
 
-func SaveAPIResponse(tx *gorm.DB, apiResponse *utils.APIResponse, selectedTopics []utils.TrendingTopic) error {
-	var existingResponse utils.APIResponse
-	if err := tx.Where(utils.APIResponse{APISource: apiResponse.APISource, Type: apiResponse.Type}).
-		Attrs(utils.APIResponse{
-			Status:        apiResponse.Status,
-			TotalResults:  apiResponse.TotalResults,
-			TotalArticles: apiResponse.TotalArticles,
-			Topic:         apiResponse.Topic,
-		}).FirstOrCreate(&existingResponse).Error; err != nil {
-		return fmt.Errorf("Failed to save or update API response: %v", err)
+// SaveAPIResponse stores one provider fetch together with its articles. Every
+// fetch gets its own row so that article sightings can point at it.
+func SaveAPIResponse(tx *gorm.DB, apiResponse *utils.APIResponse, selectedTopics []utils.TrendingTopic) ([]IngestedArticle, error) {
+	// Articles are saved one by one below so existing ones are updated in place
+	if err := tx.Omit("Articles").Create(apiResponse).Error; err != nil {
+		return nil, fmt.Errorf("Failed to save API response: %v", err)
 	}
 
-	existingResponse.Articles = apiResponse.Articles
-	if err := tx.Save(&existingResponse).Error; err != nil {
-		return fmt.Errorf("Failed to update API response articles: %v", err)
-	}
-
-	*apiResponse = existingResponse
+	return SaveArticles(tx, apiResponse)
+}
 
-	if err := SaveSearchQueries(tx, selectedTopics, apiResponse); err != nil {
+// SaveTopHeadlines stores a top headlines fetch in its own transaction and
+// announces the articles to ingestion listeners
+func SaveTopHeadlines(apiResponse *utils.APIResponse) error {
+	tx := utils.DB.Begin()
+	ingested, err := SaveAPIResponse(tx, apiResponse, nil)
+	if err != nil {
+		tx.Rollback()
 		return err
 	}
-
-	if err := SaveArticles(tx, apiResponse); err != nil {
-		return err
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("Failed to commit transaction: %v", err)
 	}
 
+	PublishIngestedArticles(apiResponse.APISource, ingested)
 	return nil
 }
 
-func SaveSearchQueries(tx *gorm.DB, selectedTopics []utils.TrendingTopic, apiResponse *utils.APIResponse) error {
+func SaveSearchQueries(tx *gorm.DB, selectedTopics []utils.TrendingTopic, country, language string, apiResponse *utils.APIResponse) error {
 	for _, topic := range selectedTopics {
 		searchQuery := utils.SearchQuery{
 			Query:       topic.Topic,
+			Country:     country,
+			Language:    language,
 			SearchedAt:  time.Now(),
 			ResultCount: len(apiResponse.Articles),
 		}
@@ -148,70 +166,257 @@ func SaveSearchQueries(tx *gorm.DB, selectedTopics []utils.TrendingTopic, apiRes
 	return nil
 }
 
-func SaveArticles(tx *gorm.DB, apiResponse *utils.APIResponse) error {
-	for _, article := range apiResponse.Articles {
-		if err := SaveSource(tx, &article); err != nil {
-			return err
-		}
+// SaveMode says where the articles SaveArticle stores come from
+type SaveMode int
 
-		if err := SaveOrUpdateArticle(tx, apiResponse, &article); err != nil {
-			return err
-		}
+const (
+	// SaveFetched articles come from a provider fetch
+	SaveFetched SaveMode = iota
+	// SaveImported articles come from an archive import. They are history
+	// rather than news: creating one queues no article.created event, it
+	// counts as first seen when it was published, and a copy older than the
+	// stored article's last sighting only fills in what the article lacks.
+	SaveImported
+	// SaveReplayed articles come from replaying an archived payload. They
+	// are history too, seen when the payload was fetched: only the payload
+	// of an article's last sighting may change it, older ones only fill in
+	// what the article lacks.
+	SaveReplayed
+)
 
-		if err := SaveKeywords(tx, &article); err != nil {
-			return err
+// replayedFetchSlack is how long before an article's last sighting the
+// payload of that sighting may have been fetched: a fetch is saved only after
+// all of its requests are done
+const replayedFetchSlack = 5 * time.Minute
+
+// olderThanStored reports whether an imported or replayed copy of an article,
+// seen at seenAt, predates the stored article's last sighting
+func olderThanStored(mode SaveMode, seenAt time.Time, existing *utils.Article) bool {
+	if mode == SaveFetched || existing.LastSeenAt == nil {
+		return false
+	}
+	lastSeen := *existing.LastSeenAt
+	if mode == SaveReplayed {
+		lastSeen = lastSeen.Add(-replayedFetchSlack)
+	}
+	return seenAt.Before(lastSeen)
+}
+
+// SaveArticles stores the articles of a fetch and returns them as saved, to
+// be announced to ingestion listeners once the transaction commits
+func SaveArticles(tx *gorm.DB, apiResponse *utils.APIResponse) ([]IngestedArticle, error) {
+	return saveArticles(tx, apiResponse, SaveFetched)
+}
+
+func saveArticles(tx *gorm.DB, apiResponse *utils.APIResponse, mode SaveMode) ([]IngestedArticle, error) {
+	ingested := make([]IngestedArticle, 0, len(apiResponse.Articles))
+	for i := range apiResponse.Articles {
+		created, err := SaveArticle(tx, apiResponse, &apiResponse.Articles[i], i+1, mode)
+		if err != nil {
+			return nil, err
 		}
+		ingested = append(ingested, IngestedArticle{Article: apiResponse.Articles[i], Created: created, Imported: mode != SaveFetched})
 	}
-	return nil
+	return ingested, nil
 }
 
-func SaveSource(tx *gorm.DB, article *utils.Article) error {
-	if err := tx.Where(utils.Source{Name: article.Source.Name}).FirstOrCreate(&article.Source).Error; err != nil {
-		return fmt.Errorf("Failed to save source: %v", err)
+// SaveArticle stores one article of a fetch, seen at the given position,
+// with its source, sighting and keywords. It reports whether the article
+// was created rather than updated.
+func SaveArticle(tx *gorm.DB, apiResponse *utils.APIResponse, article *utils.Article, position int, mode SaveMode) (bool, error) {
+	if err := SaveSource(tx, apiResponse.APISource, article); err != nil {
+		return false, err
 	}
-	return nil
+
+	created, err := SaveOrUpdateArticle(tx, apiResponse, article, mode)
+	if err != nil {
+		return false, err
+	}
+
+	if err := SaveSighting(tx, apiResponse, article, position); err != nil {
+		return false, err
+	}
+
+	return created, SaveKeywords(tx, article)
 }
 
-func SaveOrUpdateArticle(tx *gorm.DB, apiResponse *utils.APIResponse, article *utils.Article) error {
+// SaveOrUpdateArticle creates an article or updates the stored article with
+// the same URL, and reports whether it was created
+func SaveOrUpdateArticle(tx *gorm.DB, apiResponse *utils.APIResponse, article *utils.Article, mode SaveMode) (bool, error) {
+	seenAt := apiResponse.CreatedAt
+	if mode == SaveImported && article.PublishedAt != nil && article.PublishedAt.Before(seenAt) {
+		seenAt = *article.PublishedAt
+	}
 	var existingArticle utils.Article
 	result := tx.Where("url = ?", article.URL).First(&existingArticle)
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
 			// Article doesn't exist, create a new one
 			article.APIResponseID = apiResponse.ID
+			article.FirstSeenAt = &seenAt
+			article.LastSeenAt = &seenAt
+			article.ContentHash = ArticleContentHash(article)
+			article.RequestedCountry = apiResponse.Country
+			article.RequestedLanguage = apiResponse.Language
+			utils.DetectArticleLanguage(article, articleText(article), apiResponse.Language)
+			CategorizeArticle(apiResponse, article, true)
 			if err := tx.Create(article).Error; err != nil {
-				return fmt.Errorf("Failed to create new article: %v", err)
+				return false, fmt.Errorf("Failed to create new article: %v", err)
+			}
+			if err := SaveEntities(tx, article, articleText(article)); err != nil {
+				return false, err
+			}
+			if err := SaveArticleVector(tx, article, articleText(article)); err != nil {
+				return false, err
 			}
+			if mode == SaveFetched {
+				if err := EnqueueArticleCreated(tx, article); err != nil {
+					return false, err
+				}
+			}
+			return true, nil
 		} else {
 			// Some other error occurred
-			return fmt.Errorf("Error checking for existing article: %v", result.Error)
+			return false, fmt.Errorf("Error checking for existing article: %v", result.Error)
+		}
+	} else if olderThanStored(mode, seenAt, &existingArticle) {
+		// A copy older than the stored one must not undo later changes
+		if err := mergeOlderCopy(tx, &existingArticle, article, seenAt); err != nil {
+			return false, err
 		}
+		*article = existingArticle
 	} else {
-		// Article exists, update it
-		existingArticle.APIResponseID = apiResponse.ID
-		existingArticle.Author = article.Author
+		// Article exists, update it and keep what changed as a revision
+		before := existingArticle
+		// Replayed and imported fetches may be older than the last sighting
+		if existingArticle.LastSeenAt == nil || seenAt.After(*existingArticle.LastSeenAt) {
+			existingArticle.LastSeenAt = &seenAt
+		}
+		if existingArticle.FirstSeenAt == nil || seenAt.Before(*existingArticle.FirstSeenAt) {
+			existingArticle.FirstSeenAt = &seenAt
+		}
 		existingArticle.Title = article.Title
-		existingArticle.Description = article.Description
-		existingArticle.PublishedAt = article.PublishedAt
 		existingArticle.Content = article.Content
-		existingArticle.URLToImage = article.URLToImage
+		// Keep values filled in by enrichment when the provider has none
+		if article.Author != "" {
+			existingArticle.Author = article.Author
+		}
+		keepDescription, err := keepsEnrichedDescription(tx, &existingArticle, article.Description)
+		if err != nil {
+			return false, err
+		}
+		if article.Description != "" && !keepDescription {
+			existingArticle.Description = article.Description
+		}
+		if article.PublishedAt != nil {
+			existingArticle.PublishedAt = article.PublishedAt
+			existingArticle.PublishedAtRaw = article.PublishedAtRaw
+		}
+		if article.URLToImage != "" {
+			existingArticle.URLToImage = article.URLToImage
+		}
+		existingArticle.SourceID = article.SourceID
+		if err := SaveRevision(tx, apiResponse, &before, &existingArticle); err != nil {
+			return false, err
+		}
+		if existingArticle.Language == "" || existingArticle.Language == utils.UndeterminedLanguage || existingArticle.ContentHash != before.ContentHash {
+			utils.DetectArticleLanguage(&existingArticle, articleText(&existingArticle), apiResponse.Language)
+		}
+		CategorizeArticle(apiResponse, &existingArticle, existingArticle.ContentHash != before.ContentHash)
 		if err := tx.Save(&existingArticle).Error; err != nil {
-			return fmt.Errorf("Failed to update existing article: %v", err)
+			return false, fmt.Errorf("Failed to update existing article: %v", err)
+		}
+		if existingArticle.ContentHash != before.ContentHash {
+			text := entityText(tx, &existingArticle)
+			if err := SaveEntities(tx, &existingArticle, text); err != nil {
+				return false, err
+			}
+			if err := SaveArticleVector(tx, &existingArticle, text); err != nil {
+				return false, err
+			}
+			// The page changed too, so its full text and summary are stale
+			if err := RequeueExtraction(tx, existingArticle.ID); err != nil {
+				return false, err
+			}
 		}
+		existingArticle.Source = article.Source
 		*article = existingArticle
 	}
+	return false, nil
+}
+
+// mergeOlderCopy merges a copy of an article older than its last sighting,
+// as an import may hold, into the stored article. It can date the article
+// back and fill fields that are empty, but replaces nothing a later fetch
+// stored, so it records no revision and leaves the extracted page alone.
+func mergeOlderCopy(tx *gorm.DB, existing, article *utils.Article, seenAt time.Time) error {
+	if existing.FirstSeenAt == nil || seenAt.Before(*existing.FirstSeenAt) {
+		existing.FirstSeenAt = &seenAt
+	}
+	for _, field := range []struct {
+		stored *string
+		older  string
+	}{
+		{&existing.Title, article.Title},
+		{&existing.Description, article.Description},
+		{&existing.Content, article.Content},
+		{&existing.Author, article.Author},
+		{&existing.URLToImage, article.URLToImage},
+	} {
+		if *field.stored == "" {
+			*field.stored = field.older
+		}
+	}
+	if existing.PublishedAt == nil && article.PublishedAt != nil {
+		existing.PublishedAt = article.PublishedAt
+		existing.PublishedAtRaw = article.PublishedAtRaw
+	}
+	if existing.SourceID == 0 {
+		existing.SourceID = article.SourceID
+	}
+
+	previousHash := existing.ContentHash
+	existing.ContentHash = ArticleContentHash(existing)
+	if err := tx.Save(existing).Error; err != nil {
+		return fmt.Errorf("Failed to update existing article: %v", err)
+	}
+	if existing.ContentHash != previousHash {
+		text := entityText(tx, existing)
+		if err := SaveEntities(tx, existing, text); err != nil {
+			return err
+		}
+		if err := SaveArticleVector(tx, existing, text); err != nil {
+			return err
+		}
+	}
+	if existing.SourceID == article.SourceID {
+		existing.Source = article.Source
+	} else if err := tx.First(&existing.Source, existing.SourceID).Error; err != nil {
+		return fmt.Errorf("Failed to load article source: %v", err)
+	}
 	return nil
 }
 
+// SaveKeywords links an article to the keywords of its title and
+// description, counting it in the keyword rollups the first time it is linked
+// to each
 func SaveKeywords(tx *gorm.DB, article *utils.Article) error {
 	keywords := ExtractKeywords(article.Title + " " + article.Description)
+	bucket := keywordBucket(article)
 	for _, word := range keywords {
 		var keyword utils.Keyword
 		if err := tx.Where(utils.Keyword{Word: word}).FirstOrCreate(&keyword).Error; err != nil {
 			return fmt.Errorf("Failed to save keyword: %v", err)
 		}
-		if err := tx.Model(article).Association("Keywords").Append(&keyword); err != nil {
-			return fmt.Errorf("Failed to associate keyword with article: %v", err)
+		result := tx.Exec("INSERT INTO article_keywords (article_id, keyword_id) VALUES (?, ?) ON CONFLICT DO NOTHING", article.ID, keyword.ID)
+		if result.Error != nil {
+			return fmt.Errorf("Failed to associate keyword with article: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		if err := countKeyword(tx, keyword.ID, article.SourceID, bucket); err != nil {
+			return err
 		}
 	}
 	return nil
@@ -2,32 +2,31 @@ package endpoints
 
 import (
 	"fmt"
+	"go_news_api/nlp"
 	"go_news_api/utils"
+	"net/http"
+	"strconv"
 	"strings"
 	"time"
-)
-
-// requestCount keeps track of the number of requests made
-var requestCount int
-
-// maxRequestsPerDay is the maximum number of requests allowed per day
-const maxRequestsPerDay = 100
+	"unicode"
+	"unicode/utf8"
 
-func CheckRequestLimit() error {
-	// Reset requestCount at midnight
-	now := time.Now()
-	if now.Hour() == 0 && now.Minute() == 0 {
-		requestCount = 0
-	}
+	"github.com/gin-gonic/gin"
+)
 
-	// Check if the request limit has been reached
-	if requestCount >= maxRequestsPerDay {
-		return fmt.Errorf("request limit reached for today")
+// ParseIDParam reads a numeric path parameter, answering 400 when it is invalid
+func ParseIDParam(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name})
+		return 0, false
 	}
+	return uint(id), true
+}
 
-	// Increment requestCount
-	requestCount++
-	return nil
+// articleText joins the text fields of an article for analysis
+func articleText(article *utils.Article) string {
+	return strings.Join([]string{article.Title, article.Description, article.Content}, "\n")
 }
 
 // Helper function to get topic names from TrendingTopic slice
@@ -54,13 +53,16 @@ func CombineAPIResponses(responses []utils.APIResponse) utils.APIResponse {
 	}
 }
 
-// Helper function to extract keywords from text
+// ExtractKeywords returns the distinct words of text longer than three
+// letters, lowercased and without surrounding punctuation or stopwords
 func ExtractKeywords(text string) []string {
-	// This is a simple implementation. You might want to use a more sophisticated method or library.
 	words := strings.Fields(strings.ToLower(text))
 	uniqueWords := make(map[string]bool)
 	for _, word := range words {
-		if len(word) > 3 { // Only consider words longer than 3 characters
+		word = strings.TrimFunc(word, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		})
+		if utf8.RuneCountInString(word) > 3 && !nlp.IsStopword(word) {
 			uniqueWords[word] = true
 		}
 	}
@@ -70,3 +72,22 @@ func ExtractKeywords(text string) []string {
 	}
 	return keywords
 }
+
+// parseWindow reads a time window such as "24h", "7d" or "2w"
+func parseWindow(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if count, found := strings.CutSuffix(value, suffix); found {
+			n, err := strconv.Atoi(count)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("%w: invalid window %q", ErrBadRequest, value)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%w: invalid window %q (use e.g. 24h, 7d or 2w)", ErrBadRequest, value)
+	}
+	return d, nil
+}
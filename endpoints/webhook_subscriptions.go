@@ -0,0 +1,269 @@
+package endpoints
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go_news_api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// WebhookRequest is the body of a webhook subscription create or update.
+// keyword and source (a domain such as bbc.co.uk) narrow the events sent. A
+// secret is generated when none is given; on update a blank secret keeps the
+// current one.
+type WebhookRequest struct {
+	URL        string   `json:"url" binding:"required"`
+	EventTypes []string `json:"event_types" binding:"required"`
+	Secret     string   `json:"secret"`
+	Keyword    string   `json:"keyword"`
+	Source     string   `json:"source"`
+	Active     *bool    `json:"active"`
+}
+
+// apply validates the request and copies it into subscription
+func (r WebhookRequest) apply(subscription *utils.WebhookSubscription) error {
+	target, err := url.Parse(strings.TrimSpace(r.URL))
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrBadRequest)
+	}
+	subscription.URL = target.String()
+
+	if len(r.EventTypes) == 0 {
+		return fmt.Errorf("%w: event_types must not be empty", ErrBadRequest)
+	}
+	subscription.EventTypes = subscription.EventTypes[:0]
+	for _, eventType := range r.EventTypes {
+		known := false
+		for _, t := range WebhookEventTypes {
+			known = known || t == eventType
+		}
+		if !known {
+			return fmt.Errorf("%w: event type must be one of %s", ErrBadRequest, strings.Join(WebhookEventTypes, ", "))
+		}
+		if !subscribesTo(subscription, eventType) {
+			subscription.EventTypes = append(subscription.EventTypes, eventType)
+		}
+	}
+
+	subscription.Keyword = strings.TrimSpace(r.Keyword)
+	subscription.SourceDomain = ""
+	if r.Source != "" {
+		if subscription.SourceDomain = utils.RegistrableDomain(r.Source); subscription.SourceDomain == "" {
+			return fmt.Errorf("%w: invalid source domain %q", ErrBadRequest, r.Source)
+		}
+	}
+	if r.Secret != "" {
+		subscription.Secret = r.Secret
+	} else if subscription.Secret == "" {
+		subscription.Secret = randomHex(32)
+	}
+	if r.Active != nil {
+		subscription.Active = *r.Active
+	} else if subscription.ID == 0 {
+		subscription.Active = true
+	}
+	return nil
+}
+
+// findWebhook loads a webhook subscription by its id path parameter
+func findWebhook(c *gin.Context) (*utils.WebhookSubscription, bool) {
+	id, ok := ParseIDParam(c, "id")
+	if !ok {
+		return nil, false
+	}
+	var subscription utils.WebhookSubscription
+	if err := utils.DB.First(&subscription, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return &subscription, true
+}
+
+// CreateWebhook subscribes a URL to webhook events. The response is the only
+// one to include the signing secret.
+func CreateWebhook(c *gin.Context) {
+	var request WebhookRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var subscription utils.WebhookSubscription
+	if err := request.apply(&subscription); err != nil {
+		RespondError(c, err)
+		return
+	}
+	if err := utils.DB.Create(&subscription).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, subscription)
+}
+
+// ListWebhooks lists the webhook subscriptions
+func ListWebhooks(c *gin.Context) {
+	var subscriptions []utils.WebhookSubscription
+	if err := utils.DB.Order("id").Find(&subscriptions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range subscriptions {
+		subscriptions[i].Secret = ""
+	}
+	c.JSON(http.StatusOK, gin.H{"webhooks": subscriptions})
+}
+
+// GetWebhook returns a webhook subscription with its delivery counts by
+// status
+func GetWebhook(c *gin.Context) {
+	subscription, ok := findWebhook(c)
+	if !ok {
+		return
+	}
+	var counts []struct {
+		Status string
+		Count  int64
+	}
+	if err := utils.DB.Model(&utils.WebhookDelivery{}).
+		Select("status, COUNT(*) AS count").
+		Where("subscription_id = ?", subscription.ID).
+		Group("status").
+		Scan(&counts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	deliveries := map[string]int64{utils.DeliveryPending: 0, utils.DeliveryDelivered: 0, utils.DeliveryDead: 0}
+	for _, count := range counts {
+		deliveries[count.Status] = count.Count
+	}
+	subscription.Secret = ""
+	c.JSON(http.StatusOK, gin.H{"webhook": subscription, "deliveries": deliveries})
+}
+
+// UpdateWebhook replaces a webhook subscription's URL, events and filters
+func UpdateWebhook(c *gin.Context) {
+	subscription, ok := findWebhook(c)
+	if !ok {
+		return
+	}
+	var request WebhookRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := request.apply(subscription); err != nil {
+		RespondError(c, err)
+		return
+	}
+	if err := utils.DB.Save(subscription).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	subscription.Secret = ""
+	c.JSON(http.StatusOK, subscription)
+}
+
+// DeleteWebhook deletes a webhook subscription. Its pending deliveries are
+// dead-lettered; the delivery log is kept.
+func DeleteWebhook(c *gin.Context) {
+	subscription, ok := findWebhook(c)
+	if !ok {
+		return
+	}
+	err := utils.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&utils.WebhookDelivery{}).
+			Where("subscription_id = ? AND status = ?", subscription.ID, utils.DeliveryPending).
+			Updates(map[string]interface{}{"status": utils.DeliveryDead, "last_error": "subscription deleted"}).Error; err != nil {
+			return fmt.Errorf("Failed to cancel pending deliveries: %v", err)
+		}
+		return tx.Delete(subscription).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListWebhookDeliveries lists the deliveries of a webhook subscription,
+// newest first, optionally only those with a status
+func ListWebhookDeliveries(c *gin.Context) {
+	subscription, ok := findWebhook(c)
+	if !ok {
+		return
+	}
+	page, perPage := GetPaginationParams(c)
+
+	query := utils.DB.Model(&utils.WebhookDelivery{}).Where("subscription_id = ?", subscription.ID)
+	if status := c.Query("status"); status != "" {
+		if status != utils.DeliveryPending && status != utils.DeliveryDelivered && status != utils.DeliveryDead {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("status must be %s, %s or %s", utils.DeliveryPending, utils.DeliveryDelivered, utils.DeliveryDead)})
+			return
+		}
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var deliveries []utils.WebhookDelivery
+	if err := query.Order("id DESC").
+		Offset((page - 1) * perPage).Limit(perPage).
+		Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"webhook_id": subscription.ID,
+		"total":      total,
+		"page":       page,
+		"per_page":   perPage,
+		"deliveries": deliveries,
+	})
+}
+
+// RetryWebhookDelivery queues a dead delivery again, with a fresh set of
+// attempts
+func RetryWebhookDelivery(c *gin.Context) {
+	id, ok := ParseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var delivery utils.WebhookDelivery
+	if err := utils.DB.First(&delivery, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if delivery.Status != utils.DeliveryDead {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Delivery is %s, only dead deliveries can be retried", delivery.Status)})
+		return
+	}
+
+	if err := utils.DB.Model(&delivery).Updates(map[string]interface{}{
+		"status":          utils.DeliveryPending,
+		"attempts":        0,
+		"next_attempt_at": time.Now(),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	WakeWebhookDispatcher()
+	c.JSON(http.StatusAccepted, delivery)
+}
@@ -0,0 +1,392 @@
+package endpoints
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go_news_api/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Webhook event types
+const (
+	EventArticleCreated  = "article.created"
+	EventTrendingUpdated = "trending.updated"
+)
+
+// WebhookEventTypes are the event types subscriptions can ask for
+var WebhookEventTypes = []string{EventArticleCreated, EventTrendingUpdated}
+
+// Headers of a webhook request. The signature is the hex HMAC-SHA256, keyed
+// with the subscription's secret, of the timestamp, a dot and the body.
+const (
+	WebhookIDHeader        = "X-Webhook-ID"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// SignWebhook computes the signature header value of a webhook body
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// randomHex returns n random bytes, hex encoded
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// subscribesTo reports whether a subscription asked for an event type
+func subscribesTo(subscription *utils.WebhookSubscription, eventType string) bool {
+	for _, t := range subscription.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// enqueueWebhookEvent adds a delivery of an event to the outbox for every
+// active subscription to its type that match accepts. Call it inside the
+// transaction that makes the change, so the event is stored with it.
+func enqueueWebhookEvent(tx *gorm.DB, eventType string, data interface{}, match func(subscription *utils.WebhookSubscription) bool) error {
+	var subscriptions []utils.WebhookSubscription
+	if err := tx.Where("active = ?", true).Find(&subscriptions).Error; err != nil {
+		return fmt.Errorf("Failed to load webhook subscriptions: %v", err)
+	}
+
+	var event *utils.WebhookEvent
+	var payload []byte
+	for i := range subscriptions {
+		subscription := &subscriptions[i]
+		if !subscribesTo(subscription, eventType) || !match(subscription) {
+			continue
+		}
+		if event == nil {
+			event = &utils.WebhookEvent{ID: randomHex(16), Type: eventType, CreatedAt: time.Now().UTC(), Data: data}
+			var err error
+			if payload, err = json.Marshal(event); err != nil {
+				return fmt.Errorf("Failed to encode %s event: %v", eventType, err)
+			}
+		}
+		delivery := utils.WebhookDelivery{
+			SubscriptionID: subscription.ID,
+			EventID:        event.ID,
+			EventType:      eventType,
+			Payload:        payload,
+			Status:         utils.DeliveryPending,
+			NextAttemptAt:  time.Now(),
+		}
+		if err := tx.Create(&delivery).Error; err != nil {
+			return fmt.Errorf("Failed to queue webhook delivery: %v", err)
+		}
+	}
+	return nil
+}
+
+// containsFold reports whether text contains keyword, ignoring case
+func containsFold(text, keyword string) bool {
+	return strings.Contains(strings.ToLower(text), strings.ToLower(keyword))
+}
+
+// EnqueueArticleCreated queues article.created events for a new article.
+// Subscriptions with a keyword need it in the title or description, those
+// with a source need the article to come from that domain.
+func EnqueueArticleCreated(tx *gorm.DB, article *utils.Article) error {
+	return enqueueWebhookEvent(tx, EventArticleCreated, article, func(subscription *utils.WebhookSubscription) bool {
+		if subscription.Keyword != "" && !containsFold(article.Title+"\n"+article.Description, subscription.Keyword) {
+			return false
+		}
+		return subscription.SourceDomain == "" || subscription.SourceDomain == article.Source.Domain
+	})
+}
+
+// EnqueueTrendingUpdated queues trending.updated events for a new list of
+// trending topics. Subscriptions with a keyword need a topic containing it;
+// source filters do not apply.
+func EnqueueTrendingUpdated(tx *gorm.DB, topics []utils.TrendingTopic) error {
+	return enqueueWebhookEvent(tx, EventTrendingUpdated, map[string]interface{}{"topics": topics}, func(subscription *utils.WebhookSubscription) bool {
+		if subscription.Keyword == "" {
+			return true
+		}
+		for _, topic := range topics {
+			if containsFold(topic.Topic, subscription.Keyword) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// WebhookDispatcher delivers the queued webhook events, retrying failures
+// with exponential backoff until MaxAttempts, after which a delivery is dead
+type WebhookDispatcher struct {
+	BatchSize     int
+	Concurrency   int
+	MaxAttempts   int
+	PollInterval  time.Duration
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+	Timeout       time.Duration
+
+	client *http.Client
+	wake   chan struct{}
+}
+
+var webhookDispatcher *WebhookDispatcher
+
+// StartWebhookDispatcher starts delivering webhooks in the background unless
+// WEBHOOKS_ENABLED is "false". It wakes up when events are queued and
+// otherwise polls every WEBHOOK_POLL_INTERVAL.
+func StartWebhookDispatcher(ctx context.Context) {
+	if os.Getenv("WEBHOOKS_ENABLED") == "false" {
+		log.Println("Webhook delivery is disabled")
+		return
+	}
+
+	d := &WebhookDispatcher{
+		BatchSize:     utils.GetEnvInt("WEBHOOK_BATCH_SIZE", 50),
+		Concurrency:   utils.GetEnvInt("WEBHOOK_CONCURRENCY", 4),
+		MaxAttempts:   utils.GetEnvInt("WEBHOOK_MAX_ATTEMPTS", 8),
+		PollInterval:  utils.GetEnvDuration("WEBHOOK_POLL_INTERVAL", 10*time.Second),
+		RetryDelay:    utils.GetEnvDuration("WEBHOOK_RETRY_DELAY", 30*time.Second),
+		MaxRetryDelay: utils.GetEnvDuration("WEBHOOK_MAX_RETRY_DELAY", 6*time.Hour),
+		Timeout:       utils.GetEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		wake:          make(chan struct{}, 1),
+	}
+	d.client = &http.Client{Timeout: d.Timeout}
+	webhookDispatcher = d
+	OnArticlesIngested(func(provider string, articles []IngestedArticle) {
+		d.Wake()
+	})
+	go d.run(ctx)
+}
+
+// Wake asks the dispatcher to look for due deliveries now
+func (d *WebhookDispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// WakeWebhookDispatcher wakes the running dispatcher, if any, after events
+// were queued outside ingestion
+func WakeWebhookDispatcher() {
+	if webhookDispatcher != nil {
+		webhookDispatcher.Wake()
+	}
+}
+
+func (d *WebhookDispatcher) run(ctx context.Context) {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Keep going while full batches come back
+		for ctx.Err() == nil {
+			processed, err := d.processBatch(ctx)
+			if err != nil {
+				log.Printf("Webhook batch failed: %v", err)
+				break
+			}
+			if processed < d.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// leaseDuration is how long a claimed batch may take: the batch is sent in
+// rounds of Concurrency deliveries, each taking up to Timeout, plus one more
+// Timeout for recording the outcomes
+func (d *WebhookDispatcher) leaseDuration() time.Duration {
+	concurrency := d.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	rounds := (d.BatchSize + concurrency - 1) / concurrency
+	return time.Duration(rounds+1) * d.Timeout
+}
+
+// claimBatch takes due deliveries off the outbox. They are leased by moving
+// their next attempt past the time the whole batch may take, so that other
+// instances skip them while they are queued or in flight.
+func (d *WebhookDispatcher) claimBatch() ([]utils.WebhookDelivery, error) {
+	var deliveries []utils.WebhookDelivery
+	err := utils.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", utils.DeliveryPending, now).
+			Order("next_attempt_at").
+			Limit(d.BatchSize).
+			Find(&deliveries).Error; err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+		ids := make([]uint, len(deliveries))
+		for i, delivery := range deliveries {
+			ids[i] = delivery.ID
+		}
+		return tx.Model(&utils.WebhookDelivery{}).Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(d.leaseDuration())).Error
+	})
+	return deliveries, err
+}
+
+// processBatch attempts one batch of due deliveries
+func (d *WebhookDispatcher) processBatch(ctx context.Context) (int, error) {
+	deliveries, err := d.claimBatch()
+	if err != nil || len(deliveries) == 0 {
+		return 0, err
+	}
+
+	ids := map[uint]bool{}
+	for _, delivery := range deliveries {
+		ids[delivery.SubscriptionID] = true
+	}
+	subscriptionIDs := make([]uint, 0, len(ids))
+	for id := range ids {
+		subscriptionIDs = append(subscriptionIDs, id)
+	}
+	var subscriptions []utils.WebhookSubscription
+	if err := utils.DB.Where("id IN ?", subscriptionIDs).Find(&subscriptions).Error; err != nil {
+		return 0, err
+	}
+	byID := make(map[uint]*utils.WebhookSubscription, len(subscriptions))
+	for i := range subscriptions {
+		byID[subscriptions[i].ID] = &subscriptions[i]
+	}
+
+	jobs := make(chan *utils.WebhookDelivery)
+	var wg sync.WaitGroup
+	for i := 0; i < d.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for delivery := range jobs {
+				if err := d.Deliver(ctx, delivery, byID[delivery.SubscriptionID]); err != nil {
+					log.Printf("Failed to record webhook delivery %d: %v", delivery.ID, err)
+				}
+			}
+		}()
+	}
+	for i := range deliveries {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- &deliveries[i]
+	}
+	close(jobs)
+	wg.Wait()
+
+	return len(deliveries), nil
+}
+
+// Deliver POSTs a delivery's payload to its subscription and records the
+// outcome. Deliveries to deleted or inactive subscriptions are dead at once,
+// as are those the receiver answers 410 Gone.
+func (d *WebhookDispatcher) Deliver(ctx context.Context, delivery *utils.WebhookDelivery, subscription *utils.WebhookSubscription) error {
+	d.attempt(ctx, delivery, subscription)
+	return utils.DB.Model(delivery).Select("status", "attempts", "next_attempt_at", "last_status_code", "last_error", "delivered_at").
+		Updates(delivery).Error
+}
+
+// attempt makes one delivery attempt and sets the delivery's status, attempt
+// count, next attempt and last error from the outcome, without saving it
+func (d *WebhookDispatcher) attempt(ctx context.Context, delivery *utils.WebhookDelivery, subscription *utils.WebhookSubscription) {
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastStatusCode = 0
+
+	var sendErr error
+	if subscription == nil || !subscription.Active {
+		sendErr = fmt.Errorf("subscription %d is deleted or inactive", delivery.SubscriptionID)
+		delivery.Attempts = max(delivery.Attempts, d.MaxAttempts)
+	} else {
+		delivery.LastStatusCode, sendErr = d.send(ctx, delivery, subscription)
+		if delivery.LastStatusCode == http.StatusGone {
+			delivery.Attempts = max(delivery.Attempts, d.MaxAttempts)
+		}
+	}
+
+	switch {
+	case sendErr == nil:
+		delivery.Status = utils.DeliveryDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+	case delivery.Attempts >= d.MaxAttempts:
+		delivery.Status = utils.DeliveryDead
+		delivery.LastError = sendErr.Error()
+	default:
+		delivery.Status = utils.DeliveryPending
+		delivery.LastError = sendErr.Error()
+		delay := d.RetryDelay << (delivery.Attempts - 1)
+		if delay > d.MaxRetryDelay || delay <= 0 {
+			delay = d.MaxRetryDelay
+		}
+		delivery.NextAttemptAt = now.Add(delay)
+	}
+}
+
+// send makes one delivery attempt, returning the response status, if any
+func (d *WebhookDispatcher) send(ctx context.Context, delivery *utils.WebhookDelivery, subscription *utils.WebhookSubscription) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go_news_api-webhooks")
+	req.Header.Set(WebhookIDHeader, delivery.EventID)
+	req.Header.Set(WebhookEventHeader, delivery.EventType)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(subscription.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if detail := strings.TrimSpace(string(body)); detail != "" {
+			return resp.StatusCode, fmt.Errorf("receiver answered %d: %s", resp.StatusCode, truncate(detail, 200))
+		}
+		return resp.StatusCode, fmt.Errorf("receiver answered %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
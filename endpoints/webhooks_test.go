@@ -0,0 +1,150 @@
+package endpoints
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go_news_api/utils"
+)
+
+func newTestDispatcher() *WebhookDispatcher {
+	return &WebhookDispatcher{
+		MaxAttempts:   3,
+		RetryDelay:    time.Minute,
+		MaxRetryDelay: time.Hour,
+		Timeout:       5 * time.Second,
+		client:        &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func newTestDelivery() *utils.WebhookDelivery {
+	return &utils.WebhookDelivery{
+		SubscriptionID: 1,
+		EventID:        "evt-1",
+		EventType:      EventArticleCreated,
+		Payload:        []byte(`{"id":"evt-1","type":"article.created"}`),
+		Status:         utils.DeliveryPending,
+	}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	const secret = "s3cret"
+	var verified atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("read body: %v", err)
+		}
+		timestamp, err := strconv.ParseInt(r.Header.Get(WebhookTimestampHeader), 10, 64)
+		if err != nil {
+			t.Errorf("timestamp header %q: %v", r.Header.Get(WebhookTimestampHeader), err)
+		}
+		if got, want := r.Header.Get(WebhookSignatureHeader), SignWebhook(secret, timestamp, body); got != want {
+			t.Errorf("signature = %q, want %q", got, want)
+		} else {
+			verified.Store(true)
+		}
+		if r.Header.Get(WebhookIDHeader) != "evt-1" || r.Header.Get(WebhookEventHeader) != EventArticleCreated {
+			t.Errorf("event headers = %q, %q", r.Header.Get(WebhookIDHeader), r.Header.Get(WebhookEventHeader))
+		}
+	}))
+	defer server.Close()
+
+	delivery := newTestDelivery()
+	subscription := &utils.WebhookSubscription{URL: server.URL, Secret: secret, Active: true}
+	newTestDispatcher().attempt(context.Background(), delivery, subscription)
+
+	if !verified.Load() {
+		t.Error("the receiver could not verify the signature")
+	}
+	if delivery.Status != utils.DeliveryDelivered || delivery.DeliveredAt == nil {
+		t.Errorf("status = %q, delivered at %v", delivery.Status, delivery.DeliveredAt)
+	}
+	if SignWebhook(secret, 1, delivery.Payload) == SignWebhook("other", 1, delivery.Payload) {
+		t.Error("the signature does not depend on the secret")
+	}
+	if SignWebhook(secret, 1, delivery.Payload) == SignWebhook(secret, 2, delivery.Payload) {
+		t.Error("the signature does not depend on the timestamp")
+	}
+}
+
+func TestWebhookRetryAndDeadLetter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	d := newTestDispatcher()
+	delivery := newTestDelivery()
+	subscription := &utils.WebhookSubscription{URL: server.URL, Secret: "s3cret", Active: true}
+
+	var lastDelay time.Duration
+	for attempt := 1; attempt < d.MaxAttempts; attempt++ {
+		before := time.Now()
+		d.attempt(context.Background(), delivery, subscription)
+		if delivery.Status != utils.DeliveryPending {
+			t.Fatalf("attempt %d: status = %q, want pending", attempt, delivery.Status)
+		}
+		if delivery.LastStatusCode != http.StatusServiceUnavailable || delivery.LastError == "" {
+			t.Errorf("attempt %d: status code %d, error %q", attempt, delivery.LastStatusCode, delivery.LastError)
+		}
+		delay := delivery.NextAttemptAt.Sub(before)
+		if delay <= lastDelay {
+			t.Errorf("attempt %d: retry in %s, not after the previous %s", attempt, delay, lastDelay)
+		}
+		lastDelay = delay
+	}
+
+	d.attempt(context.Background(), delivery, subscription)
+	if delivery.Status != utils.DeliveryDead {
+		t.Errorf("status after %d attempts = %q, want dead", delivery.Attempts, delivery.Status)
+	}
+	if delivery.Attempts != d.MaxAttempts {
+		t.Errorf("attempts = %d, want %d", delivery.Attempts, d.MaxAttempts)
+	}
+}
+
+func TestWebhookInactiveSubscription(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("an inactive subscription was sent a request")
+	}))
+	defer server.Close()
+
+	tests := map[string]*utils.WebhookSubscription{
+		"inactive": {URL: server.URL, Secret: "s3cret", Active: false},
+		"deleted":  nil,
+	}
+	for name, subscription := range tests {
+		t.Run(name, func(t *testing.T) {
+			d := newTestDispatcher()
+			delivery := newTestDelivery()
+			d.attempt(context.Background(), delivery, subscription)
+			if delivery.Status != utils.DeliveryDead {
+				t.Errorf("status = %q, want dead", delivery.Status)
+			}
+			if delivery.Attempts < d.MaxAttempts || delivery.LastError == "" {
+				t.Errorf("attempts = %d, error %q", delivery.Attempts, delivery.LastError)
+			}
+		})
+	}
+}
+
+func TestWebhookLeaseCoversBatch(t *testing.T) {
+	d := newTestDispatcher()
+	d.BatchSize = 50
+	d.Concurrency = 4
+	// 13 rounds of 4 deliveries, the last only 2, plus a margin
+	if got, want := d.leaseDuration(), 14*d.Timeout; got != want {
+		t.Errorf("lease = %s, want %s", got, want)
+	}
+	d.Concurrency = 0
+	if got, want := d.leaseDuration(), 51*d.Timeout; got != want {
+		t.Errorf("lease without concurrency = %s, want %s", got, want)
+	}
+}
@@ -9,19 +9,17 @@ package main
 // TODO: add endpoints for fetching news by trending categories
 // labels: endpoint, feature, enhancement
 
-// TODO: add scheduling via background cronjob or something like that to continuously pull new articles
-// labels: enhancement, feature
-
 // TODO: sentiment analysis, topics and keywords extraction, and other NLP features
 // labels: feature, enhancement
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
-
-	"gorm.io/gorm"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -49,6 +47,10 @@ func main() {
 	// Initialize database connection
 	utils.InitDB()
 
+	// Configure HTTP clients for news providers and scrapers
+	endpoints.InitProviders()
+	endpoints.InitHeadlinesCache()
+
 	// Perform automatic migration
 	if err := utils.MigrateDB(); err != nil {
 		log.Fatalf("Failed to perform database migration: %v", err)
@@ -56,6 +58,40 @@ func main() {
 		log.Println("Database migration successful")
 	}
 
+	// Categorize ingested articles with the last trained classifier
+	if err := endpoints.LoadClassifier(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	// Maintenance commands such as retrain-classifier run instead of the server
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+
+	// Archive provider responses in the background
+	endpoints.StartPayloadArchive(context.Background())
+
+	// Extract full article text in the background after ingestion
+	endpoints.StartExtractionWorker(context.Background())
+
+	// Keep the related articles index in memory, following ingestion
+	endpoints.StartRelatedIndex(context.Background())
+
+	// Record which saved searches newly ingested articles match
+	endpoints.StartSavedSearchMatcher()
+
+	// Push newly ingested articles to stream clients
+	endpoints.StartArticleStream()
+
+	// Write large exports to files in the background
+	endpoints.StartExportJobs(context.Background())
+
+	// Deliver queued webhook events to their subscribers
+	endpoints.StartWebhookDispatcher(context.Background())
+
+	// Fetch headlines for the configured countries and languages on a schedule
+	endpoints.StartIngestionScheduler(context.Background())
+
 	r := gin.Default()
 
 	// Add this new route handler for the root path
@@ -74,6 +110,57 @@ func main() {
 		v1.GET("/trending-topics", getTrendingTopicsNews)
 		v1.GET("/fetch-trending-categories", fetchTrendingCategories)
 		v1.GET("/news-by-keyword", getNewsByKeyword)
+		v1.GET("/articles/changed-headlines", getChangedHeadlines)
+		v1.GET("/articles/:id", getArticle)
+		v1.GET("/articles/:id/sightings", getArticleSightings)
+		v1.GET("/articles/:id/revisions", getArticleRevisions)
+		v1.GET("/articles/:id/summary", getArticleSummary)
+		v1.GET("/articles/:id/related", getRelatedArticles)
+		v1.GET("/summaries", getStorySummary)
+		v1.GET("/sources", listSources)
+		v1.GET("/sources/:id", getSource)
+		v1.GET("/classifier", getClassifier)
+		v1.GET("/entities", listEntities)
+		v1.GET("/entities/:id", getEntity)
+		v1.GET("/entities/:id/articles", getEntityArticles)
+		v1.GET("/keywords/top", getTopKeywords)
+		v1.GET("/keywords/timeseries", compareKeywordTimeSeries)
+		v1.GET("/keywords/:word/timeseries", getKeywordTimeSeries)
+		v1.GET("/graph/keywords", getKeywordGraph)
+		v1.GET("/stream/articles", streamArticles)
+		v1.GET("/exports/articles", exportArticles)
+		v1.GET("/feeds/:feed", getFeed)
+		v1.GET("/feeds/keyword/:word", getKeywordFeed)
+		v1.GET("/feeds/source/:id", getSourceFeed)
+
+		admin := v1.Group("/admin", endpoints.RequireAdmin())
+		{
+			admin.POST("/articles/:id/enrich", enrichArticle)
+			admin.POST("/sources/sync", syncSources)
+			admin.POST("/sources/:id/merge", mergeSources)
+			admin.POST("/classifier/retrain", retrainClassifier)
+			admin.GET("/webhooks", listWebhooks)
+			admin.POST("/webhooks", createWebhook)
+			admin.GET("/webhooks/:id", getWebhook)
+			admin.PUT("/webhooks/:id", updateWebhook)
+			admin.DELETE("/webhooks/:id", deleteWebhook)
+			admin.GET("/webhooks/:id/deliveries", listWebhookDeliveries)
+			admin.POST("/webhooks/deliveries/:id/retry", retryWebhookDelivery)
+			admin.GET("/saved-searches", listSavedSearches)
+			admin.POST("/saved-searches", createSavedSearch)
+			admin.GET("/saved-searches/:id", getSavedSearch)
+			admin.PUT("/saved-searches/:id", updateSavedSearch)
+			admin.DELETE("/saved-searches/:id", deleteSavedSearch)
+			admin.GET("/saved-searches/:id/matches", getSavedSearchMatches)
+			admin.POST("/saved-searches/:id/read", markSavedSearchRead)
+			admin.GET("/imports", listImportRuns)
+			admin.POST("/imports", importArticles)
+			admin.GET("/imports/:id", getImportRun)
+			admin.GET("/exports/:id", getExportJob)
+			admin.GET("/exports/:id/download", downloadExportJob)
+			admin.GET("/payloads", listPayloads)
+			admin.GET("/payloads/:id/body", getPayloadBody)
+		}
 	}
 
 	// Modify the Swagger documentation route
@@ -98,13 +185,25 @@ func main() {
 }
 
 // @Summary Health check
-// @Description Check if the API is up and running
+// @Description Check if the API is up and running, including circuit breaker and quota state per news provider
 // @Produce json
-// @Success 200 {object} map[string]string
+// @Success 200 {object} map[string]interface{}
 // @Router /health [get]
 func healthCheck(c *gin.Context) {
+	providers := endpoints.ProviderStatuses()
+
+	// Report degraded when no provider can currently serve requests
+	status := "DEGRADED"
+	for _, p := range providers {
+		if p.Circuit.State != endpoints.CircuitOpen && p.QuotaRemaining > 0 {
+			status = "UP"
+			break
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"status": "UP",
+		"status":    status,
+		"providers": providers,
 	})
 }
 
@@ -148,7 +247,7 @@ func testPostgreSQL(c *gin.Context) {
 // @Router /init-db [post]
 func initializeDatabase(c *gin.Context) {
 	// Drop existing tables
-	err := utils.DB.Migrator().DropTable(&utils.APIResponse{}, &utils.Article{}, &utils.Source{}, &utils.Keyword{}, &utils.SearchQuery{}, &utils.TrendingTopic{})
+	err := utils.DB.Migrator().DropTable(&utils.APIResponse{}, &utils.Article{}, &utils.Source{}, &utils.SourceAlias{}, &utils.Keyword{}, &utils.SearchQuery{}, &utils.TrendingTopic{}, &utils.CacheEntry{}, &utils.ArticleContent{}, &utils.ArticleEnrichment{}, &utils.ArticleSighting{}, &utils.ArticleRevision{}, &utils.ClassifierModel{}, &utils.Entity{}, &utils.ArticleEntity{}, &utils.ArticleVector{}, &utils.KeywordRollup{}, &utils.KeywordSourceRollup{}, &utils.SavedSearch{}, &utils.SavedSearchMatch{}, &utils.WebhookSubscription{}, &utils.WebhookDelivery{}, &utils.ExportJob{}, &utils.ImportRun{}, &utils.RawPayload{})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"status":  "error",
@@ -159,7 +258,7 @@ func initializeDatabase(c *gin.Context) {
 	}
 
 	// Create new tables
-	err = utils.DB.AutoMigrate(&utils.APIResponse{}, &utils.Article{}, &utils.Source{}, &utils.Keyword{}, &utils.SearchQuery{}, &utils.TrendingTopic{})
+	err = utils.DB.AutoMigrate(&utils.APIResponse{}, &utils.Article{}, &utils.Source{}, &utils.SourceAlias{}, &utils.Keyword{}, &utils.SearchQuery{}, &utils.TrendingTopic{}, &utils.CacheEntry{}, &utils.ArticleContent{}, &utils.ArticleEnrichment{}, &utils.ArticleSighting{}, &utils.ArticleRevision{}, &utils.ClassifierModel{}, &utils.Entity{}, &utils.ArticleEntity{}, &utils.ArticleVector{}, &utils.KeywordRollup{}, &utils.KeywordSourceRollup{}, &utils.SavedSearch{}, &utils.SavedSearchMatch{}, &utils.WebhookSubscription{}, &utils.WebhookDelivery{}, &utils.ExportJob{}, &utils.ImportRun{}, &utils.RawPayload{})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"status":  "error",
@@ -182,7 +281,7 @@ func initializeDatabase(c *gin.Context) {
 // @Failure 500 {object} map[string]string
 // @Router /migrate [get]
 func migrateDatabase(c *gin.Context) {
-	err := utils.DB.AutoMigrate(&utils.APIResponse{}, &utils.Article{}, &utils.Source{}, &utils.Keyword{}, &utils.SearchQuery{}, &utils.TrendingTopic{})
+	err := utils.DB.AutoMigrate(&utils.APIResponse{}, &utils.Article{}, &utils.Source{}, &utils.SourceAlias{}, &utils.Keyword{}, &utils.SearchQuery{}, &utils.TrendingTopic{}, &utils.CacheEntry{}, &utils.ArticleContent{}, &utils.ArticleEnrichment{}, &utils.ArticleSighting{}, &utils.ArticleRevision{}, &utils.ClassifierModel{}, &utils.Entity{}, &utils.ArticleEntity{}, &utils.ArticleVector{}, &utils.KeywordRollup{}, &utils.KeywordSourceRollup{}, &utils.SavedSearch{}, &utils.SavedSearchMatch{}, &utils.WebhookSubscription{}, &utils.WebhookDelivery{}, &utils.ExportJob{}, &utils.ImportRun{}, &utils.RawPayload{})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"status":  "error",
@@ -199,93 +298,128 @@ func migrateDatabase(c *gin.Context) {
 }
 
 // @Summary Get top headlines
-// @Description Get top headlines from News API and GNews
+// @Description Get top headlines from News API and GNews. With source=auto providers are tried in priority order, skipping open circuits and exhausted quotas; the X-News-Provider header and api_source field name the provider that served the response.
 // @Produce json
-// @Param source query string false "Source of news (newsapi or gnews)"
-// @Param country query string false "Country code for headlines"
+// @Param source query string false "Source of news (newsapi, gnews or auto)"
+// @Param country query string false "ISO 3166-1 country code (default us); News API and GNews support different lists"
 // @Param category query string false "Category of news"
+// @Param language query string false "ISO 639-1 language code (GNews only; default en)"
+// @Param refresh query bool false "Bypass the cache (admin only)"
+// @Param from query string false "Only articles published at or after this date (RFC3339 or YYYY-MM-DD)"
+// @Param to query string false "Only articles published at or before this date (RFC3339 or YYYY-MM-DD)"
+// @Param sort query string false "relevance (provider order) or published_at"
+// @Param order query string false "asc or desc (default desc)"
 // @Success 200 {object} utils.SwaggerAPIResponse
+// @Header 200 {string} X-Cache "HIT, MISS or STALE"
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Failure 502 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Failure 504 {object} map[string]string
 // @Router /top-headlines [get]
 func getTopHeadlines(c *gin.Context) {
 	source := c.DefaultQuery("source", "newsapi")
-	country := c.DefaultQuery("country", "us")
+	country := strings.ToLower(c.DefaultQuery("country", "us"))
 	category := c.DefaultQuery("category", "general")
+	language := strings.ToLower(c.Query("language"))
 
-	var apiResponse *utils.APIResponse
-	var err error
+	// Bypassing the cache costs upstream quota, so only admins may do it
+	refresh := c.Query("refresh") == "true"
+	if refresh && !endpoints.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "refresh requires an admin token"})
+		return
+	}
 
-	switch source {
-	case "newsapi":
-		apiResponse, err = endpoints.GetNewsAPITopHeadlinesByCategory(country, category)
-		// Handle error
+	filter, err := endpoints.ParseArticleFilter(c.Request.URL.Query())
+	if err != nil {
+		endpoints.RespondError(c, err)
+		return
+	}
+	// Here category picks the provider feed rather than filtering it
+	filter.Category = ""
+
+	key := endpoints.HeadlinesKey{Provider: source, Country: country, Category: category, Language: language}
+	cached, cacheStatus, err := endpoints.GetCachedTopHeadlines(c.Request.Context(), key, refresh, func(ctx context.Context) (*utils.APIResponse, error) {
+		apiResponse, err := endpoints.FetchTopHeadlines(ctx, source, country, category, language)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+			return nil, err
 		}
-	case "gnews":
-		apiResponse, err = endpoints.GetGNewsTopHeadlines(country, category)
-		// Handle error
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+		if err := endpoints.SaveTopHeadlines(apiResponse); err != nil {
+			return nil, err
 		}
-	default:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid source"})
+		return apiResponse, nil
+	})
+	if err != nil {
+		endpoints.RespondError(c, err)
 		return
 	}
 
-	// Save the API response to the database
-	var existingResponse utils.APIResponse
-	result := utils.DB.Where("api_source = ?", apiResponse.APISource).First(&existingResponse)
-	if result.Error == nil {
-		// Update existing record
-		existingResponse.Status = apiResponse.Status
-		existingResponse.TotalResults = apiResponse.TotalResults
-		existingResponse.TotalArticles = apiResponse.TotalArticles
-		existingResponse.Articles = apiResponse.Articles
-		err = utils.DB.Save(&existingResponse).Error
-	} else if result.Error == gorm.ErrRecordNotFound {
-		// Create new record
-		err = utils.DB.Create(apiResponse).Error
-	} else {
-		err = result.Error
+	c.Header("X-Cache", cacheStatus)
+	c.Header("X-News-Provider", cached.ServedBy)
+	if filter.IsZero() {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", cached.Body)
+		return
 	}
 
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// The cache holds the unfiltered response, so filtering works on a copy
+	var apiResponse utils.APIResponse
+	if err := json.Unmarshal(cached.Body, &apiResponse); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to decode cached headlines: %v", err)})
 		return
 	}
-
+	apiResponse.Articles = filter.FilterArticles(apiResponse.Articles)
+	apiResponse.TotalArticles = len(apiResponse.Articles)
 	c.JSON(http.StatusOK, apiResponse)
 }
 
 // @Summary Get trending topics news
-// @Description Get news articles for trending topics from News API and GNews
+// @Description Get news articles for trending topics from News API and GNews. Supports source=auto failover like /top-headlines.
 // @Produce json
-// @Param source query string false "Source of news (newsapi or gnews)"
+// @Param source query string false "Source of news (newsapi, gnews or auto)"
 // @Param topics query int false "Number of random topics to pick (1-10, default 1)"
+// @Param country query string false "ISO 3166-1 country code (GNews only; default us)"
+// @Param language query string false "ISO 639-1 language code (default en)"
+// @Param from query string false "Only articles published at or after this date (RFC3339 or YYYY-MM-DD)"
+// @Param to query string false "Only articles published at or before this date (RFC3339 or YYYY-MM-DD)"
+// @Param sort query string false "relevance (provider order) or published_at"
+// @Param category query string false "Only articles in this category (business, entertainment, general, health, science, sports, technology)"
+// @Param order query string false "asc or desc (default desc)"
 // @Success 200 {object} utils.SwaggerAPIResponse
 // @Failure 400 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Failure 502 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Failure 504 {object} map[string]string
 // @Router /trending-topics [get]
 func getTrendingTopicsNews(c *gin.Context) {
 	source := c.DefaultQuery("source", "newsapi")
+	country := strings.ToLower(c.Query("country"))
+	language := strings.ToLower(c.Query("language"))
 	topicsCount := endpoints.GetTopicsCount(c)
 
+	filter, err := endpoints.ParseArticleFilter(c.Request.URL.Query())
+	if err != nil {
+		endpoints.RespondError(c, err)
+		return
+	}
+
 	tx := utils.DB.Begin()
 	defer endpoints.HandleTransactionError(tx, c)
 
-	selectedTopics, err := endpoints.GetSelectedTopics(tx, topicsCount)
+	selectedTopics, err := endpoints.GetSelectedTopics(c.Request.Context(), tx, topicsCount)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		tx.Rollback()
+		endpoints.RespondError(c, err)
 		return
 	}
 
-	apiResponse, err := endpoints.GetOrFetchAPIResponse(tx, source, selectedTopics)
+	apiResponse, ingested, err := endpoints.GetOrFetchAPIResponse(c.Request.Context(), tx, source, country, language, selectedTopics)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		tx.Rollback()
+		endpoints.RespondError(c, err)
 		return
 	}
 
@@ -294,6 +428,14 @@ func getTrendingTopicsNews(c *gin.Context) {
 		return
 	}
 
+	endpoints.PublishIngestedArticles(apiResponse.APISource, ingested)
+
+	if !filter.IsZero() {
+		apiResponse.Articles = filter.FilterArticles(apiResponse.Articles)
+		apiResponse.TotalArticles = len(apiResponse.Articles)
+	}
+
+	c.Header("X-News-Provider", apiResponse.APISource)
 	c.JSON(http.StatusOK, apiResponse)
 }
 
@@ -301,12 +443,15 @@ func getTrendingTopicsNews(c *gin.Context) {
 // @Description Fetch top 10 trending categories from Exploding Topics
 // @Produce json
 // @Success 200 {array} utils.TrendingTopic
+// @Failure 429 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Failure 502 {object} map[string]string
+// @Failure 504 {object} map[string]string
 // @Router /fetch-trending-categories [get]
 func fetchTrendingCategories(c *gin.Context) {
-	trendingTopics, err := endpoints.FetchTrendingTopics()
+	trendingTopics, err := endpoints.FetchTrendingTopics(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		endpoints.RespondError(c, err)
 		return
 	}
 
@@ -329,11 +474,18 @@ func fetchTrendingCategories(c *gin.Context) {
 		}
 	}
 
+	if err := endpoints.EnqueueTrendingUpdated(tx, trendingTopics); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Commit the transaction
 	if err := tx.Commit().Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
 		return
 	}
+	endpoints.WakeWebhookDispatcher()
 
 	c.JSON(http.StatusOK, trendingTopics)
 }
@@ -343,6 +495,12 @@ func fetchTrendingCategories(c *gin.Context) {
 // @Produce json
 // @Param source query string false "Source of news (newsapi or gnews)"
 // @Param keyword query string true "Keyword to search for"
+// @Param lang query string false "ISO 639-1 language; only articles in it are searched, using its stemming"
+// @Param from query string false "Only articles published at or after this date (RFC3339 or YYYY-MM-DD)"
+// @Param to query string false "Only articles published at or before this date (RFC3339 or YYYY-MM-DD)"
+// @Param sort query string false "relevance (default) or published_at"
+// @Param category query string false "Only articles in this category (business, entertainment, general, health, science, sports, technology)"
+// @Param order query string false "asc or desc (default desc)"
 // @Success 200 {object} utils.SwaggerAPIResponse
 // @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
@@ -350,3 +508,763 @@ func fetchTrendingCategories(c *gin.Context) {
 func getNewsByKeyword(c *gin.Context) {
 	endpoints.GetNewsByKeyword(c)
 }
+
+// @Summary Get article
+// @Description Get a stored article with its source, extracted full text, metadata enrichment and first/last seen timestamps
+// @Produce json
+// @Param id path int true "Article ID"
+// @Success 200 {object} utils.Article
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /articles/{id} [get]
+func getArticle(c *gin.Context) {
+	endpoints.GetArticle(c)
+}
+
+// @Summary Get article sightings
+// @Description List every provider fetch an article appeared in (provider, query or category, rank and time), newest first, plus a summary of the queries that surfaced it
+// @Produce json
+// @Param id path int true "Article ID"
+// @Param page query int false "Page number (default 1)"
+// @Param per_page query int false "Sightings per page (1-100, default 20)"
+// @Success 200 {object} utils.ArticleSightingsResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /articles/{id}/sightings [get]
+func getArticleSightings(c *gin.Context) {
+	endpoints.GetArticleSightings(c)
+}
+
+// @Summary Get article revisions
+// @Description List the changes to an article's title, description, content, author or image seen across re-fetches, newest first
+// @Produce json
+// @Param id path int true "Article ID"
+// @Param page query int false "Page number (default 1)"
+// @Param per_page query int false "Revisions per page (1-100, default 20)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /articles/{id}/revisions [get]
+func getArticleRevisions(c *gin.Context) {
+	endpoints.GetArticleRevisions(c)
+}
+
+// @Summary Get changed headlines
+// @Description Feed of articles whose headline changed between fetches, newest first
+// @Produce json
+// @Param window query string false "How far back to look, as a Go duration (default 24h)"
+// @Param page query int false "Page number (default 1)"
+// @Param per_page query int false "Entries per page (1-100, default 20)"
+// @Success 200 {array} utils.HeadlineChange
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /articles/changed-headlines [get]
+func getChangedHeadlines(c *gin.Context) {
+	endpoints.GetChangedHeadlines(c)
+}
+
+// @Summary Enrich article metadata
+// @Description Re-fetch an article's page and fill missing image, author, description and publish date from its Open Graph, Twitter Card and JSON-LD metadata (admin only)
+// @Produce json
+// @Param id path int true "Article ID"
+// @Param Authorization header string true "Bearer admin token"
+// @Success 200 {object} utils.Article
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Failure 502 {object} map[string]string
+// @Router /admin/articles/{id}/enrich [post]
+func enrichArticle(c *gin.Context) {
+	endpoints.EnrichArticleByID(c)
+}
+
+// @Summary List sources
+// @Description List and search the source catalogue. q matches source names, provider aliases and domains.
+// @Produce json
+// @Param q query string false "Search text"
+// @Param country query string false "Country code"
+// @Param language query string false "Language code"
+// @Param category query string false "Category"
+// @Param domain query string false "Registrable domain, e.g. bbc.co.uk"
+// @Param page query int false "Page number (default 1)"
+// @Param per_page query int false "Sources per page (1-100, default 20)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 500 {object} map[string]string
+// @Router /sources [get]
+func listSources(c *gin.Context) {
+	endpoints.ListSources(c)
+}
+
+// @Summary Get source
+// @Description Get a source from the catalogue with the names and ids providers use for it
+// @Produce json
+// @Param id path int true "Source ID"
+// @Success 200 {object} utils.Source
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /sources/{id} [get]
+func getSource(c *gin.Context) {
+	endpoints.GetSource(c)
+}
+
+// @Summary Merge sources
+// @Description Merge duplicate sources into this one, moving their articles and aliases (admin only)
+// @Accept json
+// @Produce json
+// @Param id path int true "Source ID to keep"
+// @Param Authorization header string true "Bearer admin token"
+// @Param request body endpoints.MergeSourcesRequest true "Sources to merge"
+// @Success 200 {object} utils.Source
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/sources/{id}/merge [post]
+func mergeSources(c *gin.Context) {
+	endpoints.MergeSourcesHandler(c)
+}
+
+// @Summary Sync sources from News API
+// @Description Seed the source catalogue with News API's source list (description, country, language, category, homepage) (admin only)
+// @Produce json
+// @Param Authorization header string true "Bearer admin token"
+// @Success 200 {object} map[string]int
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 502 {object} map[string]string
+// @Failure 504 {object} map[string]string
+// @Router /admin/sources/sync [post]
+func syncSources(c *gin.Context) {
+	endpoints.SyncSourcesHandler(c)
+}
+
+// @Summary Get the category classifier
+// @Description Evaluation metrics (accuracy, macro F1, per-category precision/recall/F1 and confusion matrix) of the classifier that assigns categories to articles outside top-headlines fetches
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /classifier [get]
+func getClassifier(c *gin.Context) {
+	endpoints.GetClassifier(c)
+}
+
+// @Summary Retrain the category classifier
+// @Description Train a new Naive Bayes classifier on articles whose category is known from top-headlines fetches, report held-out metrics and reclassify all other articles (admin only)
+// @Produce json
+// @Param Authorization header string true "Bearer admin token"
+// @Success 200 {object} endpoints.RetrainResult
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/classifier/retrain [post]
+func retrainClassifier(c *gin.Context) {
+	endpoints.RetrainClassifierHandler(c)
+}
+
+// @Summary Stream new articles
+// @Description Server-Sent Events stream of articles as ingestion creates them, one "article" event each. Reconnecting with Last-Event-ID replays missed events still in the buffer; a comment is sent as heartbeat, and an "overflow" event before clients that fall behind are disconnected
+// @Produce text/event-stream
+// @Param keyword query string false "Only articles with this in the title or description"
+// @Param source query string false "Only articles from this domain, e.g. bbc.co.uk"
+// @Param provider query string false "Only articles fetched from this provider (newsapi or gnews)"
+// @Param lang query string false "Only articles in this ISO 639-1 language"
+// @Param Last-Event-ID header string false "Id of the last event received"
+// @Param last_event_id query string false "Id of the last event received, for clients that cannot set headers"
+// @Success 200 {string} string "text/event-stream"
+// @Failure 400 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Router /stream/articles [get]
+func streamArticles(c *gin.Context) {
+	endpoints.StreamArticles(c)
+}
+
+// @Summary Latest or search feed
+// @Description The newest stored articles as RSS 2.0, Atom 1.0 or JSON Feed 1.1: latest.{rss,atom,json} (optionally narrowed by category, lang, from and to), or search.{rss,atom,json} for the articles matching q as /news-by-keyword finds them, newest first. Supports conditional GET with ETag and Last-Modified
+// @Produce application/rss+xml
+// @Produce application/atom+xml
+// @Produce application/feed+json
+// @Param feed path string true "latest.rss, latest.atom, latest.json, search.rss, search.atom or search.json"
+// @Param q query string false "Search terms (search feed only)"
+// @Param lang query string false "ISO 639-1 language"
+// @Param category query string false "Category"
+// @Param from query string false "Published at or after"
+// @Param to query string false "Published at or before; a date without a time includes that day"
+// @Param sort query string false "published_at to order by publish date alone; newest first by default"
+// @Param order query string false "asc or desc (default desc) for sort=published_at"
+// @Param limit query int false "Number of items (1-100, default 50)"
+// @Param If-None-Match header string false "ETag of a cached copy"
+// @Param If-Modified-Since header string false "Last-Modified of a cached copy"
+// @Success 200 {string} string "Feed document"
+// @Success 304 {string} string "Not modified"
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /feeds/{feed} [get]
+func getFeed(c *gin.Context) {
+	endpoints.GetFeed(c)
+}
+
+// @Summary Keyword feed
+// @Description The newest articles mentioning a keyword as RSS 2.0, Atom 1.0 or JSON Feed 1.1, chosen by the extension, e.g. /feeds/keyword/climate.atom. Supports conditional GET with ETag and Last-Modified
+// @Produce application/rss+xml
+// @Produce application/atom+xml
+// @Produce application/feed+json
+// @Param word path string true "Keyword with a .rss, .atom or .json extension"
+// @Param limit query int false "Number of items (1-100, default 50)"
+// @Param If-None-Match header string false "ETag of a cached copy"
+// @Param If-Modified-Since header string false "Last-Modified of a cached copy"
+// @Success 200 {string} string "Feed document"
+// @Success 304 {string} string "Not modified"
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /feeds/keyword/{word} [get]
+func getKeywordFeed(c *gin.Context) {
+	endpoints.GetKeywordFeed(c)
+}
+
+// @Summary Source feed
+// @Description The newest articles of a source as RSS 2.0, Atom 1.0 or JSON Feed 1.1, chosen by the extension, e.g. /feeds/source/12.json. Supports conditional GET with ETag and Last-Modified
+// @Produce application/rss+xml
+// @Produce application/atom+xml
+// @Produce application/feed+json
+// @Param id path string true "Source ID with a .rss, .atom or .json extension"
+// @Param limit query int false "Number of items (1-100, default 50)"
+// @Param If-None-Match header string false "ETag of a cached copy"
+// @Param If-Modified-Since header string false "Last-Modified of a cached copy"
+// @Success 200 {string} string "Feed document"
+// @Success 304 {string} string "Not modified"
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /feeds/source/{id} [get]
+func getSourceFeed(c *gin.Context) {
+	endpoints.GetSourceFeed(c)
+}
+
+// @Summary Export articles
+// @Description Export the articles matching the filters, with their source, keywords and provenance, as NDJSON, CSV or Parquet. Up to EXPORT_SYNC_LIMIT articles are streamed; larger exports, or async=true, return 202 with a job to poll. Background jobs need the admin token and are refused with 429 while EXPORT_MAX_PENDING_JOBS are queued or running
+// @Produce application/x-ndjson
+// @Produce text/csv
+// @Produce application/vnd.apache.parquet
+// @Produce json
+// @Param format query string false "ndjson (default), csv or parquet"
+// @Param q query string false "Full-text search, as /news-by-keyword"
+// @Param lang query string false "ISO 639-1 language"
+// @Param category query string false "Category"
+// @Param from query string false "Published at or after (RFC3339 or YYYY-MM-DD)"
+// @Param to query string false "Published at or before (RFC3339 or YYYY-MM-DD)"
+// @Param source query string false "Source domain, e.g. bbc.co.uk"
+// @Param keyword query string false "Only articles linked to this keyword"
+// @Param provider query string false "Only articles seen from this provider (newsapi or gnews)"
+// @Param full_text query bool false "Include the extracted full text"
+// @Param async query bool false "Always run as a background job"
+// @Success 200 {string} string "Export file"
+// @Success 202 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Router /exports/articles [get]
+func exportArticles(c *gin.Context) {
+	endpoints.ExportArticles(c)
+}
+
+// @Summary Get export job
+// @Description Status of a background export, with its download link once done (admin only)
+// @Produce json
+// @Param id path int true "Export job ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/exports/{id} [get]
+func getExportJob(c *gin.Context) {
+	endpoints.GetExportJob(c)
+}
+
+// @Summary Download export
+// @Description Download the file of a finished background export (admin only)
+// @Produce application/x-ndjson
+// @Produce text/csv
+// @Produce application/vnd.apache.parquet
+// @Param id path int true "Export job ID"
+// @Success 200 {file} file "Export file"
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Router /admin/exports/{id}/download [get]
+func downloadExportJob(c *gin.Context) {
+	endpoints.DownloadExportJob(c)
+}
+
+// @Summary List webhooks
+// @Description List the webhook subscriptions, without their secrets (admin only)
+// @Produce json
+// @Param Authorization header string true "Bearer admin token"
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/webhooks [get]
+func listWebhooks(c *gin.Context) {
+	endpoints.ListWebhooks(c)
+}
+
+// @Summary Create webhook
+// @Description Subscribe a URL to article.created and/or trending.updated events, optionally only those matching a keyword or source. Deliveries are signed with HMAC-SHA256 of the secret, which is generated when not given and only returned here (admin only)
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer admin token"
+// @Param request body endpoints.WebhookRequest true "Subscription"
+// @Success 201 {object} utils.WebhookSubscription
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/webhooks [post]
+func createWebhook(c *gin.Context) {
+	endpoints.CreateWebhook(c)
+}
+
+// @Summary Get webhook
+// @Description Get a webhook subscription with its pending, delivered and dead delivery counts (admin only)
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param Authorization header string true "Bearer admin token"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/webhooks/{id} [get]
+func getWebhook(c *gin.Context) {
+	endpoints.GetWebhook(c)
+}
+
+// @Summary Update webhook
+// @Description Replace a webhook subscription's URL, events and filters; a blank secret keeps the current one (admin only)
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param Authorization header string true "Bearer admin token"
+// @Param request body endpoints.WebhookRequest true "Subscription"
+// @Success 200 {object} utils.WebhookSubscription
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/webhooks/{id} [put]
+func updateWebhook(c *gin.Context) {
+	endpoints.UpdateWebhook(c)
+}
+
+// @Summary Delete webhook
+// @Description Delete a webhook subscription, dead-lettering its pending deliveries (admin only)
+// @Param id path int true "Webhook ID"
+// @Param Authorization header string true "Bearer admin token"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/webhooks/{id} [delete]
+func deleteWebhook(c *gin.Context) {
+	endpoints.DeleteWebhook(c)
+}
+
+// @Summary List webhook deliveries
+// @Description The delivery log of a webhook subscription, newest first, with attempts, last status code and error (admin only)
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param Authorization header string true "Bearer admin token"
+// @Param status query string false "pending, delivered or dead"
+// @Param page query int false "Page number (default 1)"
+// @Param per_page query int false "Deliveries per page (1-100, default 20)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/webhooks/{id}/deliveries [get]
+func listWebhookDeliveries(c *gin.Context) {
+	endpoints.ListWebhookDeliveries(c)
+}
+
+// @Summary Retry webhook delivery
+// @Description Queue a dead-lettered delivery again with a fresh set of attempts (admin only)
+// @Produce json
+// @Param id path int true "Delivery ID"
+// @Param Authorization header string true "Bearer admin token"
+// @Success 202 {object} utils.WebhookDelivery
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/webhooks/deliveries/{id}/retry [post]
+func retryWebhookDelivery(c *gin.Context) {
+	endpoints.RetryWebhookDelivery(c)
+}
+
+// @Summary List entities
+// @Description List people, organizations, places and other named entities extracted from articles, most mentioned first
+// @Produce json
+// @Param q query string false "Search entity names"
+// @Param type query string false "person, organization, place or other"
+// @Param page query int false "Page number (default 1)"
+// @Param per_page query int false "Results per page (1-100, default 20)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /entities [get]
+func listEntities(c *gin.Context) {
+	endpoints.ListEntities(c)
+}
+
+// @Summary Get an entity
+// @Description Get an entity with its article and mention counts and the entities most often mentioned in the same articles
+// @Produce json
+// @Param id path int true "Entity ID"
+// @Param limit query int false "Number of co-mentioned entities (1-100, default 20)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /entities/{id} [get]
+func getEntity(c *gin.Context) {
+	endpoints.GetEntity(c)
+}
+
+// @Summary Get an entity's articles
+// @Description Timeline of the articles mentioning an entity: articles newest first and the number published per day
+// @Produce json
+// @Param id path int true "Entity ID"
+// @Param from query string false "Only articles published at or after this date (RFC3339 or YYYY-MM-DD)"
+// @Param to query string false "Only articles published at or before this date (RFC3339 or YYYY-MM-DD)"
+// @Param category query string false "Only articles in this category"
+// @Param sort query string false "relevance (newest first) or published_at"
+// @Param order query string false "asc or desc (default desc)"
+// @Param page query int false "Page number (default 1)"
+// @Param per_page query int false "Results per page (1-100, default 20)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /entities/{id}/articles [get]
+func getEntityArticles(c *gin.Context) {
+	endpoints.GetEntityArticles(c)
+}
+
+// @Summary Get an article's summary
+// @Description Extractive (TextRank) summary of an article's extracted full text, or of the provider's snippet when the page has not been extracted. The stored summary is returned for the default length.
+// @Produce json
+// @Param id path int true "Article ID"
+// @Param sentences query int false "Number of sentences (1-20, default SUMMARY_SENTENCES or 3)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /articles/{id}/summary [get]
+func getArticleSummary(c *gin.Context) {
+	endpoints.GetArticleSummary(c)
+}
+
+// @Summary Summarize several articles
+// @Description Multi-document extractive summary of the given articles or of those matching a keyword. Sentences that repeat a better one from another article are left out.
+// @Produce json
+// @Param ids query string false "Comma-separated article ids (at most 30)"
+// @Param keyword query string false "Summarize the articles matching this keyword instead"
+// @Param lang query string false "ISO 639-1 language for keyword search"
+// @Param limit query int false "Number of keyword matches to summarize (1-30, default 20)"
+// @Param from query string false "Only keyword matches published at or after this date (RFC3339 or YYYY-MM-DD)"
+// @Param to query string false "Only keyword matches published at or before this date (RFC3339 or YYYY-MM-DD)"
+// @Param category query string false "Only keyword matches in this category"
+// @Param sentences query int false "Number of sentences (1-20, default 5)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /summaries [get]
+func getStorySummary(c *gin.Context) {
+	endpoints.GetStorySummary(c)
+}
+
+// @Summary Get related articles
+// @Description Articles most similar to an article by the cosine similarity of their TF-IDF vectors, best first. Copies of the article under the same URL are left out.
+// @Produce json
+// @Param id path int true "Article ID"
+// @Param limit query int false "Number of related articles (1-100, default 10)"
+// @Param window query string false "Only articles published this close to the article, e.g. 24h, 7d or 2w"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Router /articles/{id}/related [get]
+func getRelatedArticles(c *gin.Context) {
+	endpoints.GetRelatedArticles(c)
+}
+
+// @Summary Get a keyword's time series
+// @Description Number of articles whose title or description mentions a keyword, per hour, day or week (UTC) of publication. Weeks start on Monday.
+// @Produce json
+// @Param word path string true "Keyword"
+// @Param interval query string false "Bucket size: hour, day (default) or week"
+// @Param from query string false "Start (RFC3339 or YYYY-MM-DD); defaults to 48 hours, 30 days or 26 weeks before to"
+// @Param to query string false "End (RFC3339 or YYYY-MM-DD); defaults to now"
+// @Param sources query bool false "Also count the distinct sources per bucket"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /keywords/{word}/timeseries [get]
+func getKeywordTimeSeries(c *gin.Context) {
+	endpoints.GetKeywordTimeSeries(c)
+}
+
+// @Summary Compare keyword time series
+// @Description Time series of several keywords over the same buckets
+// @Produce json
+// @Param words query string true "Comma-separated keywords (at most 10)"
+// @Param interval query string false "Bucket size: hour, day (default) or week"
+// @Param from query string false "Start (RFC3339 or YYYY-MM-DD); defaults to 48 hours, 30 days or 26 weeks before to"
+// @Param to query string false "End (RFC3339 or YYYY-MM-DD); defaults to now"
+// @Param sources query bool false "Also count the distinct sources per bucket"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /keywords/timeseries [get]
+func compareKeywordTimeSeries(c *gin.Context) {
+	endpoints.CompareKeywordTimeSeries(c)
+}
+
+// @Summary Get top keywords
+// @Description Keywords ranked by the articles mentioning them in the last window, or by their growth over the window before
+// @Produce json
+// @Param window query string false "Window in whole hours, e.g. 6h, 24h (default) or 7d"
+// @Param by query string false "Rank by volume (default) or growth"
+// @Param limit query int false "Number of keywords (1-100, default 20)"
+// @Param min_articles query int false "Least articles in the window for growth rankings (default 3)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /keywords/top [get]
+func getTopKeywords(c *gin.Context) {
+	endpoints.GetTopKeywords(c)
+}
+
+// @Summary Get the keyword co-occurrence graph
+// @Description Keywords mentioned in the same articles, as nodes and weighted edges. With seed, the ego network of that keyword: its strongest neighbors and the edges among them. format=graphml or gexf downloads the graph for tools such as Gephi.
+// @Produce json
+// @Produce xml
+// @Param window query string false "Articles published in the last window, e.g. 24h or 7d (default)"
+// @Param from query string false "Start (RFC3339 or YYYY-MM-DD) instead of a window"
+// @Param to query string false "End (RFC3339 or YYYY-MM-DD); defaults to now"
+// @Param weight query string false "Edge weight: pmi (default), jaccard or count"
+// @Param min_support query int false "Least articles for a keyword or pair (default 3)"
+// @Param min_weight query number false "Leave out edges weighing less"
+// @Param limit query int false "Edges, or neighbors of the seed, to keep (1-2000, default 200)"
+// @Param seed query string false "Keyword whose ego network to return"
+// @Param format query string false "json (default), graphml or gexf"
+// @Success 200 {object} utils.KeywordGraph
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /graph/keywords [get]
+func getKeywordGraph(c *gin.Context) {
+	endpoints.GetKeywordGraph(c)
+}
+
+// @Summary List saved searches
+// @Description Saved searches with the number of matches since each was last read (admin only)
+// @Produce json
+// @Param owner query string false "Only the searches of this owner"
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/saved-searches [get]
+func listSavedSearches(c *gin.Context) {
+	endpoints.ListSavedSearches(c)
+}
+
+// @Summary Save a search
+// @Description Saves a keyword search. Articles ingested from now on that match it are recorded as its matches (admin only)
+// @Accept json
+// @Produce json
+// @Param search body endpoints.SavedSearchRequest true "Owner, query and optional name, lang, category and source domain"
+// @Success 201 {object} utils.SavedSearch
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/saved-searches [post]
+func createSavedSearch(c *gin.Context) {
+	endpoints.CreateSavedSearch(c)
+}
+
+// @Summary Get a saved search
+// @Description Get a saved search by ID (admin only)
+// @Produce json
+// @Param id path int true "Saved search ID"
+// @Success 200 {object} utils.SavedSearch
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/saved-searches/{id} [get]
+func getSavedSearch(c *gin.Context) {
+	endpoints.GetSavedSearch(c)
+}
+
+// @Summary Update a saved search
+// @Description Replaces the query, name and filters of a saved search. Matches recorded so far are kept (admin only)
+// @Accept json
+// @Produce json
+// @Param id path int true "Saved search ID"
+// @Param search body endpoints.SavedSearchRequest true "Owner, query and optional name, lang, category and source domain"
+// @Success 200 {object} utils.SavedSearch
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/saved-searches/{id} [put]
+func updateSavedSearch(c *gin.Context) {
+	endpoints.UpdateSavedSearch(c)
+}
+
+// @Summary Delete a saved search
+// @Description Delete a saved search and the matches recorded for it (admin only)
+// @Param id path int true "Saved search ID"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/saved-searches/{id} [delete]
+func deleteSavedSearch(c *gin.Context) {
+	endpoints.DeleteSavedSearch(c)
+}
+
+// @Summary Get a saved search's matches
+// @Description Articles matching a saved search, newest match first (admin only)
+// @Produce json
+// @Param id path int true "Saved search ID"
+// @Param since query string false "Only matches recorded after this time (RFC3339 or YYYY-MM-DD)"
+// @Param page query int false "Page number"
+// @Param per_page query int false "Matches per page (max 100)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/saved-searches/{id}/matches [get]
+func getSavedSearchMatches(c *gin.Context) {
+	endpoints.GetSavedSearchMatches(c)
+}
+
+// @Summary Mark a saved search as read
+// @Description Resets the unread count of a saved search (admin only)
+// @Produce json
+// @Param id path int true "Saved search ID"
+// @Success 200 {object} utils.SavedSearch
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/saved-searches/{id}/read [post]
+func markSavedSearchRead(c *gin.Context) {
+	endpoints.MarkSavedSearchRead(c)
+}
+
+// @Summary Import articles
+// @Description Import a file of articles through the normal save path: NDJSON of articles, raw News API or GNews responses (one after another or in a JSON array), or an RSS or Atom feed. Records are validated and normalized; invalid ones are rejected and reported. Importing the same file again resumes an interrupted run or, once done, changes nothing. dry_run=true reports what would be created, updated and rejected without saving (admin only)
+// @Accept application/x-ndjson
+// @Accept json
+// @Accept application/rss+xml
+// @Produce json
+// @Param Authorization header string true "Bearer admin token"
+// @Param format query string false "ndjson, newsapi, gnews or rss; detected when omitted"
+// @Param provider query string false "Provider recorded on the sightings; defaults to newsapi or gnews for their dumps, import or rss otherwise"
+// @Param lang query string false "ISO 639-1 language hint for language detection"
+// @Param filename query string false "Name of the file, kept on the import run"
+// @Param dry_run query bool false "Only validate and report"
+// @Param file body string true "File contents"
+// @Success 200 {object} endpoints.ImportResult
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/imports [post]
+func importArticles(c *gin.Context) {
+	endpoints.ImportArticles(c)
+}
+
+// @Summary List imports
+// @Description List bulk import runs, newest first (admin only)
+// @Produce json
+// @Param Authorization header string true "Bearer admin token"
+// @Param status query string false "running, done or failed"
+// @Param page query int false "Page number"
+// @Param per_page query int false "Results per page (max 100)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/imports [get]
+func listImportRuns(c *gin.Context) {
+	endpoints.ListImportRuns(c)
+}
+
+// @Summary Get import
+// @Description Get a bulk import run with its progress, counts and first rejections (admin only)
+// @Produce json
+// @Param Authorization header string true "Bearer admin token"
+// @Param id path int true "Import run ID"
+// @Success 200 {object} utils.ImportRun
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/imports/{id} [get]
+func getImportRun(c *gin.Context) {
+	endpoints.GetImportRun(c)
+}
+
+// @Summary List archived payloads
+// @Description List archived upstream responses with their request metadata (provider, URL without credentials, status, headers, fetch time, sizes), newest first (admin only)
+// @Produce json
+// @Param Authorization header string true "Bearer admin token"
+// @Param provider query string false "newsapi, gnews or scraper"
+// @Param endpoint query string false "Request path, e.g. /v2/top-headlines"
+// @Param status query int false "HTTP status code"
+// @Param page query int false "Page number"
+// @Param per_page query int false "Results per page (max 100)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/payloads [get]
+func listPayloads(c *gin.Context) {
+	endpoints.ListPayloads(c)
+}
+
+// @Summary Get archived payload body
+// @Description Get the body of an archived upstream response as it was received, with its original content type (admin only)
+// @Produce json
+// @Param Authorization header string true "Bearer admin token"
+// @Param id path int true "Payload ID"
+// @Success 200 {string} string "Response body"
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/payloads/{id}/body [get]
+func getPayloadBody(c *gin.Context) {
+	endpoints.GetPayloadBody(c)
+}
@@ -0,0 +1,207 @@
+package nlp
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// Categories are the News API categories articles are classified into
+var Categories = []string{"business", "entertainment", "general", "health", "science", "sports", "technology"}
+
+// IsCategory reports whether name is one of Categories
+func IsCategory(name string) bool {
+	for _, category := range Categories {
+		if category == name {
+			return true
+		}
+	}
+	return false
+}
+
+// LabeledText is a training or evaluation example
+type LabeledText struct {
+	Label string
+	Text  string
+}
+
+// NaiveBayes is a multinomial Naive Bayes text classifier with add-one
+// smoothing. It is plain data so that it can be stored as JSON.
+type NaiveBayes struct {
+	DocCounts  map[string]int            `json:"doc_counts"`
+	WordCounts map[string]map[string]int `json:"word_counts"`
+	TotalWords map[string]int            `json:"total_words"`
+	Vocabulary map[string]int            `json:"vocabulary"`
+	Documents  int                       `json:"documents"`
+}
+
+// NewNaiveBayes returns an untrained classifier
+func NewNaiveBayes() *NaiveBayes {
+	return &NaiveBayes{
+		DocCounts:  map[string]int{},
+		WordCounts: map[string]map[string]int{},
+		TotalWords: map[string]int{},
+		Vocabulary: map[string]int{},
+	}
+}
+
+// TrainNaiveBayes trains a classifier on examples
+func TrainNaiveBayes(examples []LabeledText) *NaiveBayes {
+	nb := NewNaiveBayes()
+	for _, example := range examples {
+		nb.Train(example.Label, example.Text)
+	}
+	return nb
+}
+
+// Train adds one labeled document to the model
+func (nb *NaiveBayes) Train(label, text string) {
+	nb.Documents++
+	nb.DocCounts[label]++
+	counts := nb.WordCounts[label]
+	if counts == nil {
+		counts = map[string]int{}
+		nb.WordCounts[label] = counts
+	}
+	for _, token := range classifierTokens(text) {
+		counts[token]++
+		nb.TotalWords[label]++
+		nb.Vocabulary[token]++
+	}
+}
+
+// Labels returns the labels the model has seen, sorted
+func (nb *NaiveBayes) Labels() []string {
+	labels := make([]string, 0, len(nb.DocCounts))
+	for label := range nb.DocCounts {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// Classify returns the most likely label for text and its posterior
+// probability. Text without any known word gets the most common label with
+// its prior.
+func (nb *NaiveBayes) Classify(text string) (string, float64) {
+	if nb.Documents == 0 {
+		return "", 0
+	}
+	tokens := classifierTokens(text)
+	labels := nb.Labels()
+	scores := make([]float64, len(labels))
+	vocabulary := float64(len(nb.Vocabulary) + 1)
+	best := 0
+	for i, label := range labels {
+		scores[i] = math.Log(float64(nb.DocCounts[label]) / float64(nb.Documents))
+		denominator := math.Log(float64(nb.TotalWords[label]) + vocabulary)
+		for _, token := range tokens {
+			if _, known := nb.Vocabulary[token]; !known {
+				continue
+			}
+			scores[i] += math.Log(float64(nb.WordCounts[label][token]+1)) - denominator
+		}
+		if scores[i] > scores[best] {
+			best = i
+		}
+	}
+
+	sum := 0.0
+	for _, score := range scores {
+		sum += math.Exp(score - scores[best])
+	}
+	return labels[best], 1 / sum
+}
+
+// classifierTokens returns the content words of text: lowercased, without
+// stopwords of any language and without very short words
+func classifierTokens(text string) []string {
+	var tokens []string
+	for _, word := range words(text) {
+		word = strings.Trim(word, "'")
+		if len([]rune(word)) < 3 || anyStopword[word] {
+			continue
+		}
+		tokens = append(tokens, word)
+	}
+	return tokens
+}
+
+// IsStopword reports whether word is a stopword of any known language
+func IsStopword(word string) bool {
+	return anyStopword[word]
+}
+
+var anyStopword = func() map[string]bool {
+	set := map[string]bool{}
+	for _, list := range stopwords {
+		for _, word := range list {
+			set[word] = true
+		}
+	}
+	return set
+}()
+
+// ClassMetrics are the evaluation results for one label
+type ClassMetrics struct {
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	F1        float64 `json:"f1"`
+	Support   int     `json:"support"`
+}
+
+// Metrics are the results of evaluating a classifier on held-out examples.
+// Confusion is indexed by true label, then predicted label.
+type Metrics struct {
+	TrainSize int                       `json:"train_size"`
+	TestSize  int                       `json:"test_size"`
+	Accuracy  float64                   `json:"accuracy"`
+	MacroF1   float64                   `json:"macro_f1"`
+	Classes   map[string]ClassMetrics   `json:"classes"`
+	Confusion map[string]map[string]int `json:"confusion"`
+}
+
+// Evaluate classifies every example and compares with its label
+func (nb *NaiveBayes) Evaluate(examples []LabeledText) Metrics {
+	metrics := Metrics{
+		TestSize:  len(examples),
+		Classes:   map[string]ClassMetrics{},
+		Confusion: map[string]map[string]int{},
+	}
+	predictedCounts := map[string]int{}
+	correct := 0
+	for _, example := range examples {
+		predicted, _ := nb.Classify(example.Text)
+		if metrics.Confusion[example.Label] == nil {
+			metrics.Confusion[example.Label] = map[string]int{}
+		}
+		metrics.Confusion[example.Label][predicted]++
+		predictedCounts[predicted]++
+		if predicted == example.Label {
+			correct++
+		}
+	}
+	if len(examples) == 0 {
+		return metrics
+	}
+	metrics.Accuracy = float64(correct) / float64(len(examples))
+
+	for label, row := range metrics.Confusion {
+		var class ClassMetrics
+		for _, count := range row {
+			class.Support += count
+		}
+		truePositives := float64(row[label])
+		if predictedCounts[label] > 0 {
+			class.Precision = truePositives / float64(predictedCounts[label])
+		}
+		class.Recall = truePositives / float64(class.Support)
+		if class.Precision+class.Recall > 0 {
+			class.F1 = 2 * class.Precision * class.Recall / (class.Precision + class.Recall)
+		}
+		metrics.Classes[label] = class
+		metrics.MacroF1 += class.F1
+	}
+	metrics.MacroF1 /= float64(len(metrics.Confusion))
+	return metrics
+}
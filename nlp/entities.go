@@ -0,0 +1,466 @@
+package nlp
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Entity types
+const (
+	EntityPerson       = "person"
+	EntityOrganization = "organization"
+	EntityPlace        = "place"
+	EntityOther        = "other"
+)
+
+// EntityTypes lists the entity types in the order they are documented
+var EntityTypes = []string{EntityPerson, EntityOrganization, EntityPlace, EntityOther}
+
+// EntityMention is an entity found in a text with the number of times it is
+// mentioned
+type EntityMention struct {
+	Name  string
+	Type  string
+	Count int
+}
+
+// Gazetteer maps known names and their aliases onto a canonical name and
+// type. Aliases written in capitals (e.g. "UN") only match exactly; all
+// others match regardless of case.
+type Gazetteer struct {
+	entries map[string]gazetteerEntry
+}
+
+type gazetteerEntry struct {
+	Name string
+	Type string
+}
+
+// NewGazetteer returns a gazetteer holding the built-in countries, cities and
+// international organizations
+func NewGazetteer() *Gazetteer {
+	g := &Gazetteer{entries: map[string]gazetteerEntry{}}
+	for entityType, lines := range builtinGazetteer {
+		for _, line := range lines {
+			g.addLine(entityType, line)
+		}
+	}
+	return g
+}
+
+// Add registers a canonical name of the given type and its aliases
+func (g *Gazetteer) Add(entityType, name string, aliases ...string) {
+	entry := gazetteerEntry{Name: name, Type: entityType}
+	for _, alias := range append([]string{name}, aliases...) {
+		g.entries[gazetteerKey(alias)] = entry
+	}
+}
+
+func (g *Gazetteer) addLine(entityType, line string) {
+	names := strings.Split(line, "|")
+	for i := range names {
+		names[i] = strings.TrimSpace(names[i])
+	}
+	if names[0] == "" {
+		return
+	}
+	g.Add(entityType, names[0], names[1:]...)
+}
+
+// LoadDir adds the entries of every <type>.txt file in dir, where type is
+// one of EntityTypes. Each line holds a canonical name optionally followed by
+// aliases, separated by "|"; blank lines and lines starting with # are
+// skipped.
+func (g *Gazetteer) LoadDir(dir string) error {
+	for _, entityType := range EntityTypes {
+		path := filepath.Join(dir, entityType+".txt")
+		file, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to open gazetteer %s: %v", path, err)
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			g.addLine(entityType, line)
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read gazetteer %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// Lookup returns the canonical name and type of a known name
+func (g *Gazetteer) Lookup(name string) (string, string, bool) {
+	if g == nil {
+		return "", "", false
+	}
+	entry, ok := g.entries[gazetteerKey(name)]
+	return entry.Name, entry.Type, ok
+}
+
+func gazetteerKey(name string) string {
+	if isAcronym(name) {
+		return name
+	}
+	return strings.ToLower(name)
+}
+
+// isAcronym reports whether word is written in capitals, dots aside, like
+// "NATO" or "U.S."
+func isAcronym(word string) bool {
+	letters := 0
+	for _, r := range word {
+		if unicode.IsLetter(r) {
+			if !unicode.IsUpper(r) {
+				return false
+			}
+			letters++
+		}
+	}
+	return letters >= 2
+}
+
+// entityToken matches words (including inner dots, apostrophes, hyphens and
+// ampersands), sentence punctuation and line breaks
+var entityToken = regexp.MustCompile(`[\p{L}\p{N}][\p{L}\p{M}\p{N}'’.&-]*|[.!?;:,"“”()\[\]]|\n`)
+
+type token struct {
+	Text          string
+	SentenceStart bool
+	// Break marks punctuation that ends a name
+	Break bool
+}
+
+// tokenize splits text into tokens, separating trailing full stops from
+// words that are not abbreviations
+func tokenize(text string) []token {
+	var tokens []token
+	sentenceStart := true
+	for _, raw := range entityToken.FindAllString(text, -1) {
+		first, _ := utf8.DecodeRuneInString(raw)
+		if !unicode.IsLetter(first) && !unicode.IsNumber(first) {
+			tokens = append(tokens, token{Text: raw, Break: true})
+			if strings.ContainsAny(raw, ".!?:\n") {
+				sentenceStart = true
+			}
+			continue
+		}
+
+		endsSentence := false
+		if strings.HasSuffix(raw, ".") && !isAbbreviation(raw) {
+			raw = strings.TrimRight(raw, ".")
+			endsSentence = true
+		}
+		// Possessives name the owner and end the name
+		possessive := false
+		for _, suffix := range []string{"'s", "’s"} {
+			if strings.HasSuffix(raw, suffix) {
+				raw = strings.TrimSuffix(raw, suffix)
+				possessive = true
+			}
+		}
+		raw = strings.Trim(raw, "'’-")
+		if raw != "" {
+			tokens = append(tokens, token{Text: raw, SentenceStart: sentenceStart})
+			sentenceStart = false
+		}
+		if possessive && !endsSentence {
+			tokens = append(tokens, token{Text: "'s", Break: true})
+		}
+		if endsSentence {
+			tokens = append(tokens, token{Text: ".", Break: true})
+			sentenceStart = true
+		}
+	}
+	return tokens
+}
+
+// isAbbreviation reports whether a word ending in a full stop is an
+// abbreviation like "U.S." or "Dr." rather than the end of a sentence
+func isAbbreviation(word string) bool {
+	trimmed := strings.TrimSuffix(word, ".")
+	if strings.Contains(trimmed, ".") {
+		return true
+	}
+	return abbreviations[strings.ToLower(trimmed)]
+}
+
+func isCapitalized(word string) bool {
+	first, _ := utf8.DecodeRuneInString(word)
+	return unicode.IsUpper(first)
+}
+
+// candidate is a run of capitalized tokens that may name an entity
+type candidate struct {
+	Words []string
+	// Titled is set when a personal title such as "President" preceded it
+	Titled bool
+	// Previous is the lowercased word before it, if any
+	Previous string
+	// SentenceStart is set when it opens a sentence
+	SentenceStart bool
+	// Next is the lowercase word after it, if any
+	Next string
+}
+
+// candidates finds runs of capitalized words, allowing lowercase connectors
+// such as "of" or "van" between them and splitting at personal titles
+func candidates(tokens []token) []candidate {
+	var result []candidate
+	var current *candidate
+	titled := false
+	previous := ""
+
+	flush := func(next string) {
+		if current == nil {
+			return
+		}
+		current.Next = next
+		// Connectors only count between capitalized words
+		for len(current.Words) > 0 && nameConnectors[current.Words[len(current.Words)-1]] {
+			current.Words = current.Words[:len(current.Words)-1]
+		}
+		if len(current.Words) > 0 {
+			result = append(result, *current)
+		}
+		current = nil
+	}
+
+	for i, tok := range tokens {
+		if tok.Break {
+			flush("")
+			titled = false
+			previous = ""
+			continue
+		}
+		lower := strings.ToLower(tok.Text)
+		// A title only counts when a name follows, so "Stephen King" stays whole
+		nameFollows := i+1 < len(tokens) && !tokens[i+1].Break && isCapitalized(tokens[i+1].Text)
+		switch {
+		case isCapitalized(tok.Text) && personTitles[strings.TrimSuffix(lower, ".")] && nameFollows:
+			flush("")
+			titled = true
+		case isCapitalized(tok.Text):
+			if current == nil {
+				current = &candidate{Titled: titled, Previous: previous, SentenceStart: tok.SentenceStart}
+			}
+			current.Words = append(current.Words, tok.Text)
+		case current != nil && (nameConnectors[lower] || tok.Text == "&"):
+			current.Words = append(current.Words, tok.Text)
+		default:
+			flush(lower)
+			titled = false
+		}
+		previous = lower
+	}
+	flush("")
+	return result
+}
+
+// ExtractEntities finds people, organizations and places in text. Names the
+// gazetteer knows take its canonical form and type; the others are typed
+// from titles, organization suffixes, acronyms and the preposition before
+// them. A lone surname counts towards a full name mentioned in the same text.
+func ExtractEntities(text string, gazetteer *Gazetteer) []EntityMention {
+	tokens := tokenize(text)
+
+	// Words that also appear capitalized mid-sentence are names even when
+	// they open a sentence
+	midSentence := map[string]bool{}
+	for _, tok := range tokens {
+		if !tok.Break && !tok.SentenceStart && isCapitalized(tok.Text) {
+			midSentence[tok.Text] = true
+		}
+	}
+
+	type key struct{ name, entityType string }
+	counts := map[key]int{}
+	var order []key
+	add := func(name, entityType string) {
+		k := key{name, entityType}
+		if counts[k] == 0 {
+			order = append(order, k)
+		}
+		counts[k]++
+	}
+
+	var singles []candidate
+	for _, cand := range candidates(tokens) {
+		words := cand.Words
+		// A sentence-initial article or preposition is not part of the name
+		if cand.SentenceStart && len(words) > 1 && anyStopword[strings.ToLower(words[0])] {
+			words = words[1:]
+			cand.SentenceStart = false
+		}
+		// So is a sentence-initial word joined on by a connector, as in
+		// "Shares of Goldman Sachs"
+		if cand.SentenceStart && len(words) > 2 && nameConnectors[words[1]] && !midSentence[words[0]] {
+			words = words[2:]
+			cand.SentenceStart = false
+		}
+		if len(words) > 1 && strings.EqualFold(words[0], "the") {
+			words = words[1:]
+		}
+		name := strings.Join(words, " ")
+
+		if canonical, entityType, ok := gazetteer.Lookup(name); ok {
+			add(canonical, entityType)
+			continue
+		}
+		if !plausibleName(words, cand.SentenceStart, midSentence) {
+			continue
+		}
+		if len(words) == 1 && !cand.Titled && !isAcronym(name) {
+			// Typed after the full names are known
+			cand.Words = words
+			singles = append(singles, cand)
+			continue
+		}
+		add(name, classifyEntity(words, cand))
+	}
+
+	// Resolve lone words: surnames of people named in full, then the rest
+	people := map[string]string{}
+	for _, k := range order {
+		if k.entityType == EntityPerson {
+			parts := strings.Fields(k.name)
+			people[parts[len(parts)-1]] = k.name
+		}
+	}
+	for _, cand := range singles {
+		word := cand.Words[0]
+		if fullName, ok := people[word]; ok {
+			add(fullName, EntityPerson)
+			continue
+		}
+		add(word, classifyEntity(cand.Words, cand))
+	}
+
+	mentions := make([]EntityMention, 0, len(order))
+	for _, k := range order {
+		mentions = append(mentions, EntityMention{Name: k.name, Type: k.entityType, Count: counts[k]})
+	}
+	sort.SliceStable(mentions, func(i, j int) bool {
+		return mentions[i].Count > mentions[j].Count
+	})
+	return mentions
+}
+
+// plausibleName filters out capitalized words that are rarely names
+func plausibleName(words []string, sentenceStart bool, midSentence map[string]bool) bool {
+	name := strings.Join(words, " ")
+	if utf8.RuneCountInString(name) < 2 {
+		return false
+	}
+	if len(words) == 1 {
+		lower := strings.ToLower(words[0])
+		if anyStopword[lower] || calendarWords[lower] {
+			return false
+		}
+		// A capital at the start of a sentence says nothing
+		if sentenceStart && !isAcronym(words[0]) && !midSentence[words[0]] {
+			return false
+		}
+	}
+	for _, word := range words {
+		if r, _ := utf8.DecodeRuneInString(word); unicode.IsNumber(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// classifyEntity guesses the type of a name the gazetteer does not know
+func classifyEntity(words []string, cand candidate) string {
+	last := strings.ToLower(strings.TrimSuffix(words[len(words)-1], "."))
+	switch {
+	case cand.Titled:
+		return EntityPerson
+	case orgSuffixes[last] || orgPrefixes[strings.ToLower(words[0])] || orgSuffixes[cand.Next]:
+		return EntityOrganization
+	case len(words) == 1 && isAcronym(words[0]):
+		return EntityOrganization
+	case placePrepositions[cand.Previous]:
+		return EntityPlace
+	case len(words) >= 2 && len(words) <= 3 && !containsConnector(words):
+		return EntityPerson
+	}
+	return EntityOther
+}
+
+func containsConnector(words []string) bool {
+	for _, word := range words {
+		if nameConnectors[word] || word == "&" {
+			return true
+		}
+	}
+	return false
+}
+
+func set(words ...string) map[string]bool {
+	result := make(map[string]bool, len(words))
+	for _, word := range words {
+		result[word] = true
+	}
+	return result
+}
+
+// personTitles precede people's names and are not part of them
+var personTitles = set(
+	"mr", "mrs", "ms", "miss", "dr", "prof", "professor", "sir", "dame", "lord", "lady", "prime", "vice", "deputy", "chief",
+	"president", "vice-president", "chancellor", "premier", "minister", "senator", "sen", "rep",
+	"representative", "congressman", "congresswoman", "governor", "gov", "mayor", "king", "queen",
+	"prince", "princess", "pope", "judge", "gen", "colonel", "col", "captain",
+	"capt", "ceo", "chairman", "chairwoman", "coach", "secretary", "ambassador", "commissioner",
+	"sheikh", "archbishop", "bishop", "cardinal", "rabbi", "imam",
+)
+
+// orgSuffixes end organization names
+var orgSuffixes = set(
+	"inc", "corp", "corporation", "co", "company", "ltd", "llc", "plc", "gmbh", "ag", "sa", "nv", "group",
+	"holdings", "bank", "university", "college", "institute", "foundation", "association", "agency",
+	"ministry", "department", "council", "committee", "commission", "party", "union", "federation",
+	"club", "fc", "united", "airlines", "airways", "motors", "technologies", "labs", "systems",
+	"network", "news", "times", "post", "journal", "press", "court", "parliament", "congress", "senate",
+	"police", "army", "navy", "force", "service", "authority", "board", "organization", "organisation",
+	"society", "trust", "fund", "reserve", "exchange", "school", "hospital", "museum", "studios",
+	"records", "pictures", "entertainment", "media", "pharmaceuticals", "energy", "oil",
+)
+
+// abbreviations end in a full stop without ending the sentence
+var abbreviations = set(
+	"mr", "mrs", "ms", "dr", "prof", "sen", "rep", "gov", "gen", "col", "capt", "st", "jr", "sr",
+	"inc", "corp", "co", "ltd", "no", "vs", "mt", "ft",
+)
+
+// orgPrefixes open organization names
+var orgPrefixes = set("university", "bank", "ministry", "department", "institute", "museum", "council")
+
+// placePrepositions usually precede places
+var placePrepositions = set("in", "from", "near", "across", "outside", "throughout", "towards", "toward")
+
+// nameConnectors may join the capitalized words of a single name
+var nameConnectors = set("of", "de", "del", "della", "der", "den", "van", "von", "da", "di", "du", "la", "le", "al", "bin", "el", "y", "for")
+
+// calendarWords are capitalized without being entities
+var calendarWords = set(
+	"monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday",
+	"january", "february", "march", "april", "may", "june", "july", "august", "september",
+	"october", "november", "december", "today", "yesterday", "tomorrow",
+)
@@ -0,0 +1,51 @@
+package nlp
+
+// builtinGazetteer holds widely reported names by entity type, one canonical
+// name per line followed by its aliases. Deployments extend it with files in
+// ENTITY_GAZETTEER_DIR, see Gazetteer.LoadDir.
+var builtinGazetteer = map[string][]string{
+	EntityPlace: {
+		"United States|US|U.S.|USA|U.S.A.|America",
+		"United Kingdom|UK|U.K.|Britain|Great Britain",
+		"European Union|EU|E.U.",
+		"China|People's Republic of China|PRC",
+		"Russia|Russian Federation",
+		"Ukraine", "Germany", "France", "Italy", "Spain", "Portugal", "Netherlands|Holland", "Belgium",
+		"Switzerland", "Austria", "Poland", "Czech Republic|Czechia", "Slovakia", "Hungary", "Romania",
+		"Bulgaria", "Greece", "Turkey|Türkiye", "Sweden", "Norway", "Denmark", "Finland", "Iceland",
+		"Ireland", "Serbia", "Croatia", "Slovenia", "Lithuania", "Latvia", "Estonia", "Belarus",
+		"Moldova", "Georgia", "Armenia", "Azerbaijan", "Kazakhstan", "Israel", "Palestine", "Gaza|Gaza Strip",
+		"West Bank", "Lebanon", "Syria", "Jordan", "Iraq", "Iran", "Saudi Arabia", "Yemen", "Qatar",
+		"United Arab Emirates|UAE", "Kuwait", "Oman", "Egypt", "Libya", "Tunisia", "Algeria", "Morocco",
+		"Sudan", "South Sudan", "Ethiopia", "Somalia", "Kenya", "Uganda", "Nigeria", "Ghana", "Senegal",
+		"South Africa", "Zimbabwe", "Congo|Democratic Republic of the Congo|DRC", "Rwanda", "Mali",
+		"India", "Pakistan", "Afghanistan", "Bangladesh", "Sri Lanka", "Nepal", "Myanmar|Burma",
+		"Thailand", "Vietnam", "Cambodia", "Malaysia", "Singapore", "Indonesia", "Philippines",
+		"Japan", "South Korea|Korea", "North Korea|DPRK", "Taiwan", "Hong Kong", "Mongolia",
+		"Australia", "New Zealand", "Canada", "Mexico", "Cuba", "Haiti", "Guatemala", "Honduras",
+		"Nicaragua", "Panama", "Colombia", "Venezuela", "Ecuador", "Peru", "Bolivia", "Chile",
+		"Argentina", "Brazil", "Uruguay", "Paraguay",
+		"Washington|Washington D.C.|Washington, D.C.", "New York|New York City|NYC", "Los Angeles|LA",
+		"San Francisco", "Chicago", "Boston", "Miami", "Houston", "Texas", "California", "Florida",
+		"London", "Paris", "Berlin", "Madrid", "Rome", "Brussels", "Amsterdam", "Vienna", "Prague",
+		"Warsaw", "Budapest", "Athens", "Stockholm", "Oslo", "Copenhagen", "Helsinki", "Dublin",
+		"Lisbon", "Geneva", "Zurich", "Moscow", "Kyiv|Kiev", "Minsk", "Istanbul", "Ankara",
+		"Jerusalem", "Tel Aviv", "Beirut", "Damascus", "Baghdad", "Tehran", "Riyadh", "Dubai", "Doha",
+		"Cairo", "Nairobi", "Lagos", "Johannesburg", "New Delhi|Delhi", "Mumbai", "Karachi", "Islamabad",
+		"Kabul", "Beijing", "Shanghai", "Tokyo", "Seoul", "Pyongyang", "Taipei", "Bangkok", "Jakarta",
+		"Manila", "Sydney", "Melbourne", "Toronto", "Ottawa", "Mexico City", "Sao Paulo|São Paulo",
+		"Rio de Janeiro", "Buenos Aires",
+	},
+	EntityOrganization: {
+		"United Nations|UN|U.N.", "NATO|North Atlantic Treaty Organization",
+		"World Health Organization|WHO", "World Bank", "International Monetary Fund|IMF",
+		"World Trade Organization|WTO", "European Commission", "European Central Bank|ECB",
+		"Federal Reserve|Fed|the Fed", "Organization of the Petroleum Exporting Countries|OPEC",
+		"International Criminal Court|ICC", "Red Cross|International Committee of the Red Cross|ICRC",
+		"Hamas", "Hezbollah", "Taliban", "Kremlin", "Pentagon", "White House", "Downing Street",
+		"Supreme Court", "Congress", "Senate", "House of Representatives", "FBI", "CIA", "NASA",
+		"SEC|Securities and Exchange Commission", "Apple", "Google|Alphabet", "Microsoft", "Amazon",
+		"Meta|Facebook", "Tesla", "Nvidia", "OpenAI", "Netflix", "Samsung", "Intel", "IBM", "Boeing",
+		"Airbus", "Toyota", "Volkswagen", "Reuters", "Associated Press|AP", "BBC", "CNN",
+	},
+}
@@ -0,0 +1,249 @@
+// Package nlp holds the text analysis used at ingestion time. Everything in
+// it runs offline and depends on neither the database nor the providers.
+package nlp
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// minTrigrams is the least amount of text worth guessing a language for
+const minTrigrams = 12
+
+// languageProfile is the trigram model of one language
+type languageProfile struct {
+	Code     string
+	logProb  map[string]float64
+	unseenLP float64
+}
+
+var profiles = buildProfiles(languageSamples)
+
+// unseenMass is the probability mass reserved for trigrams a sample never
+// contains, and unseenVocabulary the number of such trigrams it is spread
+// over. Both are the same for every language so that languages with shorter
+// samples are not favored.
+const (
+	unseenMass       = 0.1
+	unseenVocabulary = 20000
+)
+
+// buildProfiles turns sample texts into smoothed trigram models
+func buildProfiles(samples map[string]string) []*languageProfile {
+	codes := make([]string, 0, len(samples))
+	for code := range samples {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	result := make([]*languageProfile, 0, len(codes))
+	for _, code := range codes {
+		counts := map[string]int{}
+		total := 0
+		for _, gram := range trigrams(samples[code]) {
+			counts[gram]++
+			total++
+		}
+		profile := &languageProfile{
+			Code:     code,
+			logProb:  make(map[string]float64, len(counts)),
+			unseenLP: math.Log(unseenMass / unseenVocabulary),
+		}
+		for gram, count := range counts {
+			profile.logProb[gram] = math.Log((1 - unseenMass) * float64(count) / float64(total))
+		}
+		result = append(result, profile)
+	}
+	return result
+}
+
+// words splits text into lowercased words
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+}
+
+// trigrams returns the character trigrams of the lowercased words of text,
+// padded with spaces so word starts and ends count
+func trigrams(text string) []string {
+	var grams []string
+	for _, word := range words(text) {
+		runes := []rune(" " + word + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			grams = append(grams, string(runes[i:i+3]))
+		}
+	}
+	return grams
+}
+
+// hintPrior is the prior probability given to a provider's language hint
+const hintPrior = 0.5
+
+// DetectLanguage guesses the ISO 639-1 language of text and returns it with
+// a confidence between 0 and 1. It returns "" when the text is too short or
+// in a script it has no model for.
+func DetectLanguage(text string) (string, float64) {
+	return DetectLanguageWithHint(text, "")
+}
+
+// DetectLanguageWithHint is DetectLanguage with a prior towards hint, the
+// language the provider was asked for. Short headlines without function words
+// then fall back to the hint unless the text clearly says otherwise.
+func DetectLanguageWithHint(text, hint string) (string, float64) {
+	if code, ok := detectScript(text); ok {
+		if hint == code {
+			return code, 1
+		}
+		// The script says little more than the language family here, so a
+		// hint from the family names the language
+		for _, other := range scriptSharedWith[code] {
+			if hint == other {
+				return hint, 1
+			}
+		}
+		if len(scriptSharedWith[code]) > 0 {
+			return code, ambiguousScriptConfidence
+		}
+		return code, 1
+	}
+
+	grams := trigrams(text)
+	if len(grams) < minTrigrams {
+		if hint != "" {
+			return hint, hintPrior
+		}
+		return "", 0
+	}
+
+	tokens := words(text)
+	scores := make([]float64, len(profiles))
+	best := 0
+	for i, profile := range profiles {
+		for _, gram := range grams {
+			if lp, ok := profile.logProb[gram]; ok {
+				scores[i] += lp
+			} else {
+				scores[i] += profile.unseenLP
+			}
+		}
+		for _, token := range tokens {
+			if stopwordSets[profile.Code][token] {
+				scores[i] += stopwordBonus
+			}
+		}
+		if hint != "" {
+			if profile.Code == hint {
+				scores[i] += math.Log(hintPrior)
+			} else {
+				scores[i] += math.Log((1 - hintPrior) / float64(len(profiles)-1))
+			}
+		}
+		if scores[i] > scores[best] {
+			best = i
+		}
+	}
+
+	// Posterior of the best language
+	sum := 0.0
+	for _, score := range scores {
+		sum += math.Exp(score - scores[best])
+	}
+	return profiles[best].Code, 1 / sum
+}
+
+// scriptLanguages maps scripts used by a single language we care about onto
+// that language; Latin-script text goes through the trigram models
+var scriptLanguages = []struct {
+	table *unicode.RangeTable
+	code  string
+}{
+	{unicode.Cyrillic, "ru"},
+	{unicode.Greek, "el"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Hangul, "ko"},
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Han, "zh"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Thai, "th"},
+}
+
+// scriptSharedWith lists the other languages written in the script of a
+// language in scriptLanguages. Their hints are taken over the script's
+// language; Postgres has no stemmer for most of them, so they search with
+// the simple configuration.
+var scriptSharedWith = map[string][]string{
+	"ru": {"uk", "bg", "sr", "mk", "be", "kk", "ky", "mn"},
+	"ar": {"fa", "ur", "ps"},
+	"hi": {"mr", "ne"},
+}
+
+// ambiguousScriptConfidence is the confidence in a script's language when
+// the script is shared and no hint says which language it is
+const ambiguousScriptConfidence = 0.5
+
+// detectScript reports the language of text written mostly in a non-Latin
+// script. Japanese mixes Han with kana, so any kana wins over Han.
+func detectScript(text string) (string, bool) {
+	counts := map[string]int{}
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for _, script := range scriptLanguages {
+			if unicode.Is(script.table, r) {
+				counts[script.code]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return "", false
+	}
+	if counts["ja"] > 0 && counts["ja"]+counts["zh"] > letters/2 {
+		return "ja", true
+	}
+	for _, script := range scriptLanguages {
+		if counts[script.code] > letters/2 {
+			return script.code, true
+		}
+	}
+	return "", false
+}
+
+// searchConfigs maps ISO 639-1 codes onto Postgres text search configurations
+var searchConfigs = map[string]string{
+	"ar": "arabic",
+	"da": "danish",
+	"de": "german",
+	"el": "greek",
+	"en": "english",
+	"es": "spanish",
+	"fi": "finnish",
+	"fr": "french",
+	"hu": "hungarian",
+	"id": "indonesian",
+	"it": "italian",
+	"nl": "dutch",
+	"no": "norwegian",
+	"pt": "portuguese",
+	"ro": "romanian",
+	"ru": "russian",
+	"sv": "swedish",
+	"tr": "turkish",
+}
+
+// SearchConfig returns the Postgres text search configuration for a language
+// code, or "simple" when Postgres has no stemmer for it
+func SearchConfig(code string) string {
+	if config, ok := searchConfigs[strings.ToLower(code)]; ok {
+		return config
+	}
+	return "simple"
+}
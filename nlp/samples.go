@@ -0,0 +1,36 @@
+package nlp
+
+// languageSamples is the training text for the language profiles: a few
+// paragraphs of news-style prose per language. Trigram statistics of short
+// samples are enough to tell these languages apart on headline-sized input.
+var languageSamples = map[string]string{
+	"en": `The government announced on Tuesday that it would increase spending on public transport and housing over the next five years. Officials said the plan was designed to support economic growth while reducing emissions in the largest cities. Critics argued that the proposal did not go far enough and that many families were still struggling with the rising cost of living. The minister told reporters that the details would be published later this week, after talks with local authorities and business leaders. Shares in construction companies rose sharply after the news, while the central bank is expected to keep interest rates unchanged. Police have arrested two men in connection with the attack, which happened outside a school in the north of the country. Scientists say the new study shows that the climate is changing faster than they had previously thought. The technology company unveiled a new phone with a better camera, a longer battery life and a brighter screen, and said it would go on sale next month. Investors worry that inflation will stay high for longer, and stock markets fell for the third day in a row. The team won the championship after a dramatic final that went to extra time, and thousands of fans celebrated in the streets overnight. Health officials warned of a new wave of infections this winter and urged older people to get vaccinated. The president will travel to Europe next week for a summit with world leaders on trade, security and energy.`,
+
+	"de": `Die Bundesregierung hat am Dienstag angekündigt, die Ausgaben für den öffentlichen Nahverkehr und den Wohnungsbau in den nächsten fünf Jahren deutlich zu erhöhen. Nach Angaben von Regierungssprechern soll der Plan das Wirtschaftswachstum stützen und gleichzeitig die Emissionen in den großen Städten senken. Kritiker bemängelten, dass der Vorschlag nicht weit genug gehe und viele Familien weiterhin unter den steigenden Lebenshaltungskosten leiden. Der Minister sagte, die Einzelheiten würden noch in dieser Woche nach Gesprächen mit den Ländern und Vertretern der Wirtschaft veröffentlicht. Die Polizei hat zwei Männer im Zusammenhang mit dem Angriff festgenommen, der sich vor einer Schule im Norden des Landes ereignet hatte. Wissenschaftler sagen, dass sich das Klima schneller verändert als bisher angenommen.`,
+
+	"fr": `Le gouvernement a annoncé mardi qu'il allait augmenter les dépenses consacrées aux transports publics et au logement au cours des cinq prochaines années. Selon les responsables, ce plan doit soutenir la croissance économique tout en réduisant les émissions dans les grandes villes. Les critiques estiment que la proposition ne va pas assez loin et que de nombreuses familles ont encore du mal à faire face à la hausse du coût de la vie. Le ministre a déclaré aux journalistes que les détails seraient publiés à la fin de la semaine, après des discussions avec les collectivités locales et les chefs d'entreprise. La police a arrêté deux hommes dans le cadre de l'enquête sur l'attaque qui s'est produite devant une école du nord du pays. Les scientifiques affirment que le climat change plus vite que prévu.`,
+
+	"es": `El Gobierno anunció el martes que aumentará el gasto en transporte público y vivienda durante los próximos cinco años. Según los responsables, el plan está diseñado para apoyar el crecimiento económico y al mismo tiempo reducir las emisiones en las grandes ciudades. Los críticos sostienen que la propuesta no va lo suficientemente lejos y que muchas familias siguen teniendo dificultades por el aumento del coste de la vida. El ministro dijo a los periodistas que los detalles se publicarán a finales de esta semana, tras las conversaciones con las autoridades locales y los empresarios. La policía ha detenido a dos hombres en relación con el ataque, que se produjo frente a una escuela en el norte del país. Los científicos afirman que el clima está cambiando más rápido de lo que se pensaba.`,
+
+	"it": `Il governo ha annunciato martedì che aumenterà la spesa per il trasporto pubblico e per la casa nei prossimi cinque anni. Secondo i funzionari, il piano è pensato per sostenere la crescita economica e allo stesso tempo ridurre le emissioni nelle grandi città. I critici sostengono che la proposta non vada abbastanza lontano e che molte famiglie continuino a fare fatica a causa dell'aumento del costo della vita. Il ministro ha detto ai giornalisti che i dettagli saranno pubblicati entro la fine della settimana, dopo i colloqui con le autorità locali e con gli imprenditori. La polizia ha arrestato due uomini in relazione all'aggressione avvenuta davanti a una scuola nel nord del paese. Gli scienziati affermano che il clima sta cambiando più rapidamente di quanto si pensasse.`,
+
+	"pt": `O governo anunciou na terça-feira que vai aumentar os gastos com transporte público e habitação nos próximos cinco anos. Segundo as autoridades, o plano foi pensado para apoiar o crescimento econômico e, ao mesmo tempo, reduzir as emissões nas grandes cidades. Os críticos afirmam que a proposta não vai longe o suficiente e que muitas famílias ainda enfrentam dificuldades com o aumento do custo de vida. O ministro disse aos jornalistas que os detalhes serão divulgados no final desta semana, depois das conversas com as autoridades locais e os empresários. A polícia prendeu dois homens em ligação com o ataque, que aconteceu em frente a uma escola no norte do país. Os cientistas dizem que o clima está mudando mais rápido do que se pensava.`,
+
+	"nl": `De regering heeft dinsdag aangekondigd dat zij de komende vijf jaar meer geld gaat uitgeven aan het openbaar vervoer en de woningbouw. Volgens ambtenaren moet het plan de economische groei ondersteunen en tegelijkertijd de uitstoot in de grote steden verminderen. Critici vinden dat het voorstel niet ver genoeg gaat en dat veel gezinnen het nog steeds moeilijk hebben door de stijgende kosten van levensonderhoud. De minister zei tegen journalisten dat de details later deze week worden gepubliceerd, na overleg met gemeenten en het bedrijfsleven. De politie heeft twee mannen aangehouden in verband met de aanval die plaatsvond voor een school in het noorden van het land. Wetenschappers zeggen dat het klimaat sneller verandert dan eerder werd gedacht. Het kabinet wil strengere regels voor huurwoningen en meer bescherming voor huurders. Het techbedrijf presenteerde een nieuwe telefoon met een betere camera en een langere batterijduur. De ploeg won de finale na verlenging en duizenden supporters vierden feest in de straten van de stad.`,
+
+	"sv": `Regeringen meddelade på tisdagen att den kommer att öka utgifterna för kollektivtrafik och bostäder under de kommande fem åren. Enligt tjänstemän är planen tänkt att stödja den ekonomiska tillväxten och samtidigt minska utsläppen i de största städerna. Kritiker menar att förslaget inte går tillräckligt långt och att många familjer fortfarande kämpar med de stigande levnadskostnaderna. Ministern sade till journalister att detaljerna kommer att presenteras senare i veckan, efter samtal med kommunerna och näringslivet. Polisen har gripit två män i samband med attacken, som inträffade utanför en skola i norra delen av landet. Forskare säger att klimatet förändras snabbare än man tidigare trott.`,
+
+	"da": `Regeringen meddelte tirsdag, at den vil øge udgifterne til offentlig transport og boliger i løbet af de næste fem år. Ifølge embedsmænd skal planen understøtte den økonomiske vækst og samtidig mindske udledningerne i de største byer. Kritikere mener, at forslaget ikke går langt nok, og at mange familier stadig kæmper med de stigende leveomkostninger. Ministeren sagde til journalister, at detaljerne vil blive offentliggjort senere på ugen efter drøftelser med kommunerne og erhvervslivet. Politiet har anholdt to mænd i forbindelse med angrebet, som fandt sted uden for en skole i den nordlige del af landet. Forskere siger, at klimaet ændrer sig hurtigere, end man hidtil har troet.`,
+
+	"no": `Regjeringen kunngjorde tirsdag at den vil øke bevilgningene til kollektivtransport og boliger i løpet av de neste fem årene. Ifølge embetsverket skal planen støtte den økonomiske veksten og samtidig redusere utslippene i de største byene. Kritikere mener at forslaget ikke går langt nok, og at mange familier fortsatt sliter med økte levekostnader. Statsråden sa til journalister at detaljene blir lagt fram senere denne uken, etter samtaler med kommunene og næringslivet. Politiet har pågrepet to menn i forbindelse med angrepet, som skjedde utenfor en skole nord i landet. Forskere sier at klimaet endrer seg raskere enn man tidligere har trodd, og at det haster med tiltak.`,
+
+	"fi": `Hallitus ilmoitti tiistaina, että se lisää joukkoliikenteen ja asumisen rahoitusta seuraavien viiden vuoden aikana. Virkamiesten mukaan suunnitelman tarkoituksena on tukea talouskasvua ja samalla vähentää päästöjä suurimmissa kaupungeissa. Arvostelijoiden mielestä ehdotus ei mene tarpeeksi pitkälle, ja monet perheet kamppailevat edelleen nousevien elinkustannusten kanssa. Ministeri kertoi toimittajille, että yksityiskohdat julkaistaan myöhemmin tällä viikolla kuntien ja elinkeinoelämän kanssa käytyjen keskustelujen jälkeen. Poliisi on pidättänyt kaksi miestä hyökkäykseen liittyen, joka tapahtui koulun edessä maan pohjoisosassa. Tutkijoiden mukaan ilmasto muuttuu nopeammin kuin aiemmin luultiin.`,
+
+	"tr": `Hükümet salı günü yaptığı açıklamada önümüzdeki beş yıl içinde toplu taşıma ve konut harcamalarını artıracağını duyurdu. Yetkililere göre plan, büyük şehirlerdeki emisyonları azaltırken ekonomik büyümeyi desteklemek amacıyla hazırlandı. Eleştirmenler ise önerinin yeterince ileri gitmediğini ve pek çok ailenin artan yaşam maliyetleriyle hâlâ mücadele ettiğini söyledi. Bakan gazetecilere yaptığı açıklamada ayrıntıların yerel yönetimler ve iş dünyasıyla yapılacak görüşmelerin ardından bu hafta içinde yayımlanacağını belirtti. Polis, ülkenin kuzeyindeki bir okulun önünde meydana gelen saldırıyla bağlantılı olarak iki kişiyi gözaltına aldı. Bilim insanları iklimin daha önce düşünülenden daha hızlı değiştiğini söylüyor.`,
+
+	"ro": `Guvernul a anunțat marți că va crește cheltuielile pentru transportul public și locuințe în următorii cinci ani. Potrivit oficialilor, planul este menit să sprijine creșterea economică și, în același timp, să reducă emisiile în marile orașe. Criticii susțin că propunerea nu merge suficient de departe și că multe familii se confruntă în continuare cu creșterea costului vieții. Ministrul le-a spus jurnaliștilor că detaliile vor fi publicate la sfârșitul acestei săptămâni, după discuțiile cu autoritățile locale și cu oamenii de afaceri. Poliția a arestat doi bărbați în legătură cu atacul care a avut loc în fața unei școli din nordul țării. Oamenii de știință spun că schimbările climatice au loc mai repede decât se credea.`,
+
+	"hu": `A kormány kedden bejelentette, hogy a következő öt évben növeli a tömegközlekedésre és a lakhatásra fordított kiadásokat. A tisztviselők szerint a terv célja, hogy támogassa a gazdasági növekedést, és közben csökkentse a kibocsátást a nagyvárosokban. A bírálók szerint a javaslat nem megy elég messzire, és sok család továbbra is küzd a megélhetési költségek emelkedésével. A miniszter az újságíróknak azt mondta, hogy a részleteket a hét végén hozzák nyilvánosságra, miután egyeztettek az önkormányzatokkal és az üzleti élet képviselőivel. A rendőrség két férfit vett őrizetbe az ország északi részén egy iskola előtt történt támadással összefüggésben. A tudósok szerint az éghajlat gyorsabban változik, mint korábban gondolták.`,
+
+	"id": `Pemerintah mengumumkan pada hari Selasa bahwa mereka akan meningkatkan belanja untuk transportasi umum dan perumahan selama lima tahun ke depan. Menurut para pejabat, rencana tersebut dirancang untuk mendukung pertumbuhan ekonomi sekaligus mengurangi emisi di kota-kota besar. Para pengkritik menilai usulan itu belum cukup jauh dan banyak keluarga yang masih kesulitan menghadapi kenaikan biaya hidup. Menteri mengatakan kepada wartawan bahwa rinciannya akan diumumkan akhir pekan ini setelah pembicaraan dengan pemerintah daerah dan para pengusaha. Polisi telah menangkap dua pria terkait serangan yang terjadi di depan sebuah sekolah di bagian utara negara itu. Para ilmuwan mengatakan bahwa iklim berubah lebih cepat dari yang diperkirakan sebelumnya.`,
+}
@@ -0,0 +1,37 @@
+package nlp
+
+// stopwords are the most frequent function words of each language. They
+// carry more signal on headline-length text than trigrams alone.
+var stopwords = map[string][]string{
+	"en": {"the", "of", "and", "to", "in", "is", "for", "on", "with", "as", "at", "by", "from", "that", "this", "it", "are", "was", "be", "new", "after", "over", "about", "its", "has", "have", "will", "his", "her", "says", "how", "what", "why", "who"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "mit", "von", "den", "dem", "zu", "für", "auf", "ein", "eine", "im", "sich", "auch", "nach", "bei", "wird", "aus", "über", "wie", "des"},
+	"fr": {"le", "la", "les", "de", "des", "du", "et", "est", "un", "une", "pour", "dans", "sur", "au", "aux", "pas", "qui", "que", "avec", "par", "en", "ce", "il", "elle", "après"},
+	"es": {"el", "la", "los", "las", "de", "del", "y", "en", "un", "una", "por", "para", "con", "que", "es", "se", "al", "su", "más", "como", "tras", "sobre", "pero", "sus"},
+	"it": {"il", "lo", "la", "gli", "le", "di", "del", "della", "e", "è", "un", "una", "per", "con", "che", "non", "in", "su", "dei", "alla", "nel", "sono", "anche", "dopo"},
+	"pt": {"o", "a", "os", "as", "de", "do", "da", "dos", "das", "e", "em", "um", "uma", "para", "com", "que", "não", "no", "na", "por", "mais", "ao", "se", "após"},
+	"nl": {"de", "het", "een", "en", "van", "in", "is", "op", "te", "voor", "met", "niet", "zijn", "dat", "die", "aan", "bij", "ook", "wil", "naar", "nog", "wordt", "door", "uit"},
+	"sv": {"och", "i", "att", "det", "som", "en", "på", "är", "av", "för", "med", "till", "den", "inte", "har", "om", "ett", "vill", "efter", "från", "kan", "var", "nya"},
+	"da": {"og", "i", "at", "det", "som", "en", "på", "er", "af", "for", "med", "til", "den", "ikke", "har", "om", "et", "vil", "efter", "fra", "kan", "var", "nye", "sig"},
+	"no": {"og", "i", "at", "det", "som", "en", "på", "er", "av", "for", "med", "til", "den", "ikke", "har", "om", "et", "vil", "etter", "fra", "kan", "var", "nye", "seg"},
+	"fi": {"ja", "on", "ei", "se", "että", "oli", "kun", "mutta", "myös", "tai", "jo", "hän", "ovat", "joka", "sen", "voi", "kuin", "mukaan"},
+	"tr": {"ve", "bir", "bu", "da", "de", "için", "ile", "çok", "olarak", "daha", "gibi", "sonra", "ama", "en", "mi", "ne", "yeni", "olan"},
+	"ro": {"și", "în", "de", "la", "cu", "pe", "un", "o", "a", "că", "nu", "din", "pentru", "este", "care", "mai", "după", "fost", "sunt"},
+	"hu": {"a", "az", "és", "hogy", "nem", "is", "egy", "meg", "van", "de", "csak", "el", "ki", "már", "mint", "után", "lesz", "volt"},
+	"id": {"yang", "dan", "di", "ini", "itu", "dengan", "untuk", "dari", "ke", "tidak", "akan", "pada", "juga", "dalam", "ada", "baru", "oleh", "bisa"},
+}
+
+// stopwordBonus is the log-likelihood added for each stopword of a language
+const stopwordBonus = 3.0
+
+var stopwordSets = buildStopwordSets(stopwords)
+
+func buildStopwordSets(lists map[string][]string) map[string]map[string]bool {
+	sets := make(map[string]map[string]bool, len(lists))
+	for code, words := range lists {
+		sets[code] = make(map[string]bool, len(words))
+		for _, word := range words {
+			sets[code][word] = true
+		}
+	}
+	return sets
+}
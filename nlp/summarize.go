@@ -0,0 +1,291 @@
+package nlp
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Sentence lengths, in words, that are worth putting in a summary
+const (
+	minSentenceWords = 5
+	maxSentenceWords = 80
+)
+
+// TextRank parameters: the damping factor, the iteration cap and the change
+// below which the ranking counts as converged
+const (
+	damping       = 0.85
+	maxIterations = 50
+	convergence   = 1e-6
+)
+
+// redundancyThreshold is the word overlap (Jaccard) above which a sentence
+// repeats one already picked for a summary
+const redundancyThreshold = 0.5
+
+// RankedSentence is a sentence picked for a summary. Document is the index of
+// the text it came from and Position its index within that text.
+type RankedSentence struct {
+	Text     string  `json:"text"`
+	Document int     `json:"document"`
+	Position int     `json:"position"`
+	Score    float64 `json:"score"`
+}
+
+// sentenceEnd matches the end of a sentence: terminal punctuation, optionally
+// closed by a quote or bracket, then whitespace
+var sentenceEnd = regexp.MustCompile(`[.!?]+["'”’)\]]*\s+`)
+
+// SplitSentences splits text into sentences. Paragraph breaks always end a
+// sentence; full stops after abbreviations and initials do not.
+func SplitSentences(text string) []string {
+	var sentences []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		start := 0
+		for _, match := range sentenceEnd.FindAllStringIndex(paragraph, -1) {
+			candidate := paragraph[start:match[1]]
+			if endsWithAbbreviation(strings.TrimSpace(candidate)) || !startsSentence(paragraph[match[1]:]) {
+				continue
+			}
+			sentences = appendSentence(sentences, candidate)
+			start = match[1]
+		}
+		sentences = appendSentence(sentences, paragraph[start:])
+	}
+	return sentences
+}
+
+func appendSentence(sentences []string, sentence string) []string {
+	if sentence = strings.TrimSpace(sentence); sentence != "" {
+		sentences = append(sentences, sentence)
+	}
+	return sentences
+}
+
+// endsWithAbbreviation reports whether text ends in an abbreviation such as
+// "Dr." or an initial such as "J."
+func endsWithAbbreviation(text string) bool {
+	if !strings.HasSuffix(text, ".") {
+		return false
+	}
+	fields := strings.Fields(text)
+	last := strings.TrimSuffix(fields[len(fields)-1], ".")
+	if utf8.RuneCountInString(last) == 1 && isCapitalized(last) {
+		return true
+	}
+	return isAbbreviation(last + ".")
+}
+
+// startsSentence reports whether text looks like the start of a sentence
+func startsSentence(text string) bool {
+	r, _ := utf8.DecodeRuneInString(strings.TrimLeft(text, "\"'“‘(["))
+	return unicode.IsUpper(r) || unicode.IsNumber(r)
+}
+
+// summarySentence is a candidate sentence with its content words
+type summarySentence struct {
+	RankedSentence
+	tokens map[string]bool
+}
+
+// maxLeadSentences caps the sentences each text contributes to a summary of
+// several texts. News puts the gist up front, and the cap keeps the sentence
+// graph small however long the texts are.
+const maxLeadSentences = 12
+
+// candidateSentences splits documents into sentences of a useful length,
+// keeping at most perDocument of each, or all of them when it is 0
+func candidateSentences(documents []string, perDocument int) []summarySentence {
+	var result []summarySentence
+	for d, document := range documents {
+		kept := 0
+		for i, text := range SplitSentences(document) {
+			if perDocument > 0 && kept == perDocument {
+				break
+			}
+			words := len(strings.Fields(text))
+			if words < minSentenceWords || words > maxSentenceWords {
+				continue
+			}
+			tokens := map[string]bool{}
+			for _, token := range classifierTokens(text) {
+				tokens[token] = true
+			}
+			if len(tokens) == 0 {
+				continue
+			}
+			result = append(result, summarySentence{
+				RankedSentence: RankedSentence{Text: text, Document: d, Position: i},
+				tokens:         tokens,
+			})
+			kept++
+		}
+	}
+	return result
+}
+
+// sentenceEdge links a sentence to another it shares content words with
+type sentenceEdge struct {
+	to     int
+	weight float64
+}
+
+// rankSentences scores sentences with TextRank: PageRank over a graph whose
+// edges weigh the content words two sentences share. News puts the gist up
+// front, so random jumps favor sentences early in their document. Only pairs
+// sharing a word are compared, found through an index of the sentences each
+// word occurs in.
+func rankSentences(sentences []summarySentence) {
+	n := len(sentences)
+	if n == 0 {
+		return
+	}
+
+	containing := map[string][]int{}
+	for i, sentence := range sentences {
+		for token := range sentence.tokens {
+			containing[token] = append(containing[token], i)
+		}
+	}
+	edges := make([][]sentenceEdge, n)
+	outWeight := make([]float64, n)
+	shared := map[int]int{}
+	for i, sentence := range sentences {
+		clear(shared)
+		for token := range sentence.tokens {
+			for _, j := range containing[token] {
+				if j > i {
+					shared[j]++
+				}
+			}
+		}
+		for j, count := range shared {
+			w := float64(count) / (math.Log(float64(len(sentence.tokens)+1)) + math.Log(float64(len(sentences[j].tokens)+1)))
+			edges[i] = append(edges[i], sentenceEdge{to: j, weight: w})
+			edges[j] = append(edges[j], sentenceEdge{to: i, weight: w})
+			outWeight[i] += w
+			outWeight[j] += w
+		}
+	}
+
+	teleport := make([]float64, n)
+	teleportSum := 0.0
+	for i, sentence := range sentences {
+		teleport[i] = 1 / math.Sqrt(float64(sentence.Position+1))
+		teleportSum += teleport[i]
+	}
+	scores := make([]float64, n)
+	for i := range teleport {
+		teleport[i] /= teleportSum
+		scores[i] = teleport[i]
+	}
+
+	next := make([]float64, n)
+	for iteration := 0; iteration < maxIterations; iteration++ {
+		// Sentences sharing nothing spread their score by teleporting
+		dangling := 0.0
+		for j := range sentences {
+			if outWeight[j] == 0 {
+				dangling += scores[j]
+			}
+		}
+		delta := 0.0
+		for i := range sentences {
+			// Edges are symmetric, so i's edges are also those into i
+			sum := 0.0
+			for _, edge := range edges[i] {
+				sum += edge.weight / outWeight[edge.to] * scores[edge.to]
+			}
+			next[i] = (1-damping)*teleport[i] + damping*(sum+dangling*teleport[i])
+			delta += math.Abs(next[i] - scores[i])
+		}
+		scores, next = next, scores
+		if delta < convergence {
+			break
+		}
+	}
+	for i := range sentences {
+		sentences[i].Score = scores[i]
+	}
+}
+
+// jaccard is the overlap of two word sets
+func jaccard(a, b map[string]bool) float64 {
+	shared := 0
+	for token := range a {
+		if b[token] {
+			shared++
+		}
+	}
+	union := len(a) + len(b) - shared
+	if union == 0 {
+		return 0
+	}
+	return float64(shared) / float64(union)
+}
+
+// pickSentences returns the n best sentences, skipping any that repeat one
+// already picked
+func pickSentences(sentences []summarySentence, n int) []summarySentence {
+	ranked := make([]summarySentence, len(sentences))
+	copy(ranked, sentences)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Score > ranked[j].Score
+	})
+
+	var picked []summarySentence
+	for _, candidate := range ranked {
+		if len(picked) == n {
+			break
+		}
+		redundant := false
+		for _, chosen := range picked {
+			if jaccard(candidate.tokens, chosen.tokens) > redundancyThreshold {
+				redundant = true
+				break
+			}
+		}
+		if !redundant {
+			picked = append(picked, candidate)
+		}
+	}
+	return picked
+}
+
+// Summarize returns the n sentences that best summarize text, in the order
+// they appear in it
+func Summarize(text string, n int) []string {
+	sentences := candidateSentences([]string{text}, 0)
+	rankSentences(sentences)
+	picked := pickSentences(sentences, n)
+	sort.Slice(picked, func(i, j int) bool {
+		return picked[i].Position < picked[j].Position
+	})
+
+	summary := make([]string, len(picked))
+	for i, sentence := range picked {
+		summary[i] = sentence.Text
+	}
+	return summary
+}
+
+// SummarizeDocuments returns the n sentences that best summarize a set of
+// related texts, such as several reports of one story, best first. Sentences
+// that repeat a better one from another text are left out. Only the lead
+// sentences of each text are considered.
+func SummarizeDocuments(documents []string, n int) []RankedSentence {
+	sentences := candidateSentences(documents, maxLeadSentences)
+	rankSentences(sentences)
+	picked := pickSentences(sentences, n)
+
+	summary := make([]RankedSentence, len(picked))
+	for i, sentence := range picked {
+		summary[i] = sentence.RankedSentence
+	}
+	return summary
+}
@@ -0,0 +1,233 @@
+package nlp
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// Query limits of the vector index: only the strongest terms of a query are
+// looked up, and terms found in more than maxTermShare of the documents are
+// skipped once the index is large, since they say little and cost the most
+const (
+	maxQueryTerms = 24
+	maxTermShare  = 0.1
+	minLargeIndex = 1000
+)
+
+// IndexedDocument is what the vector index keeps about a document besides
+// its postings. Time is a Unix timestamp (0 when unknown) and URLKey
+// identifies its canonical URL.
+type IndexedDocument struct {
+	ID     uint
+	Time   int64
+	URLKey uint64
+}
+
+type indexSlot struct {
+	IndexedDocument
+	features    []uint32
+	fingerprint uint64
+	removed     bool
+}
+
+type posting struct {
+	slot   uint32
+	weight float32
+}
+
+// Neighbor is a document found by the vector index. Score is its cosine
+// similarity over the query terms looked up, which can fall short of the
+// full cosine when common or weak terms were skipped.
+type Neighbor struct {
+	ID    uint
+	Score float64
+}
+
+// VectorIndex is an in-memory inverted index of sparse vectors answering
+// nearest-neighbor queries by cosine similarity. Documents can be added and
+// replaced at any time; replaced slots are reclaimed once they pile up.
+type VectorIndex struct {
+	mu       sync.RWMutex
+	slots    []indexSlot
+	byID     map[uint]uint32
+	postings map[uint32][]posting
+	df       map[uint32]int
+	removed  int
+}
+
+// NewVectorIndex creates an empty index
+func NewVectorIndex() *VectorIndex {
+	return &VectorIndex{
+		byID:     map[uint]uint32{},
+		postings: map[uint32][]posting{},
+		df:       map[uint32]int{},
+	}
+}
+
+// Len is the number of documents in the index
+func (x *VectorIndex) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.byID)
+}
+
+// IDF is the smoothed inverse document frequency of a feature
+func (x *VectorIndex) IDF(feature uint32) float64 {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return math.Log(float64(len(x.byID)+1)/float64(x.df[feature]+1)) + 1
+}
+
+// Add indexes a document's vector, replacing any earlier one. Adding a
+// document again unchanged does nothing.
+func (x *VectorIndex) Add(doc IndexedDocument, vector Vector) {
+	fingerprint := vector.fingerprint()
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if slot, ok := x.byID[doc.ID]; ok && x.slots[slot].IndexedDocument == doc && x.slots[slot].fingerprint == fingerprint {
+		return
+	}
+	x.remove(doc.ID)
+
+	slot := uint32(len(x.slots))
+	features := make([]uint32, len(vector))
+	for i, f := range vector {
+		features[i] = f.Index
+		x.postings[f.Index] = append(x.postings[f.Index], posting{slot: slot, weight: f.Weight})
+		x.df[f.Index]++
+	}
+	x.slots = append(x.slots, indexSlot{IndexedDocument: doc, features: features, fingerprint: fingerprint})
+	x.byID[doc.ID] = slot
+
+	if x.removed > minLargeIndex && x.removed > len(x.byID)/4 {
+		x.compact()
+	}
+}
+
+// Remove drops a document from the index
+func (x *VectorIndex) Remove(id uint) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.remove(id)
+}
+
+func (x *VectorIndex) remove(id uint) {
+	slot, ok := x.byID[id]
+	if !ok {
+		return
+	}
+	for _, feature := range x.slots[slot].features {
+		if x.df[feature]--; x.df[feature] <= 0 {
+			delete(x.df, feature)
+		}
+	}
+	x.slots[slot].removed = true
+	x.slots[slot].features = nil
+	delete(x.byID, id)
+	x.removed++
+}
+
+// compact rebuilds the slots and postings without removed documents
+func (x *VectorIndex) compact() {
+	renumbered := make([]int64, len(x.slots))
+	slots := make([]indexSlot, 0, len(x.byID))
+	for i, slot := range x.slots {
+		renumbered[i] = -1
+		if slot.removed {
+			continue
+		}
+		renumbered[i] = int64(len(slots))
+		x.byID[slot.ID] = uint32(len(slots))
+		slots = append(slots, slot)
+	}
+	for feature, list := range x.postings {
+		kept := list[:0]
+		for _, p := range list {
+			if to := renumbered[p.slot]; to >= 0 {
+				kept = append(kept, posting{slot: uint32(to), weight: p.weight})
+			}
+		}
+		if len(kept) == 0 {
+			delete(x.postings, feature)
+			continue
+		}
+		x.postings[feature] = kept
+	}
+	x.slots = slots
+	x.removed = 0
+}
+
+// Nearest returns up to k documents most similar to vector, best first. The
+// document itself, documents with its URL key and, when window is positive,
+// documents more than window seconds from its time are left out. Only one
+// document per URL key is returned.
+func (x *VectorIndex) Nearest(doc IndexedDocument, vector Vector, k int, window int64) []Neighbor {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	terms := make(Vector, len(vector))
+	copy(terms, vector)
+	sort.Slice(terms, func(i, j int) bool {
+		return terms[i].Weight > terms[j].Weight
+	})
+	if len(terms) > maxQueryTerms {
+		terms = terms[:maxQueryTerms]
+	}
+	maxDF := len(x.byID)
+	if maxDF > minLargeIndex {
+		maxDF = int(float64(maxDF) * maxTermShare)
+	}
+
+	scores := map[uint32]float64{}
+	for _, term := range terms {
+		list := x.postings[term.Index]
+		if x.df[term.Index] > maxDF {
+			continue
+		}
+		for _, p := range list {
+			slot := &x.slots[p.slot]
+			if slot.removed || slot.ID == doc.ID || (doc.URLKey != 0 && slot.URLKey == doc.URLKey) {
+				continue
+			}
+			if window > 0 && doc.Time != 0 && slot.Time != 0 && abs64(slot.Time-doc.Time) > window {
+				continue
+			}
+			scores[p.slot] += float64(term.Weight) * float64(p.weight)
+		}
+	}
+
+	candidates := make([]uint32, 0, len(scores))
+	for slot := range scores {
+		candidates = append(candidates, slot)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if scores[candidates[i]] != scores[candidates[j]] {
+			return scores[candidates[i]] > scores[candidates[j]]
+		}
+		return x.slots[candidates[i]].ID > x.slots[candidates[j]].ID
+	})
+
+	neighbors := []Neighbor{}
+	seen := map[uint64]bool{}
+	for _, slot := range candidates {
+		if len(neighbors) == k {
+			break
+		}
+		if key := x.slots[slot].URLKey; key != 0 {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		neighbors = append(neighbors, Neighbor{ID: x.slots[slot].ID, Score: scores[slot]})
+	}
+	return neighbors
+}
+
+func abs64(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,135 @@
+package nlp
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math"
+	"sort"
+)
+
+// VectorDimensions is the number of hashed features words are mapped to. Two
+// words sharing a feature is rare enough at this size to be harmless.
+const VectorDimensions = 1 << 20
+
+// MaxVectorTerms caps the features kept per vector; the weakest are dropped
+const MaxVectorTerms = 48
+
+// Feature is one non-zero entry of a sparse vector
+type Feature struct {
+	Index  uint32
+	Weight float32
+}
+
+// Vector is a sparse, unit-length TF-IDF vector sorted by feature index
+type Vector []Feature
+
+// featureIndex hashes a word to its feature
+func featureIndex(word string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(word))
+	return h.Sum32() % VectorDimensions
+}
+
+// TermCounts counts the hashed features of the content words in text
+func TermCounts(text string) map[uint32]int {
+	counts := map[uint32]int{}
+	for _, token := range classifierTokens(text) {
+		counts[featureIndex(token)]++
+	}
+	return counts
+}
+
+// WeightVector turns term counts into a unit-length TF-IDF vector of at most
+// MaxVectorTerms features, weighting each by (1 + log tf) * idf
+func WeightVector(counts map[uint32]int, idf func(feature uint32) float64) Vector {
+	vector := make(Vector, 0, len(counts))
+	for feature, count := range counts {
+		weight := (1 + math.Log(float64(count))) * idf(feature)
+		if weight > 0 {
+			vector = append(vector, Feature{Index: feature, Weight: float32(weight)})
+		}
+	}
+	if len(vector) > MaxVectorTerms {
+		sort.Slice(vector, func(i, j int) bool {
+			return vector[i].Weight > vector[j].Weight
+		})
+		vector = vector[:MaxVectorTerms]
+	}
+
+	norm := 0.0
+	for _, f := range vector {
+		norm += float64(f.Weight) * float64(f.Weight)
+	}
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i].Weight = float32(float64(vector[i].Weight) / norm)
+	}
+	sort.Slice(vector, func(i, j int) bool {
+		return vector[i].Index < vector[j].Index
+	})
+	return vector
+}
+
+// Cosine is the cosine similarity of two unit-length vectors
+func (v Vector) Cosine(other Vector) float64 {
+	sum := 0.0
+	for i, j := 0, 0; i < len(v) && j < len(other); {
+		switch {
+		case v[i].Index < other[j].Index:
+			i++
+		case v[i].Index > other[j].Index:
+			j++
+		default:
+			sum += float64(v[i].Weight) * float64(other[j].Weight)
+			i++
+			j++
+		}
+	}
+	return sum
+}
+
+// fingerprint hashes the features and weights of the vector
+func (v Vector) fingerprint() uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	for _, f := range v {
+		binary.LittleEndian.PutUint32(buf[:4], f.Index)
+		binary.LittleEndian.PutUint32(buf[4:], math.Float32bits(f.Weight))
+		h.Write(buf[:])
+	}
+	return h.Sum64()
+}
+
+// MarshalBinary encodes the vector compactly: each feature is the varint gap
+// from the previous index followed by its weight quantized to 16 bits
+func (v Vector) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 0, len(v)*5)
+	var previous uint32
+	for _, f := range v {
+		data = binary.AppendUvarint(data, uint64(f.Index-previous))
+		data = binary.LittleEndian.AppendUint16(data, uint16(math.Round(float64(f.Weight)*math.MaxUint16)))
+		previous = f.Index
+	}
+	return data, nil
+}
+
+var errBadVector = errors.New("malformed vector encoding")
+
+// UnmarshalBinary decodes a vector encoded by MarshalBinary
+func (v *Vector) UnmarshalBinary(data []byte) error {
+	vector := Vector{}
+	var previous uint32
+	for len(data) > 0 {
+		gap, n := binary.Uvarint(data)
+		if n <= 0 || len(data) < n+2 {
+			return errBadVector
+		}
+		previous += uint32(gap)
+		weight := float32(binary.LittleEndian.Uint16(data[n:])) / math.MaxUint16
+		vector = append(vector, Feature{Index: previous, Weight: weight})
+		data = data[n+2:]
+	}
+	*v = vector
+	return nil
+}
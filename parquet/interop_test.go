@@ -0,0 +1,128 @@
+package parquet
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	pq "github.com/parquet-go/parquet-go"
+)
+
+// Files must be readable by other Parquet implementations, so they are read
+// back with parquet-go rather than with the test's own decoder
+func TestWriterInterop(t *testing.T) {
+	columns := []Column{
+		{Name: "id", Type: Int64},
+		{Name: "title", Type: String},
+		{Name: "published_at", Type: Timestamp, Optional: true},
+		{Name: "score", Type: Double, Optional: true},
+		{Name: "note", Type: String, Optional: true},
+	}
+	published := time.Date(2024, 5, 1, 6, 30, 0, 0, time.UTC)
+	var rows [][]interface{}
+	for i := 0; i < 25; i++ {
+		row := []interface{}{int64(i), fmt.Sprintf("Titre n°%d", i), nil, nil, nil}
+		if i%2 == 0 {
+			row[2] = published.Add(time.Duration(i) * time.Hour)
+		}
+		if i%3 == 0 {
+			row[3] = float64(i) / 4
+		}
+		if i == 7 {
+			row[1] = ""
+		}
+		rows = append(rows, row)
+	}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, columns, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	file, err := pq.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("parquet-go cannot open the file: %v", err)
+	}
+	if file.NumRows() != int64(len(rows)) {
+		t.Errorf("num rows = %d, want %d", file.NumRows(), len(rows))
+	}
+	if n := len(file.RowGroups()); n != 3 {
+		t.Errorf("%d row groups, want 3", n)
+	}
+	fields := file.Schema().Fields()
+	if len(fields) != len(columns) {
+		t.Fatalf("schema has %d fields, want %d", len(fields), len(columns))
+	}
+	for i, column := range columns {
+		field := fields[i]
+		if field.Name() != column.Name || field.Optional() != column.Optional {
+			t.Errorf("field %d = %s optional %v, want %s optional %v", i, field.Name(), field.Optional(), column.Name, column.Optional)
+		}
+	}
+	if logical := fields[1].Type().LogicalType(); logical == nil || logical.UTF8 == nil {
+		t.Errorf("title logical type = %v, want STRING", logical)
+	}
+	if logical := fields[2].Type().LogicalType(); logical == nil || logical.Timestamp == nil ||
+		logical.Timestamp.Unit.Millis == nil || !logical.Timestamp.IsAdjustedToUTC {
+		t.Errorf("published_at logical type = %v, want TIMESTAMP(MILLIS, UTC)", logical)
+	}
+
+	reader := pq.NewReader(file)
+	defer reader.Close()
+	read := make([]pq.Row, 0, len(rows))
+	for {
+		row, err := readRow(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ReadRow: %v", err)
+		}
+		read = append(read, row)
+	}
+	if len(read) != len(rows) {
+		t.Fatalf("read %d rows, want %d", len(read), len(rows))
+	}
+	for i, row := range rows {
+		got := read[i]
+		if got[0].Int64() != row[0] {
+			t.Errorf("row %d: id = %d, want %v", i, got[0].Int64(), row[0])
+		}
+		if got[1].String() != row[1] {
+			t.Errorf("row %d: title = %q, want %q", i, got[1].String(), row[1])
+		}
+		if want, ok := row[2].(time.Time); ok != !got[2].IsNull() || ok && got[2].Int64() != want.UnixMilli() {
+			t.Errorf("row %d: published_at = %v, want %v", i, got[2], row[2])
+		}
+		if want, ok := row[3].(float64); ok != !got[3].IsNull() || ok && got[3].Double() != want {
+			t.Errorf("row %d: score = %v, want %v", i, got[3], row[3])
+		}
+		if !got[4].IsNull() {
+			t.Errorf("row %d: note = %v, want null", i, got[4])
+		}
+	}
+}
+
+// readRow reads the next row, or returns io.EOF after the last one
+func readRow(reader *pq.Reader) (pq.Row, error) {
+	rows := []pq.Row{nil}
+	n, err := reader.ReadRows(rows)
+	if n == 1 {
+		return rows[0], nil
+	}
+	if err == nil {
+		err = io.EOF
+	}
+	return nil, err
+}
@@ -0,0 +1,331 @@
+// Package parquet writes flat tables as Apache Parquet files. It supports the
+// few column types the exports need, PLAIN encoded and uncompressed, which
+// every Parquet reader understands.
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Type is the type of a column's values
+type Type int
+
+// Column types. Timestamp columns hold UTC milliseconds.
+const (
+	Int64 Type = iota
+	Double
+	String
+	Timestamp
+)
+
+// Column describes a column. Optional columns accept nil values.
+type Column struct {
+	Name     string
+	Type     Type
+	Optional bool
+}
+
+// Parquet physical types, repetitions, encodings and converted types used
+const (
+	physicalInt64     = 2
+	physicalDouble    = 5
+	physicalByteArray = 6
+
+	repetitionRequired = 0
+	repetitionOptional = 1
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	convertedUTF8            = 0
+	convertedTimestampMillis = 9
+)
+
+var magic = []byte("PAR1")
+
+// DefaultRowGroupSize is the number of rows buffered before a row group is
+// written
+const DefaultRowGroupSize = 10000
+
+// columnChunk buffers a column's values in the current row group
+type columnChunk struct {
+	values  bytes.Buffer
+	defined []bool
+}
+
+// chunkMeta is what the footer records of a written column chunk
+type chunkMeta struct {
+	offset int64
+	size   int64
+	values int64
+}
+
+type rowGroupMeta struct {
+	rows    int64
+	size    int64
+	columns []chunkMeta
+}
+
+// Writer writes rows to a Parquet file. Rows are buffered in memory one row
+// group at a time; the file itself is written sequentially, so w can be a
+// network stream.
+type Writer struct {
+	w            io.Writer
+	offset       int64
+	columns      []Column
+	chunks       []columnChunk
+	rows         int
+	rowGroupSize int
+	rowGroups    []rowGroupMeta
+	createdBy    string
+}
+
+// NewWriter starts a Parquet file with the given columns. rowGroupSize is
+// the number of rows per row group, DefaultRowGroupSize if not positive.
+func NewWriter(w io.Writer, columns []Column, rowGroupSize int) (*Writer, error) {
+	if rowGroupSize <= 0 {
+		rowGroupSize = DefaultRowGroupSize
+	}
+	pw := &Writer{w: w, columns: columns, chunks: make([]columnChunk, len(columns)), rowGroupSize: rowGroupSize, createdBy: "go_news_api"}
+	if err := pw.write(magic); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+func (pw *Writer) write(b []byte) error {
+	n, err := pw.w.Write(b)
+	pw.offset += int64(n)
+	return err
+}
+
+// Write adds a row, one value per column: int64, float64, string or
+// time.Time depending on the column type, or nil in optional columns
+func (pw *Writer) Write(row []interface{}) error {
+	if len(row) != len(pw.columns) {
+		return fmt.Errorf("parquet: row has %d values for %d columns", len(row), len(pw.columns))
+	}
+	for i, value := range row {
+		column := &pw.columns[i]
+		chunk := &pw.chunks[i]
+		if value == nil {
+			if !column.Optional {
+				return fmt.Errorf("parquet: column %s is required", column.Name)
+			}
+			chunk.defined = append(chunk.defined, false)
+			continue
+		}
+		var b [8]byte
+		switch v := value.(type) {
+		case int64:
+			if column.Type != Int64 {
+				return fmt.Errorf("parquet: int64 value for column %s", column.Name)
+			}
+			binary.LittleEndian.PutUint64(b[:], uint64(v))
+			chunk.values.Write(b[:])
+		case float64:
+			if column.Type != Double {
+				return fmt.Errorf("parquet: float64 value for column %s", column.Name)
+			}
+			binary.LittleEndian.PutUint64(b[:], math.Float64bits(v))
+			chunk.values.Write(b[:])
+		case string:
+			if column.Type != String {
+				return fmt.Errorf("parquet: string value for column %s", column.Name)
+			}
+			binary.LittleEndian.PutUint32(b[:4], uint32(len(v)))
+			chunk.values.Write(b[:4])
+			chunk.values.WriteString(v)
+		case time.Time:
+			if column.Type != Timestamp {
+				return fmt.Errorf("parquet: time value for column %s", column.Name)
+			}
+			binary.LittleEndian.PutUint64(b[:], uint64(v.UnixMilli()))
+			chunk.values.Write(b[:])
+		default:
+			return fmt.Errorf("parquet: unsupported value %T for column %s", value, column.Name)
+		}
+		chunk.defined = append(chunk.defined, true)
+	}
+	pw.rows++
+	if pw.rows >= pw.rowGroupSize {
+		return pw.flush()
+	}
+	return nil
+}
+
+// definitionLevels encodes which values of an optional column are set, with
+// the RLE/bit-packing hybrid encoding at bit width 1, length prefixed
+func definitionLevels(defined []bool) []byte {
+	var runs bytes.Buffer
+	var b [binary.MaxVarintLen64]byte
+	for i := 0; i < len(defined); {
+		j := i
+		for j < len(defined) && defined[j] == defined[i] {
+			j++
+		}
+		runs.Write(b[:binary.PutUvarint(b[:], uint64(j-i)<<1)])
+		if defined[i] {
+			runs.WriteByte(1)
+		} else {
+			runs.WriteByte(0)
+		}
+		i = j
+	}
+	out := make([]byte, 4, 4+runs.Len())
+	binary.LittleEndian.PutUint32(out, uint32(runs.Len()))
+	return append(out, runs.Bytes()...)
+}
+
+// flush writes the buffered rows as a row group of one data page per column
+func (pw *Writer) flush() error {
+	if pw.rows == 0 {
+		return nil
+	}
+	group := rowGroupMeta{rows: int64(pw.rows)}
+	for i := range pw.columns {
+		chunk := &pw.chunks[i]
+		var page []byte
+		if pw.columns[i].Optional {
+			page = definitionLevels(chunk.defined)
+		}
+		page = append(page, chunk.values.Bytes()...)
+
+		header := thriftWriter{}
+		header.begin()
+		header.i32(1, 0) // DATA_PAGE
+		header.i32(2, int32(len(page)))
+		header.i32(3, int32(len(page)))
+		header.structField(5)
+		header.i32(1, int32(pw.rows))
+		header.i32(2, encodingPlain)
+		header.i32(3, encodingRLE)
+		header.i32(4, encodingRLE)
+		header.end()
+		header.end()
+
+		meta := chunkMeta{offset: pw.offset, size: int64(header.buf.Len() + len(page)), values: int64(pw.rows)}
+		if err := pw.write(header.buf.Bytes()); err != nil {
+			return err
+		}
+		if err := pw.write(page); err != nil {
+			return err
+		}
+		group.columns = append(group.columns, meta)
+		group.size += meta.size
+
+		chunk.values.Reset()
+		chunk.defined = chunk.defined[:0]
+	}
+	pw.rowGroups = append(pw.rowGroups, group)
+	pw.rows = 0
+	return nil
+}
+
+func physicalType(t Type) int32 {
+	switch t {
+	case Double:
+		return physicalDouble
+	case String:
+		return physicalByteArray
+	default:
+		return physicalInt64
+	}
+}
+
+// Close writes the remaining rows and the file footer. It does not close the
+// underlying writer.
+func (pw *Writer) Close() error {
+	if err := pw.flush(); err != nil {
+		return err
+	}
+
+	var rows int64
+	for _, group := range pw.rowGroups {
+		rows += group.rows
+	}
+
+	t := thriftWriter{}
+	t.begin()
+	t.i32(1, 1)
+	t.list(2, thriftStruct, len(pw.columns)+1)
+	t.begin()
+	t.string(4, "schema")
+	t.i32(5, int32(len(pw.columns)))
+	t.end()
+	for _, column := range pw.columns {
+		t.begin()
+		t.i32(1, physicalType(column.Type))
+		if column.Optional {
+			t.i32(3, repetitionOptional)
+		} else {
+			t.i32(3, repetitionRequired)
+		}
+		t.string(4, column.Name)
+		switch column.Type {
+		case String:
+			t.i32(6, convertedUTF8)
+			t.structField(10)
+			t.structField(1) // STRING
+			t.end()
+			t.end()
+		case Timestamp:
+			t.i32(6, convertedTimestampMillis)
+			t.structField(10)
+			t.structField(8) // TIMESTAMP
+			t.bool(1, true)
+			t.structField(2)
+			t.structField(1) // MILLIS
+			t.end()
+			t.end()
+			t.end()
+			t.end()
+		}
+		t.end()
+	}
+	t.i64(3, rows)
+	t.list(4, thriftStruct, len(pw.rowGroups))
+	for _, group := range pw.rowGroups {
+		t.begin()
+		t.list(1, thriftStruct, len(group.columns))
+		for i, chunk := range group.columns {
+			column := pw.columns[i]
+			t.begin()
+			t.i64(2, chunk.offset)
+			t.structField(3)
+			t.i32(1, physicalType(column.Type))
+			t.list(2, thriftI32, 2)
+			t.i32Element(encodingPlain)
+			t.i32Element(encodingRLE)
+			t.list(3, thriftBinary, 1)
+			t.stringElement(column.Name)
+			t.i32(4, 0) // UNCOMPRESSED
+			t.i64(5, chunk.values)
+			t.i64(6, chunk.size)
+			t.i64(7, chunk.size)
+			t.i64(9, chunk.offset)
+			t.end()
+			t.end()
+		}
+		t.i64(2, group.size)
+		t.i64(3, group.rows)
+		t.end()
+	}
+	t.string(6, pw.createdBy)
+	t.end()
+
+	if err := pw.write(t.buf.Bytes()); err != nil {
+		return err
+	}
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(t.buf.Len()))
+	if err := pw.write(length[:]); err != nil {
+		return err
+	}
+	return pw.write(magic)
+}
@@ -0,0 +1,205 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// thriftReader decodes Thrift compact structs into maps of field id to value
+// so the tests can check what the writer produced
+type thriftReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *thriftReader) byte() byte {
+	b := r.b[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *thriftReader) varint() uint64 {
+	v, n := binary.Uvarint(r.b[r.pos:])
+	if n <= 0 {
+		panic(fmt.Sprintf("bad varint at %d", r.pos))
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) zigzag() int64 {
+	v := r.varint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) value(kind byte) interface{} {
+	switch kind {
+	case thriftTrue:
+		return true
+	case thriftFalse:
+		return false
+	case thriftI32, thriftI64:
+		return r.zigzag()
+	case thriftBinary:
+		n := int(r.varint())
+		s := string(r.b[r.pos : r.pos+n])
+		r.pos += n
+		return s
+	case thriftList:
+		header := r.byte()
+		size := int(header >> 4)
+		if size == 15 {
+			size = int(r.varint())
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = r.value(header & 0x0f)
+		}
+		return list
+	case thriftStruct:
+		return r.readStruct()
+	}
+	panic(fmt.Sprintf("unsupported thrift type %d at %d", kind, r.pos))
+}
+
+func (r *thriftReader) readStruct() map[int16]interface{} {
+	fields := map[int16]interface{}{}
+	var id int16
+	for {
+		header := r.byte()
+		if header == 0 {
+			return fields
+		}
+		if delta := int16(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(r.zigzag())
+		}
+		fields[id] = r.value(header & 0x0f)
+	}
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	columns := []Column{
+		{Name: "id", Type: Int64},
+		{Name: "title", Type: String},
+		{Name: "published_at", Type: Timestamp, Optional: true},
+		{Name: "score", Type: Double, Optional: true},
+	}
+	published := time.Date(2024, 5, 1, 6, 30, 0, 0, time.UTC)
+	rows := [][]interface{}{
+		{int64(1), "First", published, 0.5},
+		{int64(2), "Second", nil, nil},
+		{int64(3), "Third", published, 1.5},
+		{int64(4), "Fourth", nil, 2.5},
+		{int64(5), "Fifth", published, nil},
+	}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, columns, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	file := buf.Bytes()
+
+	if !bytes.HasPrefix(file, magic) || !bytes.HasSuffix(file, magic) {
+		t.Fatalf("file does not start and end with %q", magic)
+	}
+	footerLength := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	footerStart := len(file) - 8 - footerLength
+	if footerStart < len(magic) {
+		t.Fatalf("footer length %d does not fit a %d byte file", footerLength, len(file))
+	}
+	r := &thriftReader{b: file[:len(file)-8], pos: footerStart}
+	meta := r.readStruct()
+	if r.pos != len(file)-8 {
+		t.Errorf("footer metadata ends at %d, want %d", r.pos, len(file)-8)
+	}
+
+	if meta[3] != int64(len(rows)) {
+		t.Errorf("num_rows = %v, want %d", meta[3], len(rows))
+	}
+	schema := meta[2].([]interface{})
+	if len(schema) != len(columns)+1 {
+		t.Fatalf("schema has %d elements, want %d", len(schema), len(columns)+1)
+	}
+	if root := schema[0].(map[int16]interface{}); root[5] != int64(len(columns)) {
+		t.Errorf("root num_children = %v, want %d", root[5], len(columns))
+	}
+	for i, column := range columns {
+		if name := schema[i+1].(map[int16]interface{})[4]; name != column.Name {
+			t.Errorf("schema column %d = %v, want %s", i, name, column.Name)
+		}
+	}
+
+	rowGroups := meta[4].([]interface{})
+	if len(rowGroups) != 3 {
+		t.Fatalf("%d row groups, want 3", len(rowGroups))
+	}
+	var ids []int64
+	var titles []string
+	var groupRows int64
+	for _, g := range rowGroups {
+		group := g.(map[int16]interface{})
+		groupRows += group[3].(int64)
+		chunks := group[1].([]interface{})
+		if len(chunks) != len(columns) {
+			t.Fatalf("row group has %d column chunks, want %d", len(chunks), len(columns))
+		}
+
+		// Read the id and title pages back
+		for i := 0; i < 2; i++ {
+			chunk := chunks[i].(map[int16]interface{})[3].(map[int16]interface{})
+			page := &thriftReader{b: file, pos: int(chunk[9].(int64))}
+			header := page.readStruct()
+			dataPage := header[5].(map[int16]interface{})
+			if dataPage[1] != group[3] {
+				t.Errorf("page num_values = %v, want %v", dataPage[1], group[3])
+			}
+			values := file[page.pos : page.pos+int(header[3].(int64))]
+			for len(values) > 0 {
+				if i == 0 {
+					ids = append(ids, int64(binary.LittleEndian.Uint64(values)))
+					values = values[8:]
+				} else {
+					n := int(binary.LittleEndian.Uint32(values))
+					titles = append(titles, string(values[4:4+n]))
+					values = values[4+n:]
+				}
+			}
+		}
+	}
+	if groupRows != int64(len(rows)) {
+		t.Errorf("row groups hold %d rows, want %d", groupRows, len(rows))
+	}
+	if fmt.Sprint(ids) != "[1 2 3 4 5]" {
+		t.Errorf("ids = %v", ids)
+	}
+	if fmt.Sprint(titles) != "[First Second Third Fourth Fifth]" {
+		t.Errorf("titles = %v", titles)
+	}
+}
+
+func TestWriterRejectsNilInRequiredColumn(t *testing.T) {
+	w, err := NewWriter(&bytes.Buffer{}, []Column{{Name: "id", Type: Int64}}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write([]interface{}{nil}); err == nil {
+		t.Error("a nil id was accepted")
+	}
+	if err := w.Write([]interface{}{"1"}); err == nil {
+		t.Error("a string id was accepted")
+	}
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GetEnvDuration reads a duration such as "10s" or "1m30s" from the environment,
+// falling back to def when the variable is unset or invalid.
+func GetEnvDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Warning: invalid duration %q for %s, using %s", value, key, def)
+		return def
+	}
+	return d
+}
+
+// GetEnvInt reads an integer from the environment, falling back to def when the
+// variable is unset or invalid.
+func GetEnvInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Warning: invalid integer %q for %s, using %d", value, key, def)
+		return def
+	}
+	return n
+}
+
+// GetEnvList reads a comma-separated list from the environment, trimming
+// whitespace and dropping empty entries
+func GetEnvList(key string, def []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// publishedAtLayouts are the date formats seen in provider payloads, RSS
+// feeds and page metadata. Layouts without a zone are read as UTC.
+var publishedAtLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04 -0700",
+	"Mon, 2 Jan 2006 15:04 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	time.RFC850,
+	time.RFC822Z,
+	time.RFC822,
+	time.ANSIC,
+	time.UnixDate,
+	"January 2, 2006 15:04",
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+	"2006-01-02",
+	"2006/01/02",
+	"20060102",
+}
+
+// rfc822Zones are the offsets of the North American zone names RFC 822
+// allows. time.Parse only knows the names of the local zone and reads any
+// other name as UTC.
+var rfc822Zones = map[string]int{
+	"EST": -5 * 60 * 60,
+	"EDT": -4 * 60 * 60,
+	"CST": -6 * 60 * 60,
+	"CDT": -5 * 60 * 60,
+	"MST": -7 * 60 * 60,
+	"MDT": -6 * 60 * 60,
+	"PST": -8 * 60 * 60,
+	"PDT": -7 * 60 * 60,
+}
+
+// resolveZone applies the offset of an RFC 822 zone name. It reports false
+// for any other name time.Parse could not resolve, since reading it as UTC
+// would give a wrong instant.
+func resolveZone(t time.Time) (time.Time, bool) {
+	name, offset := t.Zone()
+	if zoneOffset, ok := rfc822Zones[name]; ok {
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.FixedZone(name, zoneOffset)), true
+	}
+	if offset == 0 && name != "" && name != "UTC" && name != "GMT" {
+		return t, false
+	}
+	return t, true
+}
+
+// dateOnlyLayouts are the layouts of publishedAtLayouts without a time of day
+var dateOnlyLayouts = []string{
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+	"2006-01-02",
+	"2006/01/02",
+	"20060102",
+}
+
+// IsDateOnly reports whether value is a date without a time of day
+func IsDateOnly(value string) bool {
+	value = strings.TrimSpace(value)
+	for _, layout := range dateOnlyLayouts {
+		if _, err := time.Parse(layout, value); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ParsePublishedAt parses a publish date in any of the formats providers and
+// pages use, including Unix timestamps. It returns nil when the value cannot
+// be parsed, or when it names a zone other than UTC, GMT, an RFC 822 zone or
+// the local one. The result is always in UTC.
+func ParsePublishedAt(value string) *time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+
+	// Some feeds spell out the zone name in a form Go does not know
+	if strings.HasSuffix(value, " UT") {
+		value = strings.TrimSuffix(value, " UT") + " +0000"
+	}
+	value = strings.Replace(value, " GMT+0000", " +0000", 1)
+
+	for _, layout := range publishedAtLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			t, ok := resolveZone(t)
+			if !ok {
+				return nil
+			}
+			t = t.UTC()
+			return &t
+		}
+	}
+
+	// Unix timestamps, in seconds or milliseconds
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil && len(value) >= 9 {
+		var t time.Time
+		if n > 1e12 {
+			t = time.UnixMilli(n).UTC()
+		} else {
+			t = time.Unix(n, 0).UTC()
+		}
+		return &t
+	}
+	return nil
+}
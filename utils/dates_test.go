@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePublishedAt(t *testing.T) {
+	want := time.Date(2024, 5, 1, 11, 30, 0, 0, time.UTC)
+	tests := []string{
+		"2024-05-01T11:30:00Z",
+		"2024-05-01T13:30:00+02:00",
+		"2024-05-01 11:30:00",
+		"Wed, 01 May 2024 11:30:00 GMT",
+		"Wed, 01 May 2024 11:30:00 +0000",
+		"Wed, 1 May 2024 11:30:00 UT",
+		"Wed, 01 May 2024 06:30:00 EST",
+		"Wed, 01 May 2024 07:30:00 EDT",
+		"Wed, 01 May 2024 04:30:00 PDT",
+		"Wed, 01 May 2024 03:30:00 PST",
+		"2024-05-01 05:30:00 MDT",
+		"Wed May  1 06:30:00 CDT 2024",
+		"1714563000",
+		"1714563000000",
+	}
+	for _, value := range tests {
+		got := ParsePublishedAt(value)
+		if got == nil {
+			t.Errorf("ParsePublishedAt(%q) = nil, want %s", value, want)
+		} else if !got.Equal(want) || got.Location() != time.UTC {
+			t.Errorf("ParsePublishedAt(%q) = %s, want %s", value, got, want)
+		}
+	}
+}
+
+func TestParsePublishedAtRejects(t *testing.T) {
+	for _, value := range []string{"", "yesterday", "Wed, 01 May 2024 11:30:00 XYZ"} {
+		if got := ParsePublishedAt(value); got != nil {
+			t.Errorf("ParsePublishedAt(%q) = %s, want nil", value, got)
+		}
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"os"
 	"time"
 
+	"go_news_api/nlp"
+
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -48,7 +50,8 @@ func MigrateDB() error {
                 description TEXT,
                 url TEXT,
                 url_to_image TEXT,
-                published_at TEXT,
+                published_at TIMESTAMP WITH TIME ZONE,
+                published_at_raw TEXT,
                 content TEXT,
                 api_response_id INTEGER
             )
@@ -57,6 +60,10 @@ func MigrateDB() error {
 		}
 	}
 
+	if err := migratePublishedAt(); err != nil {
+		return err
+	}
+
 	// Ensure the articles table has a non-unique index on the URL column
 	if err := DB.Exec("DROP INDEX IF EXISTS idx_articles_url").Error; err != nil {
 		return fmt.Errorf("failed to drop existing index on articles.url: %v", err)
@@ -73,6 +80,11 @@ func MigrateDB() error {
 		&SearchQuery{},
 		&TrendingTopic{},
 		&NewsAPIRequest{},
+		&CacheEntry{},
+		&SourceAlias{},
+		&ClassifierModel{},
+		&KeywordRollup{},
+		&KeywordSourceRollup{},
 	); err != nil {
 		return fmt.Errorf("failed to perform AutoMigrate: %v", err)
 	}
@@ -82,6 +94,207 @@ func MigrateDB() error {
 		return fmt.Errorf("failed to migrate Article model: %v", err)
 	}
 
+	if err := DB.AutoMigrate(&ArticleContent{}, &ArticleEnrichment{}, &ArticleSighting{}, &ArticleRevision{}, &Entity{}, &ArticleEntity{}, &ArticleVector{}, &SavedSearch{}, &SavedSearchMatch{}, &WebhookSubscription{}, &WebhookDelivery{}, &ExportJob{}, &ImportRun{}, &RawPayload{}); err != nil {
+		return fmt.Errorf("failed to migrate article content models: %v", err)
+	}
+
+	// Articles stored before sightings were tracked were first seen when created
+	if err := DB.Exec("UPDATE articles SET first_seen_at = created_at, last_seen_at = updated_at WHERE first_seen_at IS NULL").Error; err != nil {
+		return fmt.Errorf("failed to backfill article seen timestamps: %v", err)
+	}
+
+	if err := backfillSourceDomains(); err != nil {
+		return err
+	}
+
+	if err := backfillArticleLanguages(); err != nil {
+		return err
+	}
+
+	if err := backfillProviderCategories(); err != nil {
+		return err
+	}
+
+	if err := backfillKeywordRollups(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// keywordBucketSQL is the hour (UTC) an article is counted in by the keyword
+// rollups: when it was published, or first seen when the date is unknown
+const keywordBucketSQL = "date_trunc('hour', COALESCE(articles.published_at, articles.first_seen_at, articles.created_at) AT TIME ZONE 'UTC') AT TIME ZONE 'UTC'"
+
+// RebuildKeywordRollups recomputes the keyword rollups from article_keywords.
+// Ingestion keeps them up to date; rebuilding catches up with deleted articles
+// and changed publish dates.
+func RebuildKeywordRollups() error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM keyword_rollups").Error; err != nil {
+			return fmt.Errorf("failed to clear keyword rollups: %v", err)
+		}
+		if err := tx.Exec("DELETE FROM keyword_source_rollups").Error; err != nil {
+			return fmt.Errorf("failed to clear keyword source rollups: %v", err)
+		}
+		if err := tx.Exec(`
+            INSERT INTO keyword_rollups (keyword_id, bucket, articles)
+            SELECT article_keywords.keyword_id, ` + keywordBucketSQL + ` AS hour, COUNT(*)
+            FROM article_keywords
+            JOIN articles ON articles.id = article_keywords.article_id AND articles.deleted_at IS NULL
+            GROUP BY article_keywords.keyword_id, hour
+        `).Error; err != nil {
+			return fmt.Errorf("failed to rebuild keyword rollups: %v", err)
+		}
+		if err := tx.Exec(`
+            INSERT INTO keyword_source_rollups (keyword_id, bucket, source_id)
+            SELECT DISTINCT article_keywords.keyword_id, ` + keywordBucketSQL + `, articles.source_id
+            FROM article_keywords
+            JOIN articles ON articles.id = article_keywords.article_id AND articles.deleted_at IS NULL
+            WHERE articles.source_id IS NOT NULL AND articles.source_id <> 0
+        `).Error; err != nil {
+			return fmt.Errorf("failed to rebuild keyword source rollups: %v", err)
+		}
+		return nil
+	})
+}
+
+// backfillKeywordRollups builds the keyword rollups once for keywords saved
+// before they existed
+func backfillKeywordRollups() error {
+	var rolledUp bool
+	if err := DB.Raw("SELECT EXISTS (SELECT 1 FROM keyword_rollups)").Scan(&rolledUp).Error; err != nil {
+		return fmt.Errorf("failed to check keyword rollups: %v", err)
+	}
+	if rolledUp {
+		return nil
+	}
+	return RebuildKeywordRollups()
+}
+
+// backfillProviderCategories labels articles that appeared in a top-headlines
+// fetch with that fetch's category. A specific category wins over "general".
+func backfillProviderCategories() error {
+	err := DB.Exec(`
+		UPDATE articles SET category = s.query, category_confidence = 1, category_source = ?
+		FROM (
+			SELECT DISTINCT ON (article_id) article_id, query
+			FROM article_sightings
+			WHERE query_type = 'category' AND query IN ?
+			ORDER BY article_id, query = 'general', seen_at DESC
+		) s
+		WHERE articles.id = s.article_id AND (articles.category_source IS NULL OR articles.category_source <> ?)`,
+		CategoryFromProvider, nlp.Categories, CategoryFromProvider).Error
+	if err != nil {
+		return fmt.Errorf("failed to backfill article categories: %v", err)
+	}
+	return nil
+}
+
+// backfillSourceDomains keys sources stored before the source catalogue by
+// registrable domain, taken from the source URL or one of its articles.
+// Sources that end up sharing a domain can be merged via the API.
+func backfillSourceDomains() error {
+	var sources []Source
+	if err := DB.Where("domain IS NULL OR domain = ''").Find(&sources).Error; err != nil {
+		return fmt.Errorf("failed to load sources without domain: %v", err)
+	}
+	for _, source := range sources {
+		domain := RegistrableDomain(source.URL)
+		if domain == "" {
+			var articleURL string
+			DB.Model(&Article{}).Select("url").Where("source_id = ? AND url <> ''", source.ID).Limit(1).Scan(&articleURL)
+			domain = RegistrableDomain(articleURL)
+		}
+		if domain == "" {
+			continue
+		}
+		if err := DB.Model(&source).Update("domain", domain).Error; err != nil {
+			return fmt.Errorf("failed to backfill domain of source %d: %v", source.ID, err)
+		}
+	}
+	return nil
+}
+
+// backfillArticleLanguages detects the language of articles stored before
+// language detection. Every provider request so far asked for English, so
+// that is the hint. Articles it cannot place are marked undetermined rather
+// than left empty, so each start does not scan them again.
+func backfillArticleLanguages() error {
+	var lastID uint
+	for {
+		var articles []Article
+		if err := DB.Where("id > ? AND (language IS NULL OR language = '')", lastID).
+			Order("id").Limit(500).Find(&articles).Error; err != nil {
+			return fmt.Errorf("failed to load articles without language: %v", err)
+		}
+		if len(articles) == 0 {
+			return nil
+		}
+		for i := range articles {
+			article := &articles[i]
+			lastID = article.ID
+			DetectArticleLanguage(article, article.Title+"\n"+article.Description+"\n"+article.Content, "en")
+			if err := DB.Model(article).Select("language", "language_confidence", "search_config").Updates(article).Error; err != nil {
+				return fmt.Errorf("failed to backfill language of article %d: %v", article.ID, err)
+			}
+		}
+	}
+}
+
+// migratePublishedAt converts the old text published_at column into a
+// timestamp. The original strings move to published_at_raw and are parsed
+// with ParsePublishedAt, so rows with unparseable dates keep their raw value.
+// The backfill runs in the transaction that swaps the columns, so a crash
+// part way leaves the text column in place to be migrated again.
+func migratePublishedAt() error {
+	var dataType string
+	DB.Raw("SELECT data_type FROM information_schema.columns WHERE table_schema = 'public' AND table_name = 'articles' AND column_name = 'published_at'").Scan(&dataType)
+	if dataType != "text" {
+		return nil
+	}
+
+	type rawDate struct {
+		ID             uint
+		PublishedAtRaw string
+	}
+	parsed, failed := 0, 0
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("ALTER TABLE articles RENAME COLUMN published_at TO published_at_raw").Error; err != nil {
+			return fmt.Errorf("failed to rename articles.published_at: %v", err)
+		}
+		if err := tx.Exec("ALTER TABLE articles ADD COLUMN published_at TIMESTAMP WITH TIME ZONE").Error; err != nil {
+			return fmt.Errorf("failed to add articles.published_at: %v", err)
+		}
+
+		var lastID uint
+		for {
+			var rows []rawDate
+			if err := tx.Table("articles").Select("id, published_at_raw").
+				Where("id > ? AND published_at_raw IS NOT NULL AND published_at_raw <> ''", lastID).
+				Order("id").Limit(1000).Scan(&rows).Error; err != nil {
+				return fmt.Errorf("failed to read publish dates: %v", err)
+			}
+			if len(rows) == 0 {
+				return nil
+			}
+			for _, row := range rows {
+				lastID = row.ID
+				t := ParsePublishedAt(row.PublishedAtRaw)
+				if t == nil {
+					failed++
+					continue
+				}
+				if err := tx.Table("articles").Where("id = ?", row.ID).Update("published_at", *t).Error; err != nil {
+					return fmt.Errorf("failed to backfill published_at for article %d: %v", row.ID, err)
+				}
+				parsed++
+			}
+		}
+	}); err != nil {
+		return err
+	}
+	log.Printf("Migrated articles.published_at to timestamps: %d parsed, %d unparseable", parsed, failed)
 	return nil
 }
 
@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// RegistrableDomain returns the registrable domain (eTLD+1) of a URL or host,
+// e.g. "bbc.co.uk" for "https://www.bbc.co.uk/news". It returns "" when no
+// host can be found.
+func RegistrableDomain(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		return ""
+	}
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return ""
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		// IP addresses and bare suffixes have no registrable domain
+		return strings.TrimPrefix(host, "www.")
+	}
+	return domain
+}
+
+// CanonicalURL reduces an article URL to what identifies the page: host
+// without "www.", path without a trailing slash, and no scheme, query or
+// fragment. It returns the trimmed input when it cannot be parsed.
+func CanonicalURL(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	host := strings.TrimPrefix(strings.TrimSuffix(strings.ToLower(u.Hostname()), "."), "www.")
+	return host + strings.TrimSuffix(u.EscapedPath(), "/")
+}
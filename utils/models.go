@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"time"
 
+	"go_news_api/nlp"
+
 	"gorm.io/gorm"
 )
 
@@ -16,23 +18,56 @@ type APIResponse struct {
 	APISource     string    `json:"api_source"` // "gnews" or "newsapi"
 	Type          string    `json:"type"`       // "category" or "topic"
 	Topic         string    `json:"topic,omitempty"`
+	Country       string    `json:"country,omitempty"`
+	Language      string    `json:"language,omitempty"`
 	Page          int       `json:"page,omitempty"`
 	PerPage       int       `json:"per_page,omitempty"`
 }
 type Article struct {
 	gorm.Model
-	Source        Source `json:"source" gorm:"foreignKey:SourceID"`
-	SourceID      uint
-	Author        string `json:"author"`
-	Title         string `json:"title"`
-	Description   string `json:"description"`
-	URL           string `json:"url" gorm:"index:idx_articles_url,priority:1"`
-	URLToImage    string `json:"urlToImage"`
-	PublishedAt   string `json:"publishedAt"`
-	Content       string `json:"content"`
+	Source      Source `json:"source" gorm:"foreignKey:SourceID"`
+	SourceID    uint
+	Author      string `json:"author"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	URL         string `json:"url" gorm:"index:idx_articles_url,priority:1"`
+	URLToImage  string `json:"urlToImage"`
+	// PublishedAt is parsed from PublishedAtRaw, the value as the provider sent it
+	PublishedAt    *time.Time `json:"publishedAt" gorm:"type:timestamptz;index"`
+	PublishedAtRaw string     `json:"publishedAtRaw,omitempty"`
+	Content        string     `json:"content"`
+	// APIResponseID is the fetch the article was first seen in; every later
+	// appearance is recorded as an ArticleSighting
 	APIResponseID uint
-	Keywords      []Keyword `gorm:"many2many:article_keywords;"`
-	Language      string    `json:"language,omitempty"`
+	FirstSeenAt   *time.Time `json:"first_seen_at,omitempty" gorm:"index"`
+	LastSeenAt    *time.Time `json:"last_seen_at,omitempty" gorm:"index"`
+	// ContentHash fingerprints the provider-supplied fields; see ArticleRevision
+	ContentHash string    `json:"content_hash,omitempty" gorm:"index"`
+	Keywords    []Keyword `gorm:"many2many:article_keywords;"`
+	// Language is the detected ISO 639-1 code, or "und" when it could not be
+	// determined; SearchConfig is the matching Postgres text search
+	// configuration used to index and query the article
+	Language           string  `json:"language,omitempty" gorm:"index"`
+	LanguageConfidence float64 `json:"language_confidence,omitempty"`
+	SearchConfig       string  `json:"search_config,omitempty"`
+	// RequestedCountry and RequestedLanguage are what the fetch that first
+	// found the article asked the provider for
+	RequestedCountry  string `json:"requested_country,omitempty"`
+	RequestedLanguage string `json:"requested_language,omitempty"`
+	// Category is one of the News API categories. CategorySource says whether
+	// a top-headlines fetch supplied it or the classifier guessed it.
+	Category           string  `json:"category,omitempty" gorm:"index"`
+	CategoryConfidence float64 `json:"category_confidence,omitempty"`
+	CategorySource     string  `json:"category_source,omitempty" gorm:"index"`
+	// Summary is an extractive summary of the extracted full text; SummaryHash
+	// fingerprints the text and length it was made from
+	Summary     string `json:"summary,omitempty"`
+	SummaryHash string `json:"-"`
+	// FullContent is the text extracted from the article page, kept apart from
+	// the provider's truncated Content snippet
+	FullContent *ArticleContent `json:"full_content,omitempty" gorm:"foreignKey:ArticleID"`
+	// Enrichment records metadata read from the article page
+	Enrichment *ArticleEnrichment `json:"enrichment,omitempty" gorm:"foreignKey:ArticleID"`
 }
 
 func (a *Article) UnmarshalJSON(data []byte) error {
@@ -44,33 +79,511 @@ func (a *Article) UnmarshalJSON(data []byte) error {
 			Name string      `json:"name"`
 			URL  string      `json:"url"`
 		} `json:"source"`
+		// GNews calls the image "image" rather than "urlToImage"
+		Image       string `json:"image"`
+		PublishedAt string `json:"publishedAt"`
 	}{
 		Alias: (*Alias)(a),
 	}
 	if err := json.Unmarshal(data, &aux); err != nil {
 		return err
 	}
+	if a.URLToImage == "" {
+		a.URLToImage = aux.Image
+	}
+	if a.PublishedAtRaw == "" {
+		a.PublishedAtRaw = aux.PublishedAt
+	}
+	a.PublishedAt = ParsePublishedAt(aux.PublishedAt)
 	a.Source.ExternalID = aux.Source.ID
 	a.Source.Name = aux.Source.Name
 	a.Source.URL = aux.Source.URL
 	return nil
 }
 
+// ArticleSighting records one appearance of an article in a provider
+// response: which fetch, which query or category, and at what rank
+type ArticleSighting struct {
+	gorm.Model
+	ArticleID     uint      `json:"article_id" gorm:"index"`
+	APIResponseID uint      `json:"api_response_id" gorm:"index"`
+	Provider      string    `json:"provider" gorm:"index"`
+	QueryType     string    `json:"query_type"` // "category" or "topic"
+	Query         string    `json:"query"`
+	Country       string    `json:"country,omitempty"`
+	Language      string    `json:"language,omitempty"`
+	Position      int       `json:"position"`
+	SeenAt        time.Time `json:"seen_at" gorm:"index"`
+}
+
+// FieldChange is one field of an article that changed between fetches
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// ArticleRevision records a change to an article's title, description,
+// content, author or image seen when the provider returned it again
+type ArticleRevision struct {
+	gorm.Model
+	ArticleID     uint          `json:"article_id" gorm:"index"`
+	APIResponseID uint          `json:"api_response_id"`
+	PreviousHash  string        `json:"previous_hash"`
+	ContentHash   string        `json:"content_hash"`
+	Changes       []FieldChange `json:"changes" gorm:"type:jsonb;serializer:json"`
+	TitleChanged  bool          `json:"title_changed" gorm:"index"`
+	ChangedAt     time.Time     `json:"changed_at" gorm:"index"`
+}
+
+// HeadlineChange is an entry of the changed headlines feed
+type HeadlineChange struct {
+	RevisionID uint      `json:"revision_id"`
+	ArticleID  uint      `json:"article_id"`
+	URL        string    `json:"url"`
+	Source     string    `json:"source"`
+	OldTitle   string    `json:"old_title"`
+	NewTitle   string    `json:"new_title"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+// SightingQuery summarizes the sightings of an article for one query
+type SightingQuery struct {
+	Provider    string    `json:"provider"`
+	QueryType   string    `json:"query_type"`
+	Query       string    `json:"query"`
+	Sightings   int       `json:"sightings"`
+	BestRank    int       `json:"best_rank"`
+	FirstSeenAt time.Time `json:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+}
+
+// ArticleSightingsResponse is the provenance of an article: every fetch it
+// appeared in and the queries that surfaced it
+type ArticleSightingsResponse struct {
+	ArticleID   uint              `json:"article_id"`
+	FirstSeenAt *time.Time        `json:"first_seen_at"`
+	LastSeenAt  *time.Time        `json:"last_seen_at"`
+	Total       int64             `json:"total"`
+	Queries     []SightingQuery   `json:"queries"`
+	Sightings   []ArticleSighting `json:"sightings"`
+	Page        int               `json:"page"`
+	PerPage     int               `json:"per_page"`
+}
+
+// Values of Article.CategorySource
+const (
+	CategoryFromProvider   = "provider"
+	CategoryFromClassifier = "classifier"
+)
+
+// Extraction states of an ArticleContent. Pending marks a page to extract
+// again because the article changed.
+const (
+	ExtractionPending     = "pending"
+	ExtractionDone        = "done"
+	ExtractionFailed      = "failed"
+	ExtractionBlocked     = "blocked"
+	ExtractionUnsupported = "unsupported"
+)
+
+// ArticleContent holds the full text extracted from an article's page
+type ArticleContent struct {
+	gorm.Model
+	ArticleID           uint       `json:"article_id" gorm:"uniqueIndex"`
+	Status              string     `json:"status" gorm:"index"`
+	FullText            string     `json:"full_text"`
+	WordCount           int        `json:"word_count"`
+	LeadImage           string     `json:"lead_image,omitempty"`
+	DetectedPublishedAt *time.Time `json:"detected_published_at,omitempty"`
+	Error               string     `json:"error,omitempty"`
+	Attempts            int        `json:"attempts"`
+	NextAttemptAt       time.Time  `json:"-"`
+	ExtractedAt         *time.Time `json:"extracted_at,omitempty"`
+}
+
+// ArticleEnrichment holds Open Graph, Twitter Card and JSON-LD metadata read
+// from an article's page. EnrichedFields lists the Article fields (by JSON
+// name, comma-separated) that were filled from it rather than by the provider.
+type ArticleEnrichment struct {
+	gorm.Model
+	ArticleID      uint       `json:"article_id" gorm:"uniqueIndex"`
+	Authors        string     `json:"authors,omitempty"`
+	Section        string     `json:"section,omitempty"`
+	Keywords       string     `json:"keywords,omitempty"`
+	Image          string     `json:"image,omitempty"`
+	PublishedAt    *time.Time `json:"published_at,omitempty"`
+	ModifiedAt     *time.Time `json:"modified_at,omitempty"`
+	EnrichedFields string     `json:"enriched_fields"`
+	EnrichedAt     time.Time  `json:"enriched_at"`
+}
+
+// Source is a publisher in the source catalogue, keyed by its registrable
+// domain. The names and ids providers use for it are kept as SourceAliases.
 type Source struct {
 	gorm.Model
-	ExternalID interface{} `json:"id" gorm:"-"` // Use interface{} to accept both string and int
-	Name       string      `json:"name"`
-	URL        string      `json:"url"`
+	ExternalID  interface{}   `json:"id" gorm:"-"` // Use interface{} to accept both string and int
+	Name        string        `json:"name"`
+	URL         string        `json:"url"`
+	Domain      string        `json:"domain,omitempty" gorm:"index"`
+	Description string        `json:"description,omitempty"`
+	Country     string        `json:"country,omitempty"`
+	Language    string        `json:"language,omitempty"`
+	Category    string        `json:"category,omitempty"`
+	Homepage    string        `json:"homepage,omitempty"`
+	Favicon     string        `json:"favicon,omitempty"`
+	Aliases     []SourceAlias `json:"aliases,omitempty" gorm:"foreignKey:SourceID"`
+}
+
+// Kinds of SourceAlias
+const (
+	AliasID   = "id"
+	AliasName = "name"
+)
+
+// SourceAlias is a name or id under which a provider reports a Source.
+// Value is normalized (lowercase, single spaces) for names.
+type SourceAlias struct {
+	gorm.Model
+	SourceID uint   `json:"source_id" gorm:"index"`
+	Provider string `json:"provider" gorm:"uniqueIndex:idx_source_alias"`
+	Kind     string `json:"kind" gorm:"uniqueIndex:idx_source_alias"`
+	Value    string `json:"value" gorm:"uniqueIndex:idx_source_alias"`
+}
+
+// NewsAPISource is an entry of News API's /v2/top-headlines/sources
+type NewsAPISource struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	URL         string `json:"url"`
+	Category    string `json:"category"`
+	Language    string `json:"language"`
+	Country     string `json:"country"`
+}
+
+type NewsAPISourcesResponse struct {
+	Status  string          `json:"status"`
+	Sources []NewsAPISource `json:"sources"`
 }
 
 type Keyword struct {
 	gorm.Model
-	Word string `json:"word"`
+	Word string `json:"word" gorm:"index"`
+}
+
+// KeywordRollup counts the articles mentioning a keyword that were published
+// in one hour (UTC); day and week series are summed from it
+type KeywordRollup struct {
+	KeywordID uint      `gorm:"primaryKey"`
+	Bucket    time.Time `gorm:"primaryKey;type:timestamptz;index"`
+	Articles  int64
+}
+
+// KeywordSourceRollup records that a source published an article mentioning
+// a keyword in one hour, so distinct sources can be counted over any interval
+type KeywordSourceRollup struct {
+	KeywordID uint      `gorm:"primaryKey"`
+	Bucket    time.Time `gorm:"primaryKey;type:timestamptz"`
+	SourceID  uint      `gorm:"primaryKey"`
+}
+
+// KeywordPoint is the number of articles, and optionally of distinct
+// sources, mentioning a keyword in the interval starting at Bucket
+type KeywordPoint struct {
+	Bucket   time.Time `json:"bucket"`
+	Articles int64     `json:"articles"`
+	Sources  *int64    `json:"sources,omitempty"`
+}
+
+// KeywordSeries is the time series of one keyword
+type KeywordSeries struct {
+	Keyword string         `json:"keyword"`
+	Total   int64          `json:"total"`
+	Points  []KeywordPoint `json:"points"`
+}
+
+// TopKeyword is a keyword's article count in a window and the one before it.
+// Growth is the change relative to the previous window (at least 1).
+type TopKeyword struct {
+	Word             string  `json:"word"`
+	Articles         int64   `json:"articles" gorm:"column:current_articles"`
+	PreviousArticles int64   `json:"previous_articles" gorm:"column:previous_articles"`
+	Growth           float64 `json:"growth"`
+}
+
+// GraphNode is a keyword of the co-occurrence graph and how many articles
+// in the window mention it
+type GraphNode struct {
+	ID       string `json:"id"`
+	Articles int64  `json:"articles"`
+}
+
+// GraphEdge links two keywords mentioned in the same articles. Weight is the
+// chosen association measure of the pair.
+type GraphEdge struct {
+	Source   string  `json:"source"`
+	Target   string  `json:"target"`
+	Articles int64   `json:"articles"`
+	Weight   float64 `json:"weight"`
+}
+
+// KeywordGraph is the keyword co-occurrence graph of the articles published
+// between From and To
+type KeywordGraph struct {
+	From      time.Time   `json:"from"`
+	To        time.Time   `json:"to"`
+	Weighting string      `json:"weighting"`
+	Seed      string      `json:"seed,omitempty"`
+	Articles  int64       `json:"articles"`
+	Nodes     []GraphNode `json:"nodes"`
+	Edges     []GraphEdge `json:"edges"`
+}
+
+// Entity is a person, organization, place or other named thing mentioned in
+// articles. NormalizedName is the lowercased name entities are keyed by.
+type Entity struct {
+	gorm.Model
+	Name           string `json:"name"`
+	NormalizedName string `json:"-" gorm:"uniqueIndex:idx_entity_name_type"`
+	Type           string `json:"type" gorm:"uniqueIndex:idx_entity_name_type;index"`
+}
+
+// ArticleEntity records how often an article mentions an entity
+type ArticleEntity struct {
+	ArticleID uint `json:"article_id" gorm:"primaryKey"`
+	EntityID  uint `json:"entity_id" gorm:"primaryKey;index"`
+	Mentions  int  `json:"mentions"`
+}
+
+// ArticleVector is the encoded TF-IDF vector (nlp.Vector) of an article,
+// from which the related articles index is built
+type ArticleVector struct {
+	ArticleID uint      `gorm:"primaryKey"`
+	Data      []byte    `gorm:"type:bytea"`
+	UpdatedAt time.Time `gorm:"index"`
+}
+
+// RelatedArticle is an article similar to another and its cosine similarity
+type RelatedArticle struct {
+	Score   float64 `json:"score"`
+	Article Article `json:"article"`
+}
+
+// EntitySummary is an entity with how often and when it was mentioned
+type EntitySummary struct {
+	Entity
+	ArticleCount int64      `json:"article_count"`
+	MentionCount int64      `json:"mention_count"`
+	FirstSeenAt  *time.Time `json:"first_seen_at,omitempty"`
+	LastSeenAt   *time.Time `json:"last_seen_at,omitempty"`
+}
+
+// CoMentionedEntity is an entity appearing in the same articles as another
+type CoMentionedEntity struct {
+	Entity
+	SharedArticles int64 `json:"shared_articles"`
+}
+
+// EntityTimelinePoint counts an entity's articles published on one day
+type EntityTimelinePoint struct {
+	Day      string `json:"day"`
+	Articles int64  `json:"articles"`
+}
+
+// SavedSearch is a keyword search kept by its owner, which newly ingested
+// articles are matched against. TSQuery and SearchConfig are derived from
+// Query and Language. Unread counts the matches since LastReadAt.
+type SavedSearch struct {
+	gorm.Model
+	Owner        string     `json:"owner" gorm:"index"`
+	Name         string     `json:"name"`
+	Query        string     `json:"query"`
+	TSQuery      string     `json:"-"`
+	Language     string     `json:"lang,omitempty"`
+	SearchConfig string     `json:"-"`
+	Category     string     `json:"category,omitempty"`
+	SourceDomain string     `json:"source,omitempty"`
+	LastReadAt   *time.Time `json:"last_read_at,omitempty"`
+	Unread       int64      `json:"unread" gorm:"->;-:migration"`
+}
+
+// SavedSearchMatch records a newly ingested article matching a saved search
+type SavedSearchMatch struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	SavedSearchID uint      `json:"saved_search_id" gorm:"uniqueIndex:idx_saved_search_match"`
+	ArticleID     uint      `json:"article_id" gorm:"uniqueIndex:idx_saved_search_match;index"`
+	Article       *Article  `json:"article,omitempty" gorm:"foreignKey:ArticleID"`
+	MatchedAt     time.Time `json:"matched_at" gorm:"index"`
+}
+
+// WebhookSubscription asks for events to be POSTed to URL, signed with
+// Secret. Keyword and SourceDomain, when set, narrow the events sent.
+type WebhookSubscription struct {
+	gorm.Model
+	URL          string   `json:"url"`
+	Secret       string   `json:"secret,omitempty"`
+	EventTypes   []string `json:"event_types" gorm:"type:jsonb;serializer:json"`
+	Keyword      string   `json:"keyword,omitempty"`
+	SourceDomain string   `json:"source,omitempty"`
+	Active       bool     `json:"active" gorm:"index"`
+}
+
+// WebhookDelivery is one event to deliver to one subscription: the outbox
+// row the dispatcher works through and, once settled, its delivery log
+type WebhookDelivery struct {
+	gorm.Model
+	SubscriptionID uint            `json:"subscription_id" gorm:"index"`
+	EventID        string          `json:"event_id" gorm:"index"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload" gorm:"type:jsonb"`
+	Status         string          `json:"status" gorm:"index"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at" gorm:"index"`
+	LastStatusCode int             `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// WebhookEvent is the body POSTed to webhook subscribers
+type WebhookEvent struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// States of a WebhookDelivery. Dead deliveries ran out of attempts.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// ExportedArticle is an article as written by exports, flattened with its
+// source, keywords and provenance: the provider and query of the fetch that
+// first found it and how often it has been seen since
+type ExportedArticle struct {
+	ID                uint       `json:"id"`
+	URL               string     `json:"url"`
+	Title             string     `json:"title"`
+	Description       string     `json:"description"`
+	Author            string     `json:"author"`
+	Content           string     `json:"content"`
+	Summary           string     `json:"summary,omitempty"`
+	FullText          string     `json:"full_text,omitempty"`
+	URLToImage        string     `json:"url_to_image,omitempty"`
+	PublishedAt       *time.Time `json:"published_at"`
+	FirstSeenAt       *time.Time `json:"first_seen_at"`
+	LastSeenAt        *time.Time `json:"last_seen_at"`
+	Language          string     `json:"language"`
+	Category          string     `json:"category"`
+	CategorySource    string     `json:"category_source"`
+	SourceID          *uint      `json:"source_id"`
+	SourceName        string     `json:"source_name"`
+	SourceDomain      string     `json:"source_domain"`
+	Provider          string     `json:"provider"`
+	QueryType         string     `json:"query_type"`
+	Query             string     `json:"query"`
+	RequestedCountry  string     `json:"requested_country,omitempty"`
+	RequestedLanguage string     `json:"requested_language,omitempty"`
+	Sightings         int64      `json:"sightings"`
+	// KeywordList holds the keywords separated by spaces, as selected
+	KeywordList string   `json:"-" gorm:"column:keywords"`
+	Keywords    []string `json:"keywords" gorm:"-"`
+}
+
+// ExportJob is an export too large to stream, written to a file in the
+// background. Query holds the export's query string.
+type ExportJob struct {
+	gorm.Model
+	Format     string     `json:"format"`
+	Query      string     `json:"query"`
+	Status     string     `json:"status" gorm:"index"`
+	Rows       int64      `json:"rows"`
+	Size       int64      `json:"size"`
+	Path       string     `json:"-"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+// States of an ExportJob. The files of expired jobs have been deleted.
+const (
+	ExportQueued  = "queued"
+	ExportRunning = "running"
+	ExportDone    = "done"
+	ExportFailed  = "failed"
+	ExportExpired = "expired"
+)
+
+// ImportRun is a bulk import of a file of articles. The file is identified
+// by its checksum and format, so importing it again resumes an interrupted
+// run or, once done, changes nothing. Processed counts the records already
+// handled, in file order.
+type ImportRun struct {
+	gorm.Model
+	Checksum      string            `json:"checksum" gorm:"uniqueIndex:idx_import_runs_file"`
+	Format        string            `json:"format" gorm:"uniqueIndex:idx_import_runs_file"`
+	Filename      string            `json:"filename,omitempty"`
+	Provider      string            `json:"provider"`
+	Status        string            `json:"status" gorm:"index"`
+	Processed     int               `json:"processed"`
+	Created       int               `json:"created"`
+	Updated       int               `json:"updated"`
+	Rejected      int               `json:"rejected"`
+	Rejections    []ImportRejection `json:"rejections,omitempty" gorm:"type:jsonb;serializer:json"`
+	APIResponseID uint              `json:"api_response_id,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	FinishedAt    *time.Time        `json:"finished_at,omitempty"`
+}
+
+// ImportRejection is a record an import left out, and why. Record numbers
+// start at 1.
+type ImportRejection struct {
+	Record int    `json:"record"`
+	URL    string `json:"url,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// States of an ImportRun. Failed runs resume when the file is imported again.
+const (
+	ImportRunning = "running"
+	ImportDone    = "done"
+	ImportFailed  = "failed"
+)
+
+// RawPayload is an upstream response as it was received, kept so articles
+// can be derived from it again after a mapping fix. The gzipped body is in
+// the payload archive under Key; URL has its credentials removed.
+type RawPayload struct {
+	gorm.Model
+	Provider   string              `json:"provider" gorm:"index"`
+	Method     string              `json:"method"`
+	URL        string              `json:"url"`
+	Endpoint   string              `json:"endpoint" gorm:"index"`
+	StatusCode int                 `json:"status_code"`
+	Header     map[string][]string `json:"header" gorm:"type:jsonb;serializer:json"`
+	Attempt    int                 `json:"attempt"`
+	FetchedAt  time.Time           `json:"fetched_at" gorm:"index"`
+	DurationMS int64               `json:"duration_ms"`
+	Size       int64               `json:"size"`
+	StoredSize int64               `json:"stored_size"`
+	SHA256     string              `json:"sha256" gorm:"index"`
+	Store      string              `json:"store"`
+	Key        string              `json:"key"`
+	// ReprocessedAt is when the payload was last replayed, into the fetch
+	// ReprocessResponseID, which later replays reuse
+	ReprocessedAt       *time.Time `json:"reprocessed_at,omitempty"`
+	ReprocessResponseID uint       `json:"reprocess_response_id,omitempty"`
 }
 
 type SearchQuery struct {
 	gorm.Model
 	Query       string    `json:"query"`
+	Country     string    `json:"country,omitempty"`
+	Language    string    `json:"language,omitempty"`
 	SearchedAt  time.Time `json:"searched_at"`
 	ResultCount int       `json:"result_count"`
 }
@@ -106,9 +619,34 @@ type NewsAPIRequest struct {
 	gorm.Model
 	Topic       string `gorm:"index"`
 	Source      string `gorm:"index"`
+	Language    string `gorm:"index"`
 	RequestedAt time.Time
 }
 
+// CacheEntry is a Postgres-backed cached upstream response. Body holds the
+// rendered JSON so that hits are served byte-for-byte.
+type CacheEntry struct {
+	gorm.Model
+	Key        string    `json:"key" gorm:"uniqueIndex"`
+	Provider   string    `json:"provider"`
+	Country    string    `json:"country"`
+	Category   string    `json:"category"`
+	Language   string    `json:"language"`
+	ServedBy   string    `json:"served_by"`
+	Body       []byte    `json:"-"`
+	FetchedAt  time.Time `json:"fetched_at"`
+	ExpiresAt  time.Time `json:"expires_at" gorm:"index"`
+	StaleUntil time.Time `json:"stale_until"`
+}
+
+// ClassifierModel is a trained article category classifier. Data holds the
+// JSON-encoded nlp.NaiveBayes; the newest row is the one in use.
+type ClassifierModel struct {
+	gorm.Model
+	Data    []byte      `json:"-"`
+	Metrics nlp.Metrics `json:"metrics" gorm:"type:jsonb;serializer:json"`
+}
+
 // @model SwaggerAPIResponse
 type SwaggerAPIResponse struct {
 	Status        string    `json:"status"`
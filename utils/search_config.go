@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"log"
+	"sync"
+
+	"go_news_api/nlp"
+)
+
+// defaultSearchConfig is used for articles whose language is unknown
+const defaultSearchConfig = "english"
+
+// UndeterminedLanguage is the language recorded for articles whose language
+// could not be detected (the ISO 639-2 code for undetermined), so that they
+// are not taken for articles never looked at
+const UndeterminedLanguage = "und"
+
+var (
+	searchConfigsOnce sync.Once
+	searchConfigs     map[string]bool
+)
+
+// SearchConfigFor returns the Postgres text search configuration for an
+// ISO 639-1 language code. Configurations the server does not have (older
+// Postgres versions lack e.g. "indonesian") fall back to "simple".
+func SearchConfigFor(code string) string {
+	if code == "" || code == UndeterminedLanguage {
+		return defaultSearchConfig
+	}
+	config := nlp.SearchConfig(code)
+
+	searchConfigsOnce.Do(func() {
+		var names []string
+		if err := DB.Raw("SELECT cfgname FROM pg_ts_config").Scan(&names).Error; err != nil {
+			log.Printf("Failed to list text search configurations: %v", err)
+			return
+		}
+		searchConfigs = make(map[string]bool, len(names))
+		for _, name := range names {
+			searchConfigs[name] = true
+		}
+	})
+	if searchConfigs != nil && !searchConfigs[config] {
+		return "simple"
+	}
+	return config
+}
+
+// DetectArticleLanguage sets an article's language, confidence and search
+// configuration from its text. hint is the language the provider was asked
+// for, if any.
+func DetectArticleLanguage(article *Article, text, hint string) {
+	if hint == UndeterminedLanguage {
+		hint = ""
+	}
+	code, confidence := nlp.DetectLanguageWithHint(text, hint)
+	if code == "" {
+		code = UndeterminedLanguage
+	}
+	article.Language = code
+	article.LanguageConfidence = confidence
+	article.SearchConfig = SearchConfigFor(code)
+}